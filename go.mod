@@ -1,5 +1,25 @@
 module github.com/salrashid123/terraform-provider-http-full
 
-require github.com/hashicorp/terraform-plugin-sdk/v2 v2.7.0
+require (
+	cloud.google.com/go v0.61.0
+	github.com/Azure/go-ntlmssp v0.0.0-20221128193559-754e69321358
+	github.com/Microsoft/go-winio v0.5.2
+	github.com/ThalesIgnite/crypto11 v1.2.5
+	github.com/andybalholm/brotli v1.0.2
+	github.com/dop251/goja v0.0.0-20230605162241-28ee0ee714f3
+	github.com/hashicorp/terraform-plugin-sdk/v2 v2.7.0
+	github.com/itchyny/gojq v0.12.7
+	github.com/jcmturner/gokrb5/v8 v8.4.2
+	github.com/klauspost/compress v1.11.2
+	github.com/miekg/pkcs11 v1.1.1 // indirect
+	github.com/youmark/pkcs8 v0.0.0-20201027041543-1326539a0a0a
+	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
+	golang.org/x/net v0.0.0-20220722155237-a158d28d115b
+	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
+	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba
+	google.golang.org/grpc v1.32.0
+	google.golang.org/protobuf v1.25.0
+	gopkg.in/yaml.v2 v2.4.0
+)
 
 go 1.13
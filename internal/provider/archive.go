@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+const (
+	archiveZip   = "zip"
+	archiveTarGz = "tar.gz"
+)
+
+var archiveFormats = []string{archiveZip, archiveTarGz}
+
+// extractMember copies a single member of the zip or gzipped tar archive
+// read from r to w and returns its permission bits. A zip archive, whose
+// directory is at its end, is spooled to a temporary file first. Members
+// larger than limit bytes, when limit is positive, fail the extraction.
+func extractMember(w io.Writer, r io.Reader, format, member string, limit int64) (os.FileMode, error) {
+	want := path.Clean(member)
+	switch format {
+	case archiveZip:
+		spool, err := ioutil.TempFile("", "http-full-archive")
+		if err != nil {
+			return 0, err
+		}
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+		size, err := io.Copy(spool, r)
+		if err != nil {
+			return 0, err
+		}
+		zr, err := zip.NewReader(spool, size)
+		if err != nil {
+			return 0, fmt.Errorf("reading zip archive: %s", err)
+		}
+		for _, f := range zr.File {
+			if path.Clean(f.Name) != want || f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return 0, err
+			}
+			defer rc.Close()
+			return f.Mode().Perm(), copyMember(w, rc, limit)
+		}
+	case archiveTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return 0, fmt.Errorf("reading tar.gz archive: %s", err)
+		}
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return 0, fmt.Errorf("reading tar.gz archive: %s", err)
+			}
+			if path.Clean(hdr.Name) != want || hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			return os.FileMode(hdr.Mode).Perm(), copyMember(w, tr, limit)
+		}
+	default:
+		return 0, fmt.Errorf("unsupported archive format %q", format)
+	}
+	return 0, fmt.Errorf("%q not found in archive", member)
+}
+
+// copyMember copies a member to w, failing once it exceeds limit bytes so
+// that a small archive can't expand into an arbitrarily large file.
+func copyMember(w io.Writer, r io.Reader, limit int64) error {
+	if limit <= 0 {
+		_, err := io.Copy(w, r)
+		return err
+	}
+	n, err := io.Copy(w, io.LimitReader(r, limit+1))
+	if err != nil {
+		return err
+	}
+	if n > limit {
+		return fmt.Errorf("member exceeds max_decompressed_bytes (%d bytes)", limit)
+	}
+	return nil
+}
+
+// writeOutputFile atomically replaces name with what write writes, giving it
+// the mode write returns, and returns the SHA-256 of the content.
+func writeOutputFile(name string, write func(io.Writer) (os.FileMode, error)) (string, error) {
+	name = expandHome(name)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(name), ".http-full")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	sum := sha256.New()
+	mode, err := write(io.MultiWriter(tmp, sum))
+	if err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
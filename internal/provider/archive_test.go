@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestExtractMember(t *testing.T) {
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	hdr := &zip.FileHeader{Name: "tool_1.0/bin/tool", Method: zip.Deflate}
+	hdr.SetMode(0755)
+	w, _ := zw.CreateHeader(hdr)
+	w.Write([]byte("binary"))
+	w, _ = zw.Create("tool_1.0/README")
+	w.Write([]byte("readme"))
+	zw.Close()
+
+	var tgzBuf bytes.Buffer
+	gz := gzip.NewWriter(&tgzBuf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "./manifest.yaml", Mode: 0644, Size: 5, Typeflag: tar.TypeReg})
+	tw.Write([]byte("kind:"))
+	tw.Close()
+	gz.Close()
+
+	var content bytes.Buffer
+	mode, err := extractMember(&content, bytes.NewReader(zipBuf.Bytes()), archiveZip, "tool_1.0/bin/tool", 0)
+	if err != nil || content.String() != "binary" || mode != 0755 {
+		t.Fatalf("zip: got %q (%s, %v); want binary with mode 0755", content.String(), mode, err)
+	}
+	content.Reset()
+	_, err = extractMember(&content, bytes.NewReader(tgzBuf.Bytes()), archiveTarGz, "manifest.yaml", 0)
+	if err != nil || content.String() != "kind:" {
+		t.Fatalf("tar.gz: got %q (%v); want kind:", content.String(), err)
+	}
+	if _, err := extractMember(ioutil.Discard, bytes.NewReader(zipBuf.Bytes()), archiveZip, "missing", 0); err == nil {
+		t.Fatalf("expected an error for a missing member")
+	}
+	for _, format := range []string{archiveZip, archiveTarGz} {
+		archive, member := zipBuf.Bytes(), "tool_1.0/bin/tool"
+		if format == archiveTarGz {
+			archive, member = tgzBuf.Bytes(), "manifest.yaml"
+		}
+		if _, err := extractMember(ioutil.Discard, bytes.NewReader(archive), format, member, 3); err == nil {
+			t.Errorf("%s: member larger than the limit was extracted", format)
+		}
+	}
+}
+
+func TestWriteOutputFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "output")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "bin", "tool")
+
+	sum, err := writeOutputFile(name, func(w io.Writer) (os.FileMode, error) {
+		_, err := io.Copy(w, strings.NewReader("hello"))
+		return 0755, err
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if sum != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Fatalf("sha256 %s does not match the content", sum)
+	}
+	info, err := os.Stat(name)
+	if err != nil || info.Mode().Perm() != 0755 {
+		t.Fatalf("file mode %v (%v); want 0755", info, err)
+	}
+}
+
+func TestDataSourceRead_outputFileStreamed(t *testing.T) {
+	payload := strings.Repeat("x", 64)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte(payload))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "output")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "download")
+
+	d := schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{
+		"url":                    server.URL,
+		"output_file":            name,
+		"max_response_body_size": 16,
+	})
+	if diags := dataSourceRead(context.Background(), d, nil); diags.HasError() {
+		t.Fatalf("err: %v", diags)
+	}
+	content, err := ioutil.ReadFile(name)
+	if err != nil || string(content) != payload {
+		t.Fatalf("got %q (%v); want the whole download despite max_response_body_size", content, err)
+	}
+}
@@ -0,0 +1,239 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// operationLocationHeaders are the headers naming the status monitor of an
+// accepted long-running operation, in order of preference: Azure's two and
+// the standard Location.
+var operationLocationHeaders = []string{"Operation-Location", "Azure-AsyncOperation", "Location"}
+
+// maxPollDelay caps the wait a Retry-After header can ask for between two
+// polls, unless poll_interval_ms is longer.
+var maxPollDelay = time.Minute
+
+// defaultSuccessValues and defaultFailureValues are the terminal statuses
+// of Azure long-running operations.
+var (
+	defaultSuccessValues = []string{"Succeeded"}
+	defaultFailureValues = []string{"Failed", "Canceled"}
+)
+
+// pollOperation waits for the long-running operation started by req, which
+// was answered with resp, a 202 Accepted, and returns the response that
+// completed it. The status monitor named by the response headers is polled
+// with GET, or req itself again unless follow_location is set, at the
+// interval asked for by Retry-After, up to maxPollDelay, or else
+// poll_interval_ms. Without success_jsonpath the operation is complete once
+// the answer isn't a 202; with it, once the value it points to is one of
+// success_values. A status monitor named by Operation-Location or
+// Azure-AsyncOperation only describes the operation, so on success the
+// resource it names in resourceLocation, or else the Location of the 202,
+// is fetched and returned instead. Status bodies are read up to limit bytes.
+func pollOperation(ctx context.Context, client *http.Client, req *http.Request, resp *http.Response, policy *retryPolicy, budget *retryBudget, async map[string]interface{}, limit int64) (*http.Response, error) {
+	timeout := time.Duration(async["timeout_ms"].(int)) * time.Millisecond
+	interval := time.Duration(async["poll_interval_ms"].(int)) * time.Millisecond
+	follow := async["follow_location"].(bool)
+	deadline := time.Now().Add(timeout)
+	maxDelay := maxPollDelay
+	if interval > maxDelay {
+		maxDelay = interval
+	}
+
+	pollURL := req.URL
+	var monitor string
+	var resourceURL *url.URL
+	if v := resp.Header.Get("Location"); v != "" {
+		resourceURL, _ = req.URL.Parse(v)
+	}
+	for attempt := 1; ; attempt++ {
+		if follow {
+			if next, name, err := operationLocation(pollURL, resp.Header); err != nil {
+				resp.Body.Close()
+				return nil, err
+			} else if next != nil {
+				pollURL, monitor = next, name
+			}
+		}
+		delay := interval
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			delay = d
+			if delay > maxDelay {
+				delay = maxDelay
+			}
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		if time.Now().Add(delay).After(deadline) {
+			return nil, fmt.Errorf("operation not complete after %s (%d polls)", timeout, attempt-1)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+
+		poll, err := pollRequest(ctx, req, pollURL, follow)
+		if err != nil {
+			return nil, err
+		}
+		if resp, err = doWithRetry(ctx, client, poll, policy, budget); err != nil {
+			return nil, err
+		}
+		done, err := operationDone(resp, async, limit)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if !done {
+			continue
+		}
+		if monitor == "Location" || monitor == "" || resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return resp, nil
+		}
+		if u, err := resourceLocation(pollURL, resp, limit); err != nil {
+			resp.Body.Close()
+			return nil, err
+		} else if u != nil {
+			resourceURL = u
+		}
+		if resourceURL == nil {
+			return resp, nil
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		get, err := pollRequest(ctx, req, resourceURL, true)
+		if err != nil {
+			return nil, err
+		}
+		return doWithRetry(ctx, client, get, policy, budget)
+	}
+}
+
+// operationLocation returns the status monitor URL named by header,
+// resolved against current, and the header naming it, or nil if there is
+// none.
+func operationLocation(current *url.URL, header http.Header) (*url.URL, string, error) {
+	for _, name := range operationLocationHeaders {
+		if v := header.Get(name); v != "" {
+			u, err := current.Parse(v)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid %s %q: %s", name, v, err)
+			}
+			return u, name, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// resourceLocation returns the resourceLocation of the status monitor body
+// of a succeeded operation, resolved against current, or nil if it has
+// none. The body is left to be read again.
+func resourceLocation(current *url.URL, resp *http.Response, limit int64) (*url.URL, error) {
+	body, err := readBody(resp, limit)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	var status struct {
+		ResourceLocation string `json:"resourceLocation"`
+	}
+	if json.Unmarshal(body, &status) != nil || status.ResourceLocation == "" {
+		return nil, nil
+	}
+	u, err := current.Parse(status.ResourceLocation)
+	if err != nil {
+		return nil, fmt.Errorf("invalid resourceLocation %q: %s", status.ResourceLocation, err)
+	}
+	return u, nil
+}
+
+// pollRequest returns the request polling the operation started by req: a
+// GET of the status monitor at pollURL with the same headers, except for
+// the body's and, on another host, the credentials, as for redirects; or
+// req sent again when not following the location.
+func pollRequest(ctx context.Context, req *http.Request, pollURL *url.URL, follow bool) (*http.Request, error) {
+	if !follow {
+		poll := req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			poll.Body = body
+		}
+		return poll, nil
+	}
+	poll, err := http.NewRequestWithContext(ctx, http.MethodGet, pollURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range req.Header {
+		switch http.CanonicalHeaderKey(name) {
+		case "Content-Type", "Content-Length", "Content-Md5", "Digest", "Content-Digest":
+			continue
+		}
+		poll.Header[name] = values
+	}
+	if pollURL.Host != req.URL.Host {
+		stripCredentials(poll.Header)
+	}
+	return poll, nil
+}
+
+// operationDone reports whether resp, the answer to a poll, completes the
+// operation. Its body is left to be read again.
+func operationDone(resp *http.Response, async map[string]interface{}, limit int64) (bool, error) {
+	path := async["success_jsonpath"].(string)
+	if path == "" || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode != http.StatusAccepted, nil
+	}
+	body, err := readBody(resp, limit)
+	resp.Body.Close()
+	if err != nil {
+		return false, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	// The status may be missing until the operation is under way.
+	outputs, err := jsonExtract(body, map[string]interface{}{"status": path})
+	if err != nil {
+		return false, nil
+	}
+	status := outputs["status"]
+	if statusIn(status, async["success_values"].([]interface{}), defaultSuccessValues) {
+		return true, nil
+	}
+	if statusIn(status, async["failure_values"].([]interface{}), defaultFailureValues) {
+		return false, fmt.Errorf("operation ended with status %q", status)
+	}
+	return false, nil
+}
+
+// statusIn reports whether status is one of values, or of defaults when no
+// values are configured.
+func statusIn(status string, values []interface{}, defaults []string) bool {
+	if len(values) == 0 {
+		for _, v := range defaults {
+			values = append(values, v)
+		}
+	}
+	for _, v := range values {
+		if status == v.(string) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPollOperation(t *testing.T) {
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/jobs":
+			w.Header().Set("Operation-Location", "/operations/1")
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusAccepted)
+		case "/operations/1":
+			if r.Method != http.MethodGet || r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if atomic.AddInt32(&polls, 1) < 3 {
+				fmt.Fprint(w, `{"status": "Running"}`)
+				return
+			}
+			fmt.Fprint(w, `{"status": "Succeeded", "id": "42"}`)
+		case "/failed":
+			fmt.Fprint(w, `{"status": "Failed"}`)
+		case "/export":
+			// Plain HTTP: 202 until the result is ready.
+			if atomic.AddInt32(&polls, 1) < 3 {
+				w.Header().Set("Location", "/export")
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			fmt.Fprint(w, "done")
+		}
+	}))
+	defer server.Close()
+
+	start := func(path, location string) (*http.Request, *http.Response) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader("{}"))
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Content-Type", "application/json")
+		resp := &http.Response{StatusCode: http.StatusAccepted, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(""))}
+		resp.Header.Set("Operation-Location", location)
+		return req, resp
+	}
+	async := func(jsonPath string) map[string]interface{} {
+		return map[string]interface{}{
+			"follow_location":  true,
+			"poll_interval_ms": 1,
+			"timeout_ms":       5000,
+			"success_jsonpath": jsonPath,
+			"success_values":   []interface{}{},
+			"failure_values":   []interface{}{},
+		}
+	}
+
+	req, resp := start("/jobs", "/operations/1")
+	resp, err := pollOperation(context.Background(), http.DefaultClient, req, resp, nil, nil, async("$.status"), defaultMaxResponseBodySize)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != `{"status": "Succeeded", "id": "42"}` || polls != 3 {
+		t.Errorf("got %s after %d polls; want the final status after 3", body, polls)
+	}
+
+	req, resp = start("/jobs", "/failed")
+	if _, err := pollOperation(context.Background(), http.DefaultClient, req, resp, nil, nil, async("$.status"), defaultMaxResponseBodySize); err == nil || !strings.Contains(err.Error(), "Failed") {
+		t.Errorf("failed operation: got %v", err)
+	}
+
+	polls = 0
+	req, resp = start("/export", "/export")
+	if resp, err = pollOperation(context.Background(), http.DefaultClient, req, resp, nil, nil, async(""), defaultMaxResponseBodySize); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "done" || resp.StatusCode != http.StatusOK {
+		t.Errorf("got %d %s; want the response after the last 202", resp.StatusCode, body)
+	}
+
+	settings := async("$.status")
+	settings["timeout_ms"] = 0
+	req, resp = start("/jobs", "/operations/1")
+	if _, err := pollOperation(context.Background(), http.DefaultClient, req, resp, nil, nil, settings, defaultMaxResponseBodySize); err == nil || !strings.Contains(err.Error(), "not complete") {
+		t.Errorf("timeout: got %v", err)
+	}
+}
+
+func TestPollOperation_resource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/operations/with-resource":
+			w.Header().Set("Retry-After", "3600")
+			fmt.Fprint(w, `{"status": "Succeeded", "resourceLocation": "/vms/1"}`)
+		case "/operations/plain":
+			fmt.Fprint(w, `{"status": "Succeeded"}`)
+		case "/vms/1", "/vms/2":
+			fmt.Fprintf(w, `{"id": %q}`, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	defaultDelay := maxPollDelay
+	maxPollDelay = time.Millisecond
+	defer func() { maxPollDelay = defaultDelay }()
+
+	cases := []struct {
+		name     string
+		monitor  string
+		location string
+		want     string
+	}{
+		{"resourceLocation", "/operations/with-resource", "/vms/2", `{"id": "/vms/1"}`},
+		{"Location", "/operations/plain", "/vms/2", `{"id": "/vms/2"}`},
+		{"status only", "/operations/plain", "", `{"status": "Succeeded"}`},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest(http.MethodPut, server.URL+"/vms", nil)
+		resp := &http.Response{StatusCode: http.StatusAccepted, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(""))}
+		resp.Header.Set("Azure-AsyncOperation", tc.monitor)
+		resp.Header.Set("Retry-After", "3600")
+		if tc.location != "" {
+			resp.Header.Set("Location", tc.location)
+		}
+		resp, err := pollOperation(context.Background(), http.DefaultClient, req, resp, nil, nil, map[string]interface{}{
+			"follow_location":  true,
+			"poll_interval_ms": 1,
+			"timeout_ms":       5000,
+			"success_jsonpath": "$.status",
+			"success_values":   []interface{}{},
+			"failure_values":   []interface{}{},
+		}, defaultMaxResponseBodySize)
+		if err != nil {
+			t.Errorf("%s: err: %s", tc.name, err)
+			continue
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != tc.want {
+			t.Errorf("%s: got %s; want %s", tc.name, body, tc.want)
+		}
+	}
+}
+
+func TestPollRequest(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPut, "https://api.example.com/items/1", strings.NewReader("{}"))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-Source", "terraform")
+
+	same, _ := req.URL.Parse("/operations/1")
+	poll, err := pollRequest(context.Background(), req, same, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if poll.Method != http.MethodGet || poll.Header.Get("Authorization") == "" || poll.Header.Get("Content-Type") != "" || poll.Header.Get("X-Request-Source") == "" {
+		t.Errorf("same host: got %s with %v", poll.Method, poll.Header)
+	}
+
+	other, _ := req.URL.Parse("https://status.example.net/operations/1")
+	if poll, _ = pollRequest(context.Background(), req, other, true); poll.Header.Get("Authorization") != "" {
+		t.Errorf("other host: credentials sent to %s", other)
+	}
+
+	if poll, _ = pollRequest(context.Background(), req, same, false); poll.Method != http.MethodPut || poll.URL.String() != req.URL.String() {
+		t.Errorf("not following: got %s %s; want the request again", poll.Method, poll.URL)
+	}
+}
@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// azureIMDSEndpoint is the token endpoint of the Azure Instance Metadata
+// Service, used for managed identities on VMs, AKS and Container Apps.
+var azureIMDSEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// azureToken returns an Azure AD access token for the resource of an
+// azure_auth block: with the client credentials grant when client_secret is
+// set, otherwise from the managed identity of the machine.
+func azureToken(ctx context.Context, config *providerConfig, client *http.Client, block map[string]interface{}) (string, error) {
+	resource := block["resource"].(string)
+	clientID := block["client_id"].(string)
+	clientSecret := block["client_secret"].(string)
+	tenantID := block["tenant_id"].(string)
+	authorityHost := strings.TrimSuffix(block["authority_host"].(string), "/")
+
+	key := strings.Join([]string{"azure", authorityHost, tenantID, clientID, resource}, "|")
+	return config.tokens.token(ctx, key, func(ctx context.Context) (string, time.Time, error) {
+		if clientSecret == "" {
+			// The identity endpoint is local to the machine, never behind
+			// a proxy.
+			return azureManagedIdentityToken(ctx, &http.Client{Timeout: config.requestTimeout}, resource, clientID)
+		}
+		if tenantID == "" {
+			return "", time.Time{}, fmt.Errorf("tenant_id is required with client_secret")
+		}
+		cc := &clientcredentials.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			TokenURL:     authorityHost + "/" + tenantID + "/oauth2/v2.0/token",
+			Scopes:       []string{strings.TrimSuffix(resource, "/") + "/.default"},
+			AuthStyle:    oauth2.AuthStyleInParams,
+		}
+		token, err := cc.Token(context.WithValue(ctx, oauth2.HTTPClient, client))
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		return token.AccessToken, token.Expiry, nil
+	})
+}
+
+// azureManagedIdentityToken obtains a token from the App Service identity
+// endpoint when its environment variables are set, otherwise from IMDS.
+// clientID selects a user-assigned identity.
+func azureManagedIdentityToken(ctx context.Context, client *http.Client, resource, clientID string) (string, time.Time, error) {
+	endpoint, apiVersion := azureIMDSEndpoint, "2018-02-01"
+	header, secret := "Metadata", "true"
+	if v := os.Getenv("IDENTITY_ENDPOINT"); v != "" && os.Getenv("IDENTITY_HEADER") != "" {
+		endpoint, apiVersion = v, "2019-08-01"
+		header, secret = "X-IDENTITY-HEADER", os.Getenv("IDENTITY_HEADER")
+	}
+
+	q := url.Values{"api-version": {apiVersion}, "resource": {resource}}
+	if clientID != "" {
+		q.Set("client_id", clientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set(header, secret)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("managed identity endpoint returned %d: %s", resp.StatusCode, body)
+	}
+	var token struct {
+		AccessToken string      `json:"access_token"`
+		ExpiresOn   json.Number `json:"expires_on"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", time.Time{}, fmt.Errorf("decoding managed identity token: %s", err)
+	}
+	var expiry time.Time
+	if secs, err := strconv.ParseInt(token.ExpiresOn.String(), 10, 64); err == nil {
+		expiry = time.Unix(secs, 0)
+	}
+	return token.AccessToken, expiry, nil
+}
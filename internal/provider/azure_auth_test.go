@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAzureToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/tenant/oauth2/v2.0/token":
+			if r.FormValue("client_id") != "app" || r.FormValue("client_secret") != "secret" || r.FormValue("scope") != "api://backend/.default" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "client-token", "token_type": "Bearer", "expires_in": 3600})
+		case "/imds":
+			if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("resource") != "api://backend" || r.URL.Query().Get("client_id") != "identity" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "msi-token", "expires_on": "4102444800"})
+		}
+	}))
+	defer server.Close()
+
+	defaultEndpoint := azureIMDSEndpoint
+	azureIMDSEndpoint = server.URL + "/imds"
+	defer func() { azureIMDSEndpoint = defaultEndpoint }()
+
+	cases := []struct {
+		name  string
+		block map[string]interface{}
+		want  string
+	}{
+		{"client credentials", map[string]interface{}{"resource": "api://backend", "tenant_id": "tenant", "client_id": "app", "client_secret": "secret", "authority_host": server.URL}, "client-token"},
+		{"managed identity", map[string]interface{}{"resource": "api://backend", "tenant_id": "", "client_id": "identity", "client_secret": "", "authority_host": server.URL}, "msi-token"},
+	}
+
+	for _, tc := range cases {
+		token, err := azureToken(context.Background(), configFromMeta(nil), http.DefaultClient, tc.block)
+		if err != nil {
+			t.Errorf("%s: err: %s", tc.name, err)
+			continue
+		}
+		if token != tc.want {
+			t.Errorf("%s: got token %q; want %q", tc.name, token, tc.want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// dataSourceBatch sends many requests concurrently over a single client, so
+// that fetching a few hundred URLs shares connections and TLS sessions
+// instead of paying for a handshake in each of as many data sources.
+func dataSourceBatch() *schema.Resource {
+	return &schema.Resource{
+		Description: "Sends a set of HTTP requests concurrently.",
+
+		ReadContext: dataSourceBatchRead,
+
+		Schema: map[string]*schema.Schema{
+			"request": {
+				Type:     schema.TypeList,
+				Required: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"key": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"url": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"method": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      http.MethodGet,
+							ValidateFunc: validateVerb,
+						},
+						"request_headers": {
+							Type:     schema.TypeMap,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"request_body": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+
+			"parallelism": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      10,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+
+			"request_headers": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"max_response_body_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      defaultMaxResponseBodySize,
+				ValidateFunc: validateNonNegative,
+			},
+
+			"ca": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"bodies": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"status_codes": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+
+			"response_headers": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+// batchRequest is one entry of the request list.
+type batchRequest struct {
+	key     string
+	url     string
+	method  string
+	headers map[string]interface{}
+	body    string
+}
+
+// batchResponse is the outcome of a batchRequest.
+type batchResponse struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+	err        error
+}
+
+func dataSourceBatchRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := configFromMeta(meta)
+	tlsConfig, diags := newTLSConfig(ctx, d, config)
+	if diags.HasError() {
+		return diags
+	}
+	tr, err := config.baseTransport(tlsConfig)
+	if err != nil {
+		return append(diags, diag.Errorf("Error configuring proxy: %s", err)...)
+	}
+	parallelism := d.Get("parallelism").(int)
+	// Keep a connection per worker for reuse rather than the default two
+	// per host, the usual case being many URLs of the same API.
+	tr.MaxIdleConnsPerHost = parallelism
+	client := &http.Client{
+		Transport: config.wrapTransport(&decompressTransport{next: tr, limit: defaultMaxDecompressedBytes}),
+		Timeout:   config.requestTimeout,
+	}
+
+	var requests []batchRequest
+	seen := map[string]bool{}
+	for _, v := range d.Get("request").([]interface{}) {
+		r := v.(map[string]interface{})
+		key := r["key"].(string)
+		if seen[key] {
+			return append(diags, diag.Errorf("Error in request: duplicate key %q", key)...)
+		}
+		seen[key] = true
+		requests = append(requests, batchRequest{
+			key:     key,
+			url:     config.resolveURL(r["url"].(string)),
+			method:  r["method"].(string),
+			headers: r["request_headers"].(map[string]interface{}),
+			body:    r["request_body"].(string),
+		})
+	}
+
+	headers := d.Get("request_headers").(map[string]interface{})
+	limit := int64(d.Get("max_response_body_size").(int))
+	ctx = withRecordingPolicy(ctx, recordingPolicy{limit: limit})
+	responses := runBatch(ctx, client, config, headers, requests, parallelism, limit)
+
+	bodies := make(map[string]string, len(requests))
+	statusCodes := make(map[string]int, len(requests))
+	responseHeaders := make(map[string]string)
+	for i, r := range requests {
+		resp := responses[i]
+		if resp.err != nil {
+			return append(diags, diag.Errorf("Error making request %q: %s", r.key, resp.err)...)
+		}
+		bodies[r.key] = string(resp.body)
+		statusCodes[r.key] = resp.statusCode
+		for name, values := range resp.header {
+			responseHeaders[r.key+"/"+name] = strings.Join(values, ", ")
+		}
+	}
+
+	d.Set("bodies", bodies)
+	d.Set("status_codes", statusCodes)
+	d.Set("response_headers", responseHeaders)
+	id := sha256.New()
+	for _, r := range requests {
+		fmt.Fprintf(id, "%s %s %s\n", r.key, r.method, r.url)
+	}
+	d.SetId(hex.EncodeToString(id.Sum(nil)))
+	return diags
+}
+
+// runBatch sends requests with at most parallelism of them in flight and
+// returns their responses in the same order.
+func runBatch(ctx context.Context, client *http.Client, config *providerConfig, headers map[string]interface{}, requests []batchRequest, parallelism int, limit int64) []batchResponse {
+	responses := make([]batchResponse, len(requests))
+	slots := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			responses[i] = batchDo(ctx, client, config, headers, requests[i], limit)
+		}(i)
+	}
+	wg.Wait()
+	return responses
+}
+
+func batchDo(ctx context.Context, client *http.Client, config *providerConfig, headers map[string]interface{}, r batchRequest, limit int64) batchResponse {
+	var body io.Reader
+	if r.body != "" {
+		body = strings.NewReader(r.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, r.url, body)
+	if err != nil {
+		return batchResponse{err: err}
+	}
+	// As on the http data source, the provider's default headers come first
+	// and the request's own last.
+	for _, headers := range []map[string]interface{}{config.defaultHeaders, headers, r.headers} {
+		for name, value := range headers {
+			req.Header.Set(name, value.(string))
+		}
+	}
+
+	resp, err := doWithRetry(ctx, client, req, config.retryPolicy, config.retryBudget)
+	if err != nil {
+		return batchResponse{err: err}
+	}
+	defer resp.Body.Close()
+	data, err := readBody(resp, limit)
+	if err != nil {
+		return batchResponse{err: err}
+	}
+	return batchResponse{statusCode: resp.StatusCode, header: resp.Header, body: data}
+}
@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceBatchRead(t *testing.T) {
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("X-Token", r.Header.Get("X-Token"))
+		fmt.Fprintf(w, "%s %s", r.Method, r.URL.Path)
+	}))
+	defer server.Close()
+
+	var requests []interface{}
+	for i := 0; i < 8; i++ {
+		requests = append(requests, map[string]interface{}{
+			"key": fmt.Sprintf("r%d", i),
+			"url": fmt.Sprintf("%s/item/%d", server.URL, i),
+		})
+	}
+	requests = append(requests,
+		map[string]interface{}{
+			"key":             "post",
+			"url":             server.URL + "/items",
+			"method":          "POST",
+			"request_body":    "{}",
+			"request_headers": map[string]interface{}{"X-Token": "override"},
+		},
+		map[string]interface{}{"key": "missing", "url": server.URL + "/missing"},
+	)
+	d := schema.TestResourceDataRaw(t, dataSourceBatch().Schema, map[string]interface{}{
+		"request":         requests,
+		"parallelism":     3,
+		"request_headers": map[string]interface{}{"X-Token": "shared"},
+	})
+	if diags := dataSourceBatchRead(context.Background(), d, nil); diags.HasError() {
+		t.Fatalf("err: %v", diags)
+	}
+
+	if maxInFlight > 3 {
+		t.Errorf("got %d requests in flight; want at most 3", maxInFlight)
+	}
+	bodies := d.Get("bodies").(map[string]interface{})
+	statusCodes := d.Get("status_codes").(map[string]interface{})
+	headers := d.Get("response_headers").(map[string]interface{})
+	if got := bodies["r5"]; got != "GET /item/5" {
+		t.Errorf("got body %q for r5", got)
+	}
+	if got := bodies["post"]; got != "POST /items" {
+		t.Errorf("got body %q for post", got)
+	}
+	if got := headers["r0/X-Token"]; got != "shared" {
+		t.Errorf("got X-Token %q for r0; want the shared header", got)
+	}
+	if got := headers["post/X-Token"]; got != "override" {
+		t.Errorf("got X-Token %q for post; want the request's own header", got)
+	}
+	if got := statusCodes["missing"]; got != http.StatusNotFound {
+		t.Errorf("got status %v for missing; want 404", got)
+	}
+
+	d = schema.TestResourceDataRaw(t, dataSourceBatch().Schema, map[string]interface{}{
+		"request": []interface{}{
+			map[string]interface{}{"key": "a", "url": server.URL},
+			map[string]interface{}{"key": "a", "url": server.URL},
+		},
+	})
+	if diags := dataSourceBatchRead(context.Background(), d, nil); !diags.HasError() {
+		t.Errorf("duplicate keys were accepted")
+	}
+
+	d = schema.TestResourceDataRaw(t, dataSourceBatch().Schema, map[string]interface{}{
+		"request":                []interface{}{map[string]interface{}{"key": "big", "url": server.URL + "/a/long/path"}},
+		"max_response_body_size": 4,
+	})
+	if diags := dataSourceBatchRead(context.Background(), d, nil); !diags.HasError() {
+		t.Errorf("response larger than max_response_body_size was accepted")
+	}
+}
@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+)
+
+// captureTransport records the last request it sends, so that the capture
+// holds the headers added by the wrapping transports, such as
+// Accept-Encoding or the response to an authentication challenge, rather
+// than the request as it was built.
+type captureTransport struct {
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	captured string
+	err      error
+}
+
+func (t *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		body, err = ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	captured, err := captureRequest(req, body)
+	t.mu.Lock()
+	t.captured, t.err = captured, err
+	t.mu.Unlock()
+	return t.next.RoundTrip(req)
+}
+
+// result returns the last request sent.
+func (t *captureTransport) result() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.captured, t.err
+}
+
+// captureRequest serializes req as it goes on the wire, with credentials
+// redacted from its headers and body.
+func captureRequest(req *http.Request, body []byte) (string, error) {
+	out := req.Clone(req.Context())
+	out.Header = redactHeaders(req.Header)
+	if body != nil {
+		body = redactBody(req.Header.Get("Content-Type"), body)
+		out.Body = ioutil.NopCloser(bytes.NewReader(body))
+		out.ContentLength = int64(len(body))
+	}
+	dump, err := httputil.DumpRequestOut(out, body != nil)
+	if err != nil {
+		return "", err
+	}
+	// DumpRequestOut asks for gzip like a default transport would, while
+	// ours have compression disabled and only send what decompressTransport
+	// set.
+	if req.Header.Get("Accept-Encoding") == "" {
+		dump = bytes.Replace(dump, []byte("Accept-Encoding: gzip\r\n"), nil, 1)
+	}
+	return string(dump), nil
+}
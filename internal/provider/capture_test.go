@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCaptureRequest(t *testing.T) {
+	body := []byte(`{"x":1,"client_secret":"s3cr3t"}`)
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/items?a=1", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Api-Key", "secret")
+	req.Header.Set("Content-Type", "application/json")
+
+	got, err := captureRequest(req, body)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, want := range []string{
+		"POST /items?a=1 HTTP/1.1\r\n",
+		"Host: example.com\r\n",
+		"Authorization: REDACTED\r\n",
+		"X-Api-Key: REDACTED\r\n",
+		"Content-Type: application/json\r\n",
+		"Content-Length: 34\r\n",
+		"\r\n\r\n{\"client_secret\":\"REDACTED\",\"x\":1}",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("capture is missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Bearer secret") || strings.Contains(got, ": secret") || strings.Contains(got, "s3cr3t") {
+		t.Errorf("capture leaks a credential:\n%s", got)
+	}
+	if strings.Contains(got, "Accept-Encoding") {
+		t.Errorf("capture adds an Accept-Encoding header that is not sent:\n%s", got)
+	}
+	if req.Header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("capture modified the original request")
+	}
+}
+
+func TestCaptureTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	capture := &captureTransport{next: &http.Transport{DisableCompression: true}}
+	client := &http.Client{Transport: &decompressTransport{next: capture}}
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"/items", strings.NewReader("name=api&password=hunter2"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+
+	got, err := capture.result()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, want := range []string{
+		"PUT /items HTTP/1.1\r\n",
+		"Accept-Encoding: gzip, br, zstd\r\n",
+		"\r\n\r\nname=api&password=REDACTED",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("capture is missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "hunter2") {
+		t.Errorf("capture leaks a credential:\n%s", got)
+	}
+}
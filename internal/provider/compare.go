@@ -0,0 +1,246 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// dataSourceCompare fetches two URLs, such as the blue and green or the
+// staging and production deployment of a service, and reports whether they
+// answer the same, for promotion gates and configuration parity checks.
+func dataSourceCompare() *schema.Resource {
+	return &schema.Resource{
+		Description: "Compares the responses of two HTTP endpoints.",
+
+		ReadContext: dataSourceCompareRead,
+
+		Schema: map[string]*schema.Schema{
+			"url_a": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"url_b": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"request_headers": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"compare_headers": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"max_response_body_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      defaultMaxResponseBodySize,
+				ValidateFunc: validateNonNegative,
+			},
+
+			"ca": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"match": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+
+			"body_match": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+
+			"headers_match": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+
+			"diff": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+// compareDifference is a single entry of the diff output: the location,
+// as a JSON pointer into the body or "header/<name>", both values, and the
+// side, "a" or "b", from which the value is missing rather than null.
+type compareDifference struct {
+	Path    string      `json:"path"`
+	A       interface{} `json:"a"`
+	B       interface{} `json:"b"`
+	Missing string      `json:"missing,omitempty"`
+}
+
+func dataSourceCompareRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := configFromMeta(meta)
+	tlsConfig, diags := newTLSConfig(ctx, d, config)
+	if diags.HasError() {
+		return diags
+	}
+	tr, err := config.baseTransport(tlsConfig)
+	if err != nil {
+		return append(diags, diag.Errorf("Error configuring proxy: %s", err)...)
+	}
+	client := &http.Client{
+		Transport: config.wrapTransport(&decompressTransport{next: tr, limit: defaultMaxDecompressedBytes}),
+		Timeout:   config.requestTimeout,
+	}
+
+	headers := d.Get("request_headers").(map[string]interface{})
+	limit := int64(d.Get("max_response_body_size").(int))
+	ctx = withRecordingPolicy(ctx, recordingPolicy{limit: limit})
+	urlA, urlB := d.Get("url_a").(string), d.Get("url_b").(string)
+	headerA, bodyA, err := compareFetch(ctx, client, config, urlA, headers, limit)
+	if err != nil {
+		return append(diags, diag.Errorf("Error fetching url_a: %s", err)...)
+	}
+	headerB, bodyB, err := compareFetch(ctx, client, config, urlB, headers, limit)
+	if err != nil {
+		return append(diags, diag.Errorf("Error fetching url_b: %s", err)...)
+	}
+
+	var differences []compareDifference
+	var a, b interface{}
+	if json.Unmarshal(bodyA, &a) == nil && json.Unmarshal(bodyB, &b) == nil {
+		jsonDiff("", a, b, &differences)
+	} else if string(bodyA) != string(bodyB) {
+		differences = append(differences, compareDifference{Path: "", A: string(bodyA), B: string(bodyB)})
+	}
+	bodyDifferences := len(differences)
+
+	for _, name := range d.Get("compare_headers").([]interface{}) {
+		name := http.CanonicalHeaderKey(name.(string))
+		va, vb := strings.Join(headerA[name], ", "), strings.Join(headerB[name], ", ")
+		if va != vb {
+			differences = append(differences, compareDifference{Path: "header/" + name, A: va, B: vb})
+		}
+	}
+	bodyMatch := bodyDifferences == 0
+	headersMatch := len(differences) == bodyDifferences
+
+	if differences == nil {
+		differences = []compareDifference{}
+	}
+	out, err := json.Marshal(differences)
+	if err != nil {
+		return append(diags, diag.Errorf("Error encoding diff: %s", err)...)
+	}
+
+	d.Set("body_match", bodyMatch)
+	d.Set("headers_match", headersMatch)
+	d.Set("match", bodyMatch && headersMatch)
+	d.Set("diff", string(out))
+	d.SetId(urlA + " " + urlB)
+	return diags
+}
+
+func compareFetch(ctx context.Context, client *http.Client, config *providerConfig, url string, headers map[string]interface{}, limit int64) (http.Header, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value.(string))
+	}
+	resp, err := doWithRetry(ctx, client, req, config.retryPolicy, config.retryBudget)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil, fmt.Errorf("HTTP request error. Response code: %d", resp.StatusCode)
+	}
+	body, err := readBody(resp, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Header, body, nil
+}
+
+// jsonDiff appends the differences between a and b, located by JSON pointer
+// relative to path. A key or element present on one side only differs even
+// when it is null on the other.
+func jsonDiff(path string, a, b interface{}, out *[]compareDifference) {
+	switch va := a.(type) {
+	case map[string]interface{}:
+		vb, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(va)+len(vb))
+		for k := range va {
+			keys = append(keys, k)
+		}
+		for k := range vb {
+			if _, ok := va[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ea, inA := va[k]
+			eb, inB := vb[k]
+			jsonDiffPresent(path+"/"+jsonPointerEscape(k), ea, eb, inA, inB, out)
+		}
+		return
+	case []interface{}:
+		vb, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(va) || i < len(vb); i++ {
+			var ea, eb interface{}
+			if i < len(va) {
+				ea = va[i]
+			}
+			if i < len(vb) {
+				eb = vb[i]
+			}
+			jsonDiffPresent(path+"/"+strconv.Itoa(i), ea, eb, i < len(va), i < len(vb), out)
+		}
+		return
+	}
+	if !jsonEqual(a, b) {
+		*out = append(*out, compareDifference{Path: path, A: a, B: b})
+	}
+}
+
+// jsonDiffPresent is jsonDiff for a value that may be missing from a or b.
+func jsonDiffPresent(path string, a, b interface{}, inA, inB bool, out *[]compareDifference) {
+	switch {
+	case !inA:
+		*out = append(*out, compareDifference{Path: path, A: nil, B: b, Missing: "a"})
+	case !inB:
+		*out = append(*out, compareDifference{Path: path, A: a, B: nil, Missing: "b"})
+	default:
+		jsonDiff(path, a, b, out)
+	}
+}
+
+func jsonPointerEscape(s string) string {
+	return strings.Replace(strings.Replace(s, "~", "~0", -1), "/", "~1", -1)
+}
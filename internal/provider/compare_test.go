@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJSONDiff(t *testing.T) {
+	var a, b interface{}
+	json.Unmarshal([]byte(`{"version": "1.2", "flags": {"x": true, "a/b": 1}, "zones": ["a", "b"], "owner": null}`), &a)
+	json.Unmarshal([]byte(`{"version": "1.3", "flags": {"x": true, "a/b": 2}, "zones": ["a"], "new": 1, "region": null}`), &b)
+
+	var differences []compareDifference
+	jsonDiff("", a, b, &differences)
+
+	got, _ := json.Marshal(differences)
+	want := `[{"path":"/flags/a~1b","a":1,"b":2},{"path":"/new","a":null,"b":1,"missing":"a"},{"path":"/owner","a":null,"b":null,"missing":"b"},{"path":"/region","a":null,"b":null,"missing":"a"},{"path":"/version","a":"1.2","b":"1.3"},{"path":"/zones/1","a":"b","b":null,"missing":"b"}]`
+	if string(got) != want {
+		t.Fatalf("got %s; want %s", got, want)
+	}
+
+	differences = nil
+	jsonDiff("", a, a, &differences)
+	if len(differences) != 0 {
+		t.Fatalf("identical documents differ: %v", differences)
+	}
+}
+
+func TestCompareFetch_status(t *testing.T) {
+	cases := []struct {
+		status int
+		ok     bool
+	}{
+		{http.StatusOK, true},
+		{http.StatusCreated, true},
+		{http.StatusNoContent, true},
+		{http.StatusMovedPermanently, false},
+		{http.StatusNotFound, false},
+	}
+	for _, tc := range cases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", "/elsewhere")
+			w.WriteHeader(tc.status)
+		}))
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		_, _, err := compareFetch(context.Background(), client, configFromMeta(nil), server.URL, nil, defaultMaxResponseBodySize)
+		server.Close()
+		if (err == nil) != tc.ok {
+			t.Errorf("%d: err %v; want ok %t", tc.status, err, tc.ok)
+		}
+	}
+}
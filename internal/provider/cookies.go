@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"net/http"
+	"time"
+)
+
+// responseCookies returns the cookies set by the final response, by name;
+// a cookie set more than once keeps its last value.
+func responseCookies(resp *http.Response) map[string]string {
+	cookies := make(map[string]string)
+	for _, cookie := range resp.Cookies() {
+		cookies[cookie.Name] = cookie.Value
+	}
+	return cookies
+}
+
+// flattenCookies describes the Set-Cookie headers of a response, in order,
+// as the set_cookies attribute. expires is empty for session cookies and
+// max_age is 0 when not set, or -1 for Max-Age=0, as in net/http.
+func flattenCookies(cookies []*http.Cookie) []interface{} {
+	var out []interface{}
+	for _, cookie := range cookies {
+		var expires string
+		if !cookie.Expires.IsZero() {
+			expires = cookie.Expires.UTC().Format(time.RFC3339)
+		}
+		out = append(out, map[string]interface{}{
+			"name":      cookie.Name,
+			"value":     cookie.Value,
+			"domain":    cookie.Domain,
+			"path":      cookie.Path,
+			"expires":   expires,
+			"max_age":   cookie.MaxAge,
+			"secure":    cookie.Secure,
+			"http_only": cookie.HttpOnly,
+			"same_site": sameSiteName(cookie.SameSite),
+		})
+	}
+	return out
+}
+
+func sameSiteName(mode http.SameSite) string {
+	switch mode {
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,46 @@
+package provider
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestFlattenCookies(t *testing.T) {
+	resp := &http.Response{Header: http.Header{"Set-Cookie": {
+		"session=abc123; Path=/api; Domain=example.com; Expires=Wed, 21 Oct 2026 07:28:00 GMT; Secure; HttpOnly; SameSite=Strict",
+		"lang=en; Max-Age=3600",
+	}}}
+
+	got := flattenCookies(resp.Cookies())
+	want := []interface{}{
+		map[string]interface{}{
+			"name":      "session",
+			"value":     "abc123",
+			"domain":    "example.com",
+			"path":      "/api",
+			"expires":   "2026-10-21T07:28:00Z",
+			"max_age":   0,
+			"secure":    true,
+			"http_only": true,
+			"same_site": "Strict",
+		},
+		map[string]interface{}{
+			"name":      "lang",
+			"value":     "en",
+			"domain":    "",
+			"path":      "",
+			"expires":   "",
+			"max_age":   3600,
+			"secure":    false,
+			"http_only": false,
+			"same_site": "",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if cookies := responseCookies(resp); cookies["session"] != "abc123" || cookies["lang"] != "en" {
+		t.Errorf("got response cookies %v", cookies)
+	}
+}
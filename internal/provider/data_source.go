@@ -4,23 +4,42 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
-	"crypto/x509"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"mime"
 	"net/http"
+	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"golang.org/x/net/http/httpguts"
 )
 
+// strictMethods are the only methods accepted with strict_method.
+var strictMethods = []string{http.MethodGet, http.MethodPost, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodPatch, http.MethodOptions, http.MethodTrace}
+
+func isStrictMethod(method string) bool {
+	for _, m := range strictMethods {
+		if method == m {
+			return true
+		}
+	}
+	return false
+}
+
+// validateVerb accepts any method that is a valid token (RFC 9110 section
+// 9.1), so that WebDAV methods such as PROPFIND and MKCOL and vendor
+// extensions can be sent.
 func validateVerb(val interface{}, key string) (warns []string, errs []error) {
 	if v, ok := val.(string); ok {
-		if !(v == http.MethodGet || v == http.MethodPost || v == http.MethodHead || v == http.MethodPatch || v == http.MethodDelete) {
-			errs = append(errs, fmt.Errorf("%s must be GET|POST|HEAD|DELETE|PATCH, got: %s", key, v))
+		if !httpguts.ValidHeaderFieldName(v) {
+			errs = append(errs, fmt.Errorf("%s must be a valid HTTP method, got: %q", key, v))
 		}
 	} else {
 		errs = append(errs, fmt.Errorf("error parsing method"))
@@ -28,6 +47,50 @@ func validateVerb(val interface{}, key string) (warns []string, errs []error) {
 	return
 }
 
+// redirectPolicy returns an http.Client CheckRedirect hook following up to
+// max redirects, or none at all so that the redirect response itself, and
+// its possibly signed Location, is returned.
+func redirectPolicy(follow bool, max int) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if !follow {
+			return http.ErrUseLastResponse
+		}
+		if len(via) > max {
+			return fmt.Errorf("stopped after %d redirects", max)
+		}
+		return nil
+	}
+}
+
+// statusExpected reports whether code is one of expected or, when none are
+// configured, one of the success codes accepted by default.
+func statusExpected(code int, expected []interface{}) bool {
+	if len(expected) == 0 {
+		return code == http.StatusOK || code == http.StatusNoContent ||
+			code == http.StatusAccepted || code == http.StatusCreated
+	}
+	for _, v := range expected {
+		if v.(int) == code {
+			return true
+		}
+	}
+	return false
+}
+
+// authAttributes are the mutually exclusive ways of authenticating a request.
+var authAttributes = []string{"basic_auth", "digest_auth", "ntlm_auth", "kerberos_auth", "netrc", "netrc_file", "bearer_token", "oauth2", "gcp_auth", "azure_auth"}
+
+// conflictingAuth returns the authentication attributes other than self.
+func conflictingAuth(self string) []string {
+	var out []string
+	for _, name := range authAttributes {
+		if name != self {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
 func dataSource() *schema.Resource {
 	return &schema.Resource{
 		ReadContext: dataSourceRead,
@@ -51,6 +114,12 @@ func dataSource() *schema.Resource {
 				ValidateFunc: validateVerb,
 			},
 
+			"strict_method": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
 			"request_headers": {
 				Type:     schema.TypeMap,
 				Optional: true,
@@ -59,6 +128,227 @@ func dataSource() *schema.Resource {
 				},
 			},
 
+			"basic_auth": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MaxItems:      1,
+				ConflictsWith: conflictingAuth("basic_auth"),
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"username": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"password": {
+							Type:      schema.TypeString,
+							Required:  true,
+							Sensitive: true,
+						},
+					},
+				},
+			},
+
+			"digest_auth": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MaxItems:      1,
+				ConflictsWith: conflictingAuth("digest_auth"),
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"username": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"password": {
+							Type:      schema.TypeString,
+							Required:  true,
+							Sensitive: true,
+						},
+					},
+				},
+			},
+
+			"ntlm_auth": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MaxItems:      1,
+				ConflictsWith: conflictingAuth("ntlm_auth"),
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"domain": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"username": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"password": {
+							Type:      schema.TypeString,
+							Required:  true,
+							Sensitive: true,
+						},
+					},
+				},
+			},
+
+			"kerberos_auth": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MaxItems:      1,
+				ConflictsWith: conflictingAuth("kerberos_auth"),
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"ccache": {
+							Type:          schema.TypeString,
+							Optional:      true,
+							ConflictsWith: []string{"kerberos_auth.0.keytab"},
+						},
+						"keytab": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							RequiredWith: []string{"kerberos_auth.0.principal"},
+						},
+						"principal": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							RequiredWith: []string{"kerberos_auth.0.keytab"},
+						},
+						"krb5_conf": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"spn": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+
+			"netrc": {
+				Type:          schema.TypeBool,
+				Optional:      true,
+				ConflictsWith: conflictingAuth("netrc"),
+			},
+
+			"netrc_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: conflictingAuth("netrc_file"),
+			},
+
+			"bearer_token": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				ConflictsWith: conflictingAuth("bearer_token"),
+			},
+
+			"oauth2": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MaxItems:      1,
+				ConflictsWith: conflictingAuth("oauth2"),
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"token_url": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"client_id": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"client_secret": {
+							Type:      schema.TypeString,
+							Required:  true,
+							Sensitive: true,
+						},
+						"scopes": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"endpoint_params": {
+							Type:     schema.TypeMap,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+
+			"gcp_auth": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MaxItems:      1,
+				ConflictsWith: conflictingAuth("gcp_auth"),
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"token_type": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      gcpAccessToken,
+							ValidateFunc: validation.StringInSlice(gcpTokenTypes, false),
+						},
+						"scopes": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"audience": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"credentials": {
+							Type:      schema.TypeString,
+							Optional:  true,
+							Sensitive: true,
+						},
+					},
+				},
+			},
+
+			"azure_auth": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MaxItems:      1,
+				ConflictsWith: conflictingAuth("azure_auth"),
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"resource": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"tenant_id": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"client_id": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"client_secret": {
+							Type:      schema.TypeString,
+							Optional:  true,
+							Sensitive: true,
+						},
+						"authority_host": {
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "https://login.microsoftonline.com",
+						},
+					},
+				},
+			},
+
 			"request_body": {
 				Type:     schema.TypeString,
 				Computed: false,
@@ -68,6 +358,61 @@ func dataSource() *schema.Resource {
 				},
 			},
 
+			"request_body_base64": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ValidateFunc:  validation.StringIsBase64,
+				ConflictsWith: []string{"request_body", "multipart", "request_form", "request_body_json"},
+			},
+
+			"request_form": {
+				Type:          schema.TypeMap,
+				Optional:      true,
+				ConflictsWith: []string{"request_body", "request_body_base64", "multipart", "request_body_json"},
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"request_body_json": {
+				Type:          schema.TypeMap,
+				Optional:      true,
+				ConflictsWith: []string{"request_body", "request_body_base64", "multipart", "request_form"},
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"multipart": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				ConflictsWith: []string{"request_body", "request_form", "request_body_json"},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"field": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"filename": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"content": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"source_file": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"content_type": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+
 			"body": {
 				Type:     schema.TypeString,
 				Computed: true,
@@ -76,6 +421,86 @@ func dataSource() *schema.Resource {
 				},
 			},
 
+			"response_body_base64": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"body_decoded_format": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      decodeFormatAuto,
+				ValidateFunc: validation.StringInSlice(decodeFormats, false),
+			},
+
+			"body_decoded": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"response_body_json": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"jq": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ValidateFunc:  validateJQ,
+				ConflictsWith: []string{"output_file"},
+			},
+
+			"json_extract": {
+				Type:          schema.TypeMap,
+				Optional:      true,
+				ConflictsWith: []string{"output_file"},
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"regex_extract": {
+				Type:          schema.TypeMap,
+				Optional:      true,
+				ValidateFunc:  validateRegexMap,
+				ConflictsWith: []string{"output_file"},
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"outputs": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"sensitive_response": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"sensitive_body": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+
+			"sensitive_outputs": {
+				Type:      schema.TypeMap,
+				Computed:  true,
+				Sensitive: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
 			"response_headers": {
 				Type:     schema.TypeMap,
 				Computed: true,
@@ -83,6 +508,65 @@ func dataSource() *schema.Resource {
 					Type: schema.TypeString,
 				},
 			},
+			"response_cookies": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"set_cookies": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"value": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"domain": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"path": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"expires": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"max_age": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"secure": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"http_only": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"same_site": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+			"status_code": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"final_url": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
 			"ca": {
 				Type:     schema.TypeString,
 				Required: false,
@@ -108,121 +592,1200 @@ func dataSource() *schema.Resource {
 					Type: schema.TypeString,
 				},
 			},
-		},
-	}
-}
 
-func dataSourceRead(ctx context.Context, d *schema.ResourceData, meta interface{}) (diags diag.Diagnostics) {
-	url := d.Get("url").(string)
-	headers := d.Get("request_headers").(map[string]interface{})
+			"ca_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"ca"},
+			},
 
-	tlsConfig := &tls.Config{}
+			"include_system_ca": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
 
-	castr, ok := d.GetOk("ca")
-	if ok {
-		caCertPool := x509.NewCertPool()
-		caCertPool.AppendCertsFromPEM([]byte(castr.(string)))
-		tlsConfig.RootCAs = caCertPool
-	}
+			"tls_min_version": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice(tlsVersionNames, false),
+			},
 
-	client_crt, ok := d.GetOk("client_crt")
-	if ok {
-		client_key, ok := d.GetOk("client_key")
-		if !ok {
-			return append(diags, diag.Errorf("Both client_crt and client_key must be specified")...)
-		}
-		clientCerts, err := tls.X509KeyPair(
-			[]byte(client_crt.(string)),
-			[]byte(client_key.(string)),
-		)
-		if err != nil {
-			return append(diags, diag.Errorf("Error loading client certificates: %s", err)...)
-		}
-		tlsConfig.Certificates = []tls.Certificate{clientCerts}
-	}
+			"tls_max_version": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice(tlsVersionNames, false),
+			},
 
-	tr := &http.Transport{
-		TLSClientConfig: tlsConfig,
-	}
-	client := &http.Client{Transport: tr}
+			"tls_server_name": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
 
-	verb := http.MethodGet
+			"tls_cipher_suites": tlsCipherSuitesSchema(),
 
-	method_override, ok := d.GetOk("method")
-	if ok {
-		if verb, ok = method_override.(string); !ok {
-			return append(diags, diag.Errorf("Error overring verb")...)
-		}
-	}
+			"tls_curve_preferences": tlsCurvePreferencesSchema(),
 
-	var body io.Reader
-	b, ok := d.GetOk("request_body")
-	if ok {
-		verb = http.MethodPost
-		if method_override != nil {
-			if verb, ok = method_override.(string); !ok {
-				return append(diags, diag.Errorf("Error overring verb")...)
-			}
-		}
-		body = bytes.NewReader([]byte(b.(string)))
-	}
+			"client_crt_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"client_crt"},
+			},
 
-	req, err := http.NewRequestWithContext(ctx, verb, url, body)
-	if err != nil {
-		return append(diags, diag.Errorf("Error creating request: %s", err)...)
-	}
+			"client_key_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"client_key"},
+			},
 
-	for name, value := range headers {
-		req.Header.Set(name, value.(string))
-	}
+			"client_key_password": {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return append(diags, diag.Errorf("Error making request: %s", err)...)
-	}
+			"client_pkcs12": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				ConflictsWith: []string{"client_pkcs12_file", "client_crt", "client_key", "client_crt_file", "client_key_file"},
+			},
 
-	defer resp.Body.Close()
+			"client_pkcs12_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"client_crt", "client_key", "client_crt_file", "client_key_file"},
+			},
 
-	// TODO, check if the response code is valid for the verb sent in...
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent &&
-		resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusCreated {
-		bytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return append(diags, diag.Errorf("HTTP request error. Response code: %d", resp.StatusCode)...)
-		}
-		return append(diags, diag.Errorf("HTTP request error. Response code: %d,  Error Response body: %s", resp.StatusCode, string(bytes))...)
-	}
+			"pkcs12_password": {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
 
-	contentType := resp.Header.Get("Content-Type")
-	if contentType == "" || isContentTypeText(contentType) == false {
-		diags = append(diags, diag.Diagnostic{
-			Severity: diag.Warning,
-			Summary:  fmt.Sprintf("Content-Type is not recognized as a text type, got %q", contentType),
-			Detail:   "If the content is binary data, Terraform may not properly handle the contents of the response.",
-		})
-	}
+			"pkcs11": pkcs11Schema(),
 
-	bytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return append(diags, diag.FromErr(err)...)
-	}
+			"client_certificates": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				ConflictsWith: []string{"client_crt", "client_key", "client_crt_file", "client_key_file", "client_pkcs12", "client_pkcs12_file"},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"client_crt": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"client_key": {
+							Type:      schema.TypeString,
+							Required:  true,
+							Sensitive: true,
+						},
+					},
+				},
+			},
 
-	responseHeaders := make(map[string]string)
-	for k, v := range resp.Header {
-		// Concatenate according to RFC2616
-		// cf. https://www.w3.org/Protocols/rfc2616/rfc2616-sec4.html#sec4.2
-		responseHeaders[k] = strings.Join(v, ", ")
-	}
+			"http_version": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice(httpVersions, false),
+			},
 
-	d.Set("body", string(bytes))
-	if err = d.Set("response_headers", responseHeaders); err != nil {
-		return append(diags, diag.Errorf("Error setting HTTP response headers: %s", err)...)
-	}
+			"h2c": {
+				Type:          schema.TypeBool,
+				Optional:      true,
+				Default:       false,
+				ConflictsWith: []string{"proxy_url", "proxy_pac_url", "proxy_pac_file"},
+			},
 
-	// set ID as something more stable than time
-	d.SetId(url)
+			"unix_socket": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"proxy_url", "proxy_pac_url", "proxy_pac_file", "dns_servers", "connect_to"},
+			},
 
-	return diags
+			"named_pipe": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"unix_socket", "proxy_url", "proxy_pac_url", "proxy_pac_file", "dns_servers", "connect_to"},
+			},
+
+			"dns_servers": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateDNSServer,
+				},
+			},
+
+			"connect_to": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"proxy_url": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ValidateFunc:  validation.IsURLWithScheme(proxySchemes),
+				ConflictsWith: []string{"proxy_pac_url", "proxy_pac_file"},
+			},
+
+			"proxy_pac_url": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"proxy_pac_file"},
+			},
+
+			"proxy_pac_file": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"skip_hostname_verification": {
+				Type:          schema.TypeBool,
+				Optional:      true,
+				Default:       false,
+				ConflictsWith: []string{"spiffe_peer_id"},
+			},
+
+			"insecure": {
+				Type:          schema.TypeBool,
+				Optional:      true,
+				Default:       false,
+				ConflictsWith: []string{"ca", "ca_file", "skip_hostname_verification", "spiffe_peer_id"},
+			},
+
+			"ocsp": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "off",
+				ValidateFunc: validation.StringInSlice(ocspModes, false),
+			},
+
+			"pinned_spki_sha256": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateSPKIPin,
+				},
+			},
+
+			"spiffe_socket_path": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"client_crt", "client_key", "client_crt_file", "client_key_file", "client_pkcs12", "client_pkcs12_file", "client_certificates", "pkcs11"},
+			},
+			"spiffe_id": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"spiffe_socket_path"},
+			},
+			"spiffe_peer_id": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"spiffe_socket_path"},
+			},
+
+			"paginate": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"mode": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringInSlice(paginateModes, false),
+						},
+						"max_pages": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      100,
+							ValidateFunc: validateNonNegative,
+						},
+						"cursor_path": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"cursor_param": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"items_path": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+
+			"page_count": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+
+			"resolved_addresses": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"resolved_address": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"negotiated_protocol": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"tls_version": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"tls_cipher": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"server_certificates": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"subject": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"issuer": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"serial_number": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"sans": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"not_before": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"not_after": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"is_ca": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"sha256_fingerprint": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"spki_sha256": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"pem": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+
+			"openapi": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"spec_url": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ExactlyOneOf: []string{"openapi.0.spec_url", "openapi.0.spec"},
+						},
+						"spec": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ExactlyOneOf: []string{"openapi.0.spec_url", "openapi.0.spec"},
+						},
+						"operation_id": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+					},
+				},
+			},
+
+			"sign_command": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"expected_status_codes": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IntBetween(100, 599),
+				},
+			},
+
+			"follow_redirects": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  true,
+			},
+
+			"max_redirects": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      10,
+				ValidateFunc: validateNonNegative,
+			},
+
+			"retry": retrySchema(),
+
+			"request_timeout_ms": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validateNonNegative,
+			},
+
+			"wait_for": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"timeout_ms": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      300000,
+							ValidateFunc: validateNonNegative,
+						},
+						"interval_ms": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      5000,
+							ValidateFunc: validateNonNegative,
+						},
+					},
+				},
+			},
+
+			"async": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"follow_location": {
+							Type:     schema.TypeBool,
+							Optional: true,
+							Default:  true,
+						},
+						"poll_interval_ms": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      5000,
+							ValidateFunc: validateNonNegative,
+						},
+						"timeout_ms": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      1800000,
+							ValidateFunc: validateNonNegative,
+						},
+						"success_jsonpath": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"success_values": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"failure_values": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+
+			"body_digest": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringInSlice(digestHeaders, false),
+				},
+			},
+
+			"body_digest_algorithm": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "sha-256",
+				ValidateFunc: validation.StringInSlice(digestAlgorithms, false),
+			},
+
+			"http_signature": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"scheme": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      signatureSchemeRFC9421,
+							ValidateFunc: validation.StringInSlice([]string{signatureSchemeRFC9421, signatureSchemeCavage}, false),
+						},
+						"key_id": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"key": {
+							Type:      schema.TypeString,
+							Required:  true,
+							Sensitive: true,
+						},
+						"algorithm": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validateSignatureAlgorithm,
+						},
+						"components": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"label": {
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "sig1",
+						},
+						"use_authorization_header": {
+							Type:     schema.TypeBool,
+							Optional: true,
+							Default:  false,
+						},
+					},
+				},
+			},
+
+			"dry_run": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"max_decompressed_bytes": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      defaultMaxDecompressedBytes,
+				ValidateFunc: validateNonNegative,
+			},
+
+			"max_response_body_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      defaultMaxResponseBodySize,
+				ValidateFunc: validateNonNegative,
+			},
+
+			"output_file": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"extract": {
+				Type:         schema.TypeList,
+				Optional:     true,
+				MaxItems:     1,
+				RequiredWith: []string{"output_file"},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"archive": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringInSlice(archiveFormats, false),
+						},
+						"member": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+					},
+				},
+			},
+
+			"output_sha256": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"debug": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"debug_redact_headers": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"capture_request": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"captured_request": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"dry_run_request": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"method": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"url": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"headers": {
+							Type:      schema.TypeMap,
+							Computed:  true,
+							Sensitive: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"body": {
+							Type:      schema.TypeString,
+							Computed:  true,
+							Sensitive: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceRead(ctx context.Context, d *schema.ResourceData, meta interface{}) (diags diag.Diagnostics) {
+	config := configFromMeta(meta)
+	url := config.resolveURL(d.Get("url").(string))
+
+	tlsConfig, diags := newTLSConfig(ctx, d, config)
+	if diags.HasError() {
+		return diags
+	}
+
+	ctx = withRecordingPolicy(ctx, recordingPolicy{
+		omitBody: d.Get("sensitive_response").(bool),
+		limit:    int64(d.Get("max_response_body_size").(int)),
+	})
+	req, reqBody, reqDiags := newRequest(ctx, d, config)
+	diags = append(diags, reqDiags...)
+	if diags.HasError() {
+		return diags
+	}
+
+	dns := newDNSRecorder(req.URL.Hostname())
+	if v, ok := d.GetOk("dns_servers"); ok {
+		var servers []string
+		for _, server := range v.([]interface{}) {
+			servers = append(servers, server.(string))
+		}
+		dns.useDNSServers(servers)
+	}
+	for k, v := range d.Get("connect_to").(map[string]interface{}) {
+		if dns.connectTo == nil {
+			dns.connectTo = map[string]string{}
+		}
+		dns.connectTo[k] = v.(string)
+	}
+	tr := &http.Transport{
+		TLSClientConfig:    tlsConfig,
+		DialContext:        dns.dialContext,
+		DisableCompression: true,
+	}
+	// The provider's proxy applies unless this data source sets its own
+	// or talks to a local socket or pipe.
+	pacURL, pacFile := d.Get("proxy_pac_url").(string), d.Get("proxy_pac_file").(string)
+	proxyURL := d.Get("proxy_url").(string)
+	switch {
+	case d.Get("unix_socket").(string) != "":
+		tr.DialContext = dialUnix(expandHome(d.Get("unix_socket").(string)))
+	case d.Get("named_pipe").(string) != "":
+		dial, err := dialPipe(d.Get("named_pipe").(string))
+		if err != nil {
+			return append(diags, diag.Errorf("Error configuring named pipe: %s", err)...)
+		}
+		tr.DialContext = dial
+	case proxyURL != "":
+		if err := configureProxy(tr, dns, proxyURL); err != nil {
+			return append(diags, diag.Errorf("Error configuring proxy: %s", err)...)
+		}
+	case pacURL != "" || pacFile != "":
+		pac, err := loadPACScript(ctx, pacURL, pacFile)
+		if err != nil {
+			return append(diags, diag.Errorf("Error loading proxy auto-config: %s", err)...)
+		}
+		tr.Proxy = pac.proxy
+	default:
+		if err := config.applyDefaultProxy(tr, dns); err != nil {
+			return append(diags, diag.Errorf("Error configuring proxy: %s", err)...)
+		}
+		proxyURL = config.proxyURL
+	}
+	if dns.connectTo != nil {
+		if err := refuseRemoteResolution(tr, proxyURL); err != nil {
+			return append(diags, diag.Errorf("Error configuring connect_to: %s", err)...)
+		}
+	}
+	httpVersion := config.httpVersion
+	if v, ok := d.GetOk("http_version"); ok {
+		httpVersion = v.(string)
+	}
+	applyHTTPVersion(tr, httpVersion)
+	decompress := &decompressTransport{next: tr, limit: int64(d.Get("max_decompressed_bytes").(int))}
+	if d.Get("h2c").(bool) {
+		decompress.next = newH2CTransport(ctx, tr)
+	}
+	var capture *captureTransport
+	if d.Get("capture_request").(bool) {
+		capture = &captureTransport{next: decompress.next}
+		decompress.next = capture
+	}
+	client := &http.Client{Transport: config.wrapTransport(decompress), Timeout: config.requestTimeout}
+	if d.Get("debug").(bool) {
+		var redact []string
+		for _, name := range d.Get("debug_redact_headers").([]interface{}) {
+			redact = append(redact, name.(string))
+		}
+		client.Transport = &debugTransport{next: client.Transport, redact: redact}
+	}
+	// Outside the debug logging, so that both the challenge and the
+	// authenticated request are logged.
+	if v, ok := d.GetOk("digest_auth"); ok {
+		auth := v.([]interface{})[0].(map[string]interface{})
+		client.Transport = &digestAuthTransport{
+			next:     client.Transport,
+			username: auth["username"].(string),
+			password: auth["password"].(string),
+		}
+	}
+	if v, ok := d.GetOk("kerberos_auth"); ok {
+		auth := v.([]interface{})[0].(map[string]interface{})
+		krb, err := newKerberosClient(auth)
+		if err != nil {
+			return append(diags, diag.Errorf("Error configuring Kerberos: %s", err)...)
+		}
+		client.Transport = &spnegoTransport{next: client.Transport, client: krb, spn: auth["spn"].(string)}
+	}
+	if v, ok := d.GetOk("ntlm_auth"); ok {
+		auth := v.([]interface{})[0].(map[string]interface{})
+		client.Transport = &ntlmTransport{
+			next:     client.Transport,
+			domain:   auth["domain"].(string),
+			username: auth["username"].(string),
+			password: auth["password"].(string),
+		}
+	}
+	if v, ok := d.GetOk("request_timeout_ms"); ok {
+		client.Timeout = time.Duration(v.(int)) * time.Millisecond
+	}
+	client.CheckRedirect = redirectPolicy(d.Get("follow_redirects").(bool), d.Get("max_redirects").(int))
+
+	if d.Get("dry_run").(bool) {
+		if d.Get("capture_request").(bool) {
+			captured, err := captureRequest(withAcceptEncoding(req), reqBody)
+			if err != nil {
+				return append(diags, diag.Errorf("Error capturing request: %s", err)...)
+			}
+			d.Set("captured_request", captured)
+		}
+		if err := d.Set("dry_run_request", flattenRequest(req, reqBody)); err != nil {
+			return append(diags, diag.Errorf("Error setting dry run request: %s", err)...)
+		}
+		d.SetId(url)
+		return diags
+	}
+
+	policy := config.retryPolicy
+	if p := expandRetryPolicy(d.Get("retry")); p != nil {
+		policy = p
+	}
+	var resp *http.Response
+	var err error
+	if v, ok := d.GetOk("wait_for"); ok {
+		wait := v.([]interface{})[0].(map[string]interface{})
+		timeout := time.Duration(wait["timeout_ms"].(int)) * time.Millisecond
+		interval := time.Duration(wait["interval_ms"].(int)) * time.Millisecond
+		resp, err = waitFor(ctx, client, req, policy, config.retryBudget, d.Get("expected_status_codes").([]interface{}), timeout, interval)
+	} else {
+		resp, err = doWithRetry(ctx, client, req, policy, config.retryBudget)
+		// A shared or cached token may have been revoked before it
+		// expired: it is dropped and the request sent once more with a
+		// new one.
+		if err == nil && resp.StatusCode == http.StatusUnauthorized && usesStoredToken(d) {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+			config.tokens.reject(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "))
+			if req, _, reqDiags = newRequest(ctx, d, config); reqDiags.HasError() {
+				return append(diags, reqDiags...)
+			}
+			resp, err = doWithRetry(ctx, client, req, policy, config.retryBudget)
+		}
+	}
+	if err != nil {
+		return append(diags, diag.Errorf("Error making request: %s", err)...)
+	}
+	// Before polling or following pages, which go through the same client.
+	if capture != nil {
+		captured, err := capture.result()
+		if err != nil {
+			resp.Body.Close()
+			return append(diags, diag.Errorf("Error capturing request: %s", err)...)
+		}
+		d.Set("captured_request", captured)
+	}
+	maxBodySize := int64(d.Get("max_response_body_size").(int))
+	if v, ok := d.GetOk("async"); ok && resp.StatusCode == http.StatusAccepted {
+		if resp, err = pollOperation(ctx, client, req, resp, policy, config.retryBudget, v.([]interface{})[0].(map[string]interface{}), maxBodySize); err != nil {
+			return append(diags, diag.Errorf("Error polling operation: %s", err)...)
+		}
+	}
+
+	defer resp.Body.Close()
+
+	// TODO, check if the response code is valid for the verb sent in...
+	if !statusExpected(resp.StatusCode, d.Get("expected_status_codes").([]interface{})) {
+		bytes, err := readBody(resp, maxBodySize)
+		if err != nil {
+			return append(diags, diag.Errorf("HTTP request error. Response code: %d", resp.StatusCode)...)
+		}
+		if d.Get("sensitive_response").(bool) {
+			return append(diags, diag.Errorf("HTTP request error. Response code: %d", resp.StatusCode)...)
+		}
+		return append(diags, diag.Errorf("HTTP request error. Response code: %d,  Error Response body: %s", resp.StatusCode, string(bytes))...)
+	}
+
+	responseHeaders := make(map[string]string)
+	for k, v := range resp.Header {
+		// Concatenate according to RFC2616
+		// cf. https://www.w3.org/Protocols/rfc2616/rfc2616-sec4.html#sec4.2
+		responseHeaders[k] = strings.Join(v, ", ")
+	}
+	if err = d.Set("response_headers", responseHeaders); err != nil {
+		return append(diags, diag.Errorf("Error setting HTTP response headers: %s", err)...)
+	}
+	d.Set("response_cookies", responseCookies(resp))
+	d.Set("set_cookies", flattenCookies(resp.Cookies()))
+	d.Set("status_code", resp.StatusCode)
+	d.Set("final_url", resp.Request.URL.String())
+	addresses, used := dns.result()
+	d.Set("resolved_addresses", addresses)
+	d.Set("resolved_address", used)
+	d.Set("server_certificates", flattenCertificates(resp.TLS))
+	d.Set("negotiated_protocol", negotiatedProtocol(resp))
+	if resp.TLS != nil {
+		d.Set("tls_version", tlsVersionName(resp.TLS.Version))
+		d.Set("tls_cipher", tls.CipherSuiteName(resp.TLS.CipherSuite))
+	} else {
+		d.Set("tls_version", "")
+		d.Set("tls_cipher", "")
+	}
+
+	outputFile := d.Get("output_file").(string)
+	contentType := resp.Header.Get("Content-Type")
+	if outputFile == "" && (contentType == "" || isContentTypeText(contentType) == false) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Content-Type is not recognized as a text type, got %q", contentType),
+			Detail:   "If the content is binary data, Terraform may not properly handle the contents of the response; use response_body_base64 instead of body.",
+		})
+	}
+
+	// Downloads are streamed to disk rather than kept in state, so they
+	// aren't limited by max_response_body_size.
+	if outputFile != "" {
+		sum, err := writeOutputFile(outputFile, func(w io.Writer) (os.FileMode, error) {
+			v, ok := d.GetOk("extract")
+			if !ok {
+				_, err := io.Copy(w, resp.Body)
+				return 0644, err
+			}
+			extract := v.([]interface{})[0].(map[string]interface{})
+			mode, err := extractMember(w, resp.Body, extract["archive"].(string), extract["member"].(string), int64(d.Get("max_decompressed_bytes").(int)))
+			if err != nil {
+				return 0, fmt.Errorf("extracting %s: %s", extract["member"], err)
+			}
+			return mode, nil
+		})
+		if err != nil {
+			return append(diags, diag.Errorf("Error writing %s: %s", outputFile, err)...)
+		}
+		d.Set("output_sha256", sum)
+		d.Set("body", "")
+		d.Set("response_body_base64", "")
+		d.Set("response_body_json", nil)
+		d.Set("outputs", nil)
+		d.Set("page_count", 1)
+		d.SetId(url)
+		return diags
+	}
+
+	bytes, err := readBody(resp, maxBodySize)
+	if err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	pages := 1
+	if v, ok := d.GetOk("paginate"); ok {
+		result, err := paginate(ctx, client, req, resp.Header, bytes, v.([]interface{})[0].(map[string]interface{}), policy, config.retryBudget, maxBodySize)
+		if err != nil {
+			return append(diags, diag.Errorf("Error following pagination: %s", err)...)
+		}
+		if result.truncated {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Pagination stopped after %d pages", result.pages),
+				Detail:   "The response has more pages than paginate.max_pages allows; the body only contains the items fetched so far.",
+			})
+		}
+		bytes, pages = result.body, result.pages
+	}
+
+	if v, ok := d.GetOk("openapi"); ok {
+		openapi := v.([]interface{})[0].(map[string]interface{})
+		if err := validateOpenAPIResponse(ctx, client, openapi, resp.StatusCode, bytes); err != nil {
+			return append(diags, diag.Errorf("Error validating response: %s", err)...)
+		}
+	}
+
+	if v, ok := d.GetOk("jq"); ok {
+		if bytes, err = applyJQ(ctx, v.(string), bytes); err != nil {
+			return append(diags, diag.Errorf("Error applying jq: %s", err)...)
+		}
+		contentType = "application/json"
+	}
+
+	format := d.Get("body_decoded_format").(string)
+	decoded, err := decodeBody(contentType, format, bytes)
+	if err != nil {
+		if format != decodeFormatAuto {
+			return append(diags, diag.Errorf("Error decoding response body: %s", err)...)
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Response body could not be decoded",
+			Detail:   fmt.Sprintf("body_decoded is left empty: %s", err),
+		})
+	}
+
+	outputs := map[string]string{}
+	if v, ok := d.GetOk("json_extract"); ok {
+		if outputs, err = jsonExtract(bytes, v.(map[string]interface{})); err != nil {
+			return append(diags, diag.Errorf("Error extracting JSON values: %s", err)...)
+		}
+	}
+	if v, ok := d.GetOk("regex_extract"); ok {
+		if err := regexExtract(bytes, v.(map[string]interface{}), outputs); err != nil {
+			return append(diags, diag.Errorf("Error extracting values: %s", err)...)
+		}
+	}
+
+	// Attributes can't be made sensitive on demand, so a sensitive response
+	// goes to attributes that always are.
+	if d.Get("sensitive_response").(bool) {
+		d.Set("sensitive_body", string(bytes))
+		d.Set("sensitive_outputs", outputs)
+		d.Set("body", "")
+		d.Set("response_body_base64", "")
+		d.Set("body_decoded", "")
+		d.Set("response_body_json", nil)
+		d.Set("outputs", nil)
+	} else {
+		d.Set("sensitive_body", "")
+		d.Set("sensitive_outputs", nil)
+		d.Set("body", string(bytes))
+		d.Set("response_body_base64", base64.StdEncoding.EncodeToString(bytes))
+		d.Set("body_decoded", decoded)
+		d.Set("response_body_json", jsonFields(contentType, bytes))
+		d.Set("outputs", outputs)
+	}
+	d.Set("page_count", pages)
+
+	// set ID as something more stable than time
+	d.SetId(url)
+
+	return diags
+}
+
+// usesStoredToken reports whether the request authenticates with a token
+// from the provider's token store.
+func usesStoredToken(d *schema.ResourceData) bool {
+	for _, name := range []string{"oauth2", "gcp_auth", "azure_auth"} {
+		if _, ok := d.GetOk(name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// newRequest assembles the outgoing request described by d along with a copy
+// of its body, validating everything that can be checked without sending it.
+func newRequest(ctx context.Context, d *schema.ResourceData, config *providerConfig) (*http.Request, []byte, diag.Diagnostics) {
+	url := config.resolveURL(d.Get("url").(string))
+	headers := d.Get("request_headers").(map[string]interface{})
+
+	verb := http.MethodGet
+
+	method_override, ok := d.GetOk("method")
+	if ok {
+		if verb, ok = method_override.(string); !ok {
+			return nil, nil, diag.Errorf("Error overring verb")
+		}
+	}
+
+	var reqBody []byte
+	var body io.Reader
+	b, ok := d.GetOk("request_body")
+	b64, isBase64 := d.GetOk("request_body_base64")
+	parts, isMultipart := d.GetOk("multipart")
+	form, isForm := d.GetOk("request_form")
+	object, isJSON := d.GetOk("request_body_json")
+	var contentType string
+	if ok || isBase64 || isMultipart || isForm || isJSON {
+		verb = http.MethodPost
+		if method_override != nil {
+			if verb, ok = method_override.(string); !ok {
+				return nil, nil, diag.Errorf("Error overring verb")
+			}
+		}
+		if isMultipart {
+			var err error
+			if reqBody, contentType, err = multipartBody(parts.([]interface{})); err != nil {
+				return nil, nil, diag.Errorf("Error encoding multipart body: %s", err)
+			}
+		} else if isForm {
+			reqBody = formBody(form.(map[string]interface{}))
+			contentType = "application/x-www-form-urlencoded"
+		} else if isJSON {
+			var err error
+			if reqBody, err = jsonBody(object.(map[string]interface{})); err != nil {
+				return nil, nil, diag.Errorf("Error encoding request_body_json: %s", err)
+			}
+			contentType = "application/json"
+		} else if isBase64 {
+			var err error
+			if reqBody, err = base64.StdEncoding.DecodeString(b64.(string)); err != nil {
+				return nil, nil, diag.Errorf("Error decoding request_body_base64: %s", err)
+			}
+		} else {
+			reqBody = []byte(b.(string))
+		}
+		body = bytes.NewReader(reqBody)
+	}
+
+	if d.Get("strict_method").(bool) && !isStrictMethod(verb) {
+		return nil, nil, diag.Errorf("Error creating request: method must be %s with strict_method, got: %s", strings.Join(strictMethods, "|"), verb)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, verb, url, body)
+	if err != nil {
+		return nil, nil, diag.Errorf("Error creating request: %s", err)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, nil, diag.Errorf("Error creating request: unsupported URL scheme %q", req.URL.Scheme)
+	}
+	if req.URL.Host == "" {
+		return nil, nil, diag.Errorf("Error creating request: URL %q has no host", url)
+	}
+
+	// The provider's default headers come first so that the data source's
+	// own replace them.
+	for _, headers := range []map[string]interface{}{config.defaultHeaders, headers} {
+		for name, value := range headers {
+			if !httpguts.ValidHeaderFieldName(name) {
+				return nil, nil, diag.Errorf("Error creating request: invalid header name %q", name)
+			}
+			if !httpguts.ValidHeaderFieldValue(value.(string)) {
+				return nil, nil, diag.Errorf("Error creating request: invalid value for header %q", name)
+			}
+			req.Header.Set(name, value.(string))
+		}
+	}
+	// The encoding of multipart, form and JSON bodies is only known here, so
+	// it overrides any configured type.
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if v, ok := d.GetOk("basic_auth"); ok {
+		auth := v.([]interface{})[0].(map[string]interface{})
+		req.SetBasicAuth(auth["username"].(string), auth["password"].(string))
+	}
+	if path, ok := d.GetOk("netrc_file"); ok || d.Get("netrc").(bool) {
+		if !ok {
+			path = defaultNetrcPath
+		}
+		login, password, found, err := netrcCredentials(path.(string), req.URL.Hostname(), ok)
+		if err != nil {
+			return nil, nil, diag.Errorf("Error reading netrc file: %s", err)
+		}
+		if found {
+			req.SetBasicAuth(login, password)
+		}
+	}
+	if v, ok := d.GetOk("bearer_token"); ok {
+		req.Header.Set("Authorization", "Bearer "+v.(string))
+	}
+	if v, ok := d.GetOk("oauth2"); ok {
+		client, err := tokenClient(ctx, d, config)
+		if err != nil {
+			return nil, nil, diag.Errorf("Error obtaining OAuth2 token: %s", err)
+		}
+		token, err := oauth2Token(ctx, config, client, v.([]interface{})[0].(map[string]interface{}))
+		if err != nil {
+			return nil, nil, diag.Errorf("Error obtaining OAuth2 token: %s", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if v, ok := d.GetOk("gcp_auth"); ok {
+		client, err := tokenClient(ctx, d, config)
+		if err != nil {
+			return nil, nil, diag.Errorf("Error obtaining Google Cloud token: %s", err)
+		}
+		token, err := gcpToken(ctx, config, client, v.([]interface{})[0].(map[string]interface{}), url)
+		if err != nil {
+			return nil, nil, diag.Errorf("Error obtaining Google Cloud token: %s", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if v, ok := d.GetOk("azure_auth"); ok {
+		client, err := tokenClient(ctx, d, config)
+		if err != nil {
+			return nil, nil, diag.Errorf("Error obtaining Azure AD token: %s", err)
+		}
+		token, err := azureToken(ctx, config, client, v.([]interface{})[0].(map[string]interface{}))
+		if err != nil {
+			return nil, nil, diag.Errorf("Error obtaining Azure AD token: %s", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	// Signing must come last so that it covers the request as sent.
+	if v, ok := d.GetOk("body_digest"); ok {
+		var headers []string
+		for _, header := range v.([]interface{}) {
+			headers = append(headers, header.(string))
+		}
+		if err := setBodyDigests(req, reqBody, headers, d.Get("body_digest_algorithm").(string)); err != nil {
+			return nil, nil, diag.Errorf("Error computing body digest: %s", err)
+		}
+	}
+
+	if v, ok := d.GetOk("http_signature"); ok {
+		signer, err := newHTTPSigner(v.([]interface{})[0].(map[string]interface{}))
+		if err != nil {
+			return nil, nil, diag.Errorf("Error loading signing key: %s", err)
+		}
+		if err := signer.sign(req, reqBody); err != nil {
+			return nil, nil, diag.Errorf("Error signing request: %s", err)
+		}
+	}
+
+	if v, ok := d.GetOk("sign_command"); ok {
+		var command []string
+		for _, arg := range v.([]interface{}) {
+			command = append(command, arg.(string))
+		}
+		if err := runSignCommand(ctx, command, req, reqBody); err != nil {
+			return nil, nil, diag.Errorf("Error signing request: %s", err)
+		}
+	}
+
+	return req, reqBody, nil
+}
+
+// flattenRequest describes req in the shape of the dry_run_request attribute.
+func flattenRequest(req *http.Request, body []byte) []interface{} {
+	headers := make(map[string]interface{})
+	for k, v := range req.Header {
+		headers[k] = strings.Join(v, ", ")
+	}
+
+	return []interface{}{
+		map[string]interface{}{
+			"method":  req.Method,
+			"url":     req.URL.String(),
+			"headers": headers,
+			"body":    string(body),
+		},
+	}
+}
+
+// defaultMaxResponseBodySize bounds the response bodies stored in state
+// unless configured otherwise.
+const defaultMaxResponseBodySize = 10 << 20
+
+// readBody reads the body of resp, failing once it is larger than limit
+// bytes, or right away when Content-Length says so. A limit of 0 disables
+// the check.
+func readBody(resp *http.Response, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return ioutil.ReadAll(resp.Body)
+	}
+	if resp.ContentLength > limit {
+		return nil, fmt.Errorf("response body of %d bytes exceeds max_response_body_size (%d bytes)", resp.ContentLength, limit)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("response body exceeds max_response_body_size (%d bytes)", limit)
+	}
+	return body, nil
 }
 
 // This is to prevent potential issues w/ binary files
@@ -1,19 +1,27 @@
 package provider
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 type TestHttpMock struct {
-	server *httptest.Server
+	server   *httptest.Server
+	requests int32
 }
 
 const testDataSourceConfig_basic = `
@@ -94,13 +102,512 @@ func TestDataSource_http404(t *testing.T) {
 	})
 }
 
+const testDataSourceConfig_expectedStatusCodes = `
+data "http" "http_test" {
+  url = "%s/meta_404.txt"
+
+  expected_status_codes = [200, 404]
+}
+
+output "body" {
+  value = data.http.http_test.body
+}
+
+output "status_code" {
+  value = data.http.http_test.status_code
+}
+`
+
+func TestDataSource_expectedStatusCodes(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testDataSourceConfig_expectedStatusCodes, testHttpMock.server.URL),
+				Check: func(s *terraform.State) error {
+					outputs := s.RootModule().Outputs
+
+					if outputs["body"].Value != "" {
+						return fmt.Errorf(
+							`'body' output is %s; want ''`,
+							outputs["body"].Value,
+						)
+					}
+
+					if outputs["status_code"].Value != "404" {
+						return fmt.Errorf(
+							`'status_code' output is %v; want '404'`,
+							outputs["status_code"].Value,
+						)
+					}
+
+					return nil
+				},
+			},
+		},
+	})
+}
+
 const testDataSourceConfig_basic_error_with_body = `
 data "http" "http_test" {
-  url = "%s/errorwithbody"
+  url = "%s/errorwithbody"
+}
+
+output "body" {
+  value = data.http.http_test.body
+}
+
+output "response_headers" {
+  value = data.http.http_test.response_headers
+}
+`
+
+func TestDataSource_httperrorwithbody(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(testDataSourceConfig_basic_error_with_body, testHttpMock.server.URL),
+				ExpectError: regexp.MustCompile("HTTP request error. Response code: 500,  Error Response body: ruh-roh"),
+			},
+		},
+	})
+}
+
+const testDataSourceConfig_retry = `
+data "http" "http_test" {
+  url = "%s/flaky"
+
+  retry {
+    attempts     = 4
+    min_delay_ms = 1
+    max_delay_ms = 1
+  }
+}
+
+output "body" {
+  value = data.http.http_test.body
+}
+`
+
+func TestDataSource_retry(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testDataSourceConfig_retry, testHttpMock.server.URL),
+				Check: func(s *terraform.State) error {
+					outputs := s.RootModule().Outputs
+
+					if outputs["body"].Value != "1.0.0" {
+						return fmt.Errorf(
+							`'body' output is %s; want '1.0.0'`,
+							outputs["body"].Value,
+						)
+					}
+
+					return nil
+				},
+			},
+		},
+	})
+}
+
+const testDataSourceConfig_timeout = `
+data "http" "http_test" {
+  url = "%s/slow"
+
+  request_timeout_ms = 50
+
+  retry {
+    attempts = 1
+  }
+}
+`
+
+func TestDataSource_timeout(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(testDataSourceConfig_timeout, testHttpMock.server.URL),
+				ExpectError: regexp.MustCompile("Client.Timeout exceeded"),
+			},
+		},
+	})
+}
+
+const testDataSourceConfig_basicAuth = `
+data "http" "http_test" {
+  url = "%s/restricted/meta_%d.txt"
+
+  basic_auth {
+    username = "foo"
+    password = "bar"
+  }
+}
+
+output "body" {
+  value = data.http.http_test.body
+}
+`
+
+func TestDataSource_basicAuth(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testDataSourceConfig_basicAuth, testHttpMock.server.URL, 200),
+				Check: func(s *terraform.State) error {
+					outputs := s.RootModule().Outputs
+
+					if outputs["body"].Value != "1.0.0" {
+						return fmt.Errorf(
+							`'body' output is %s; want '1.0.0'`,
+							outputs["body"].Value,
+						)
+					}
+
+					return nil
+				},
+			},
+		},
+	})
+}
+
+const testDataSourceConfig_bearerToken = `
+data "http" "http_test" {
+  url = "%s/restricted/meta_%d.txt"
+
+  bearer_token = "Zm9vOmJhcg=="
+}
+
+output "body" {
+  value = data.http.http_test.body
+}
+`
+
+func TestDataSource_bearerToken(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testDataSourceConfig_bearerToken, testHttpMock.server.URL, 200),
+				Check: func(s *terraform.State) error {
+					outputs := s.RootModule().Outputs
+
+					if outputs["body"].Value != "1.0.0" {
+						return fmt.Errorf(
+							`'body' output is %s; want '1.0.0'`,
+							outputs["body"].Value,
+						)
+					}
+
+					return nil
+				},
+			},
+		},
+	})
+}
+
+const testDataSourceConfig_finalURL = `
+data "http" "http_test" {
+  url = "%s/redirect"
+}
+
+output "body" {
+  value = data.http.http_test.body
+}
+
+output "final_url" {
+  value = data.http.http_test.final_url
+}
+`
+
+func TestDataSource_finalURL(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testDataSourceConfig_finalURL, testHttpMock.server.URL),
+				Check: func(s *terraform.State) error {
+					outputs := s.RootModule().Outputs
+
+					if outputs["body"].Value != "1.0.0" {
+						return fmt.Errorf(
+							`'body' output is %s; want '1.0.0'`,
+							outputs["body"].Value,
+						)
+					}
+
+					if want := testHttpMock.server.URL + "/meta_200.txt"; outputs["final_url"].Value != want {
+						return fmt.Errorf(
+							`'final_url' output is %s; want '%s'`,
+							outputs["final_url"].Value,
+							want,
+						)
+					}
+
+					return nil
+				},
+			},
+		},
+	})
+}
+
+const testDataSourceConfig_postBase64 = `
+data "http" "http_test" {
+  url    = "%s/post"
+  method = "POST"
+
+  request_body_base64 = base64encode(jsonencode({
+    foo = "bar"
+    bar = "bar"
+  }))
+}
+
+output "body" {
+  value = data.http.http_test.body
+}
+`
+
+func TestDataSource_postBase64(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testDataSourceConfig_postBase64, testHttpMock.server.URL),
+				Check: func(s *terraform.State) error {
+					outputs := s.RootModule().Outputs
+
+					if outputs["body"].Value != "1.0.0" {
+						return fmt.Errorf(
+							`'body' output is %s; want '1.0.0'`,
+							outputs["body"].Value,
+						)
+					}
+
+					return nil
+				},
+			},
+		},
+	})
+}
+
+const testDataSourceConfig_postJSON = `
+data "http" "http_test" {
+  url    = "%s/post"
+  method = "POST"
+
+  request_body_json = {
+    foo = "bar"
+    bar = "bar"
+  }
+}
+
+output "body" {
+  value = data.http.http_test.body
+}
+`
+
+func TestDataSource_postJSON(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testDataSourceConfig_postJSON, testHttpMock.server.URL),
+				Check: func(s *terraform.State) error {
+					outputs := s.RootModule().Outputs
+
+					if outputs["body"].Value != "1.0.0" {
+						return fmt.Errorf(
+							`'body' output is %s; want '1.0.0'`,
+							outputs["body"].Value,
+						)
+					}
+
+					return nil
+				},
+			},
+		},
+	})
+}
+
+const testDataSourceConfig_sensitiveResponse = `
+data "http" "http_test" {
+  url                = "%s/meta_200.txt"
+  sensitive_response = true
 }
 
 output "body" {
-  value = data.http.http_test.body
+  value     = data.http.http_test.sensitive_body
+  sensitive = true
+}
+`
+
+func TestDataSource_sensitiveResponse(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testDataSourceConfig_sensitiveResponse, testHttpMock.server.URL),
+				Check: func(s *terraform.State) error {
+					outputs := s.RootModule().Outputs
+
+					if outputs["body"].Value != "1.0.0" {
+						return fmt.Errorf(
+							`'body' output is %s; want '1.0.0'`,
+							outputs["body"].Value,
+						)
+					}
+
+					attributes := s.RootModule().Resources["data.http.http_test"].Primary.Attributes
+					if attributes["body"] != "" {
+						return fmt.Errorf("body is %q; want it empty for a sensitive response", attributes["body"])
+					}
+
+					return nil
+				},
+			},
+		},
+	})
+}
+
+const testDataSourceConfig_responseCookies = `
+data "http" "http_test" {
+  url = "%s/cookies"
+}
+
+output "session" {
+  value = data.http.http_test.response_cookies["session"]
+}
+
+output "lang" {
+  value = data.http.http_test.response_cookies["lang"]
+}
+`
+
+func TestDataSource_responseCookies(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testDataSourceConfig_responseCookies, testHttpMock.server.URL),
+				Check: func(s *terraform.State) error {
+					outputs := s.RootModule().Outputs
+
+					if outputs["session"].Value != "abc123" {
+						return fmt.Errorf(
+							`'session' output is %s; want 'abc123'`,
+							outputs["session"].Value,
+						)
+					}
+
+					if outputs["lang"].Value != "en" {
+						return fmt.Errorf(
+							`'lang' output is %s; want 'en'`,
+							outputs["lang"].Value,
+						)
+					}
+
+					return nil
+				},
+			},
+		},
+	})
+}
+
+const testDataSourceConfig_binary = `
+data "http" "http_test" {
+  url = "%s/binary"
+}
+
+output "response_body_base64" {
+  value = data.http.http_test.response_body_base64
+}
+`
+
+func TestDataSource_binary(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testDataSourceConfig_binary, testHttpMock.server.URL),
+				Check: func(s *terraform.State) error {
+					outputs := s.RootModule().Outputs
+
+					if outputs["response_body_base64"].Value != "/wABgA==" {
+						return fmt.Errorf(
+							`'response_body_base64' output is %s; want '/wABgA=='`,
+							outputs["response_body_base64"].Value,
+						)
+					}
+
+					return nil
+				},
+			},
+		},
+	})
+}
+
+const testDataSourceConfig_noRedirects = `
+data "http" "http_test" {
+  url = "%s/redirect"
+
+  follow_redirects      = false
+  expected_status_codes = [302]
+}
+
+output "status_code" {
+  value = data.http.http_test.status_code
 }
 
 output "response_headers" {
@@ -108,7 +615,7 @@ output "response_headers" {
 }
 `
 
-func TestDataSource_httperrorwithbody(t *testing.T) {
+func TestDataSource_noRedirects(t *testing.T) {
 	testHttpMock := setUpMockHttpServer()
 
 	defer testHttpMock.server.Close()
@@ -117,8 +624,28 @@ func TestDataSource_httperrorwithbody(t *testing.T) {
 		Providers: testProviders,
 		Steps: []resource.TestStep{
 			{
-				Config:      fmt.Sprintf(testDataSourceConfig_basic_error_with_body, testHttpMock.server.URL),
-				ExpectError: regexp.MustCompile("HTTP request error. Response code: 500,  Error Response body: ruh-roh"),
+				Config: fmt.Sprintf(testDataSourceConfig_noRedirects, testHttpMock.server.URL),
+				Check: func(s *terraform.State) error {
+					outputs := s.RootModule().Outputs
+
+					if outputs["status_code"].Value != "302" {
+						return fmt.Errorf(
+							`'status_code' output is %v; want '302'`,
+							outputs["status_code"].Value,
+						)
+					}
+
+					response_headers := outputs["response_headers"].Value.(map[string]interface{})
+
+					if response_headers["Location"].(string) != "/meta_200.txt" {
+						return fmt.Errorf(
+							`'Location' response header is %s; want '/meta_200.txt'`,
+							response_headers["Location"].(string),
+						)
+					}
+
+					return nil
+				},
 			},
 		},
 	})
@@ -377,6 +904,109 @@ func TestDataSource_form_post(t *testing.T) {
 	})
 }
 
+const testDataSourceConfig_request_form = `
+data "http" "http_test" {
+  url    = "%s/formpost"
+  method = "POST"
+
+  request_form = {
+    foo = "bar"
+    bar = "bar"
+  }
+}
+
+output "body" {
+  value = data.http.http_test.body
+}
+`
+
+func TestDataSource_request_form(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testDataSourceConfig_request_form, testHttpMock.server.URL),
+				Check: func(s *terraform.State) error {
+					outputs := s.RootModule().Outputs
+
+					if outputs["body"].Value != "1.0.0" {
+						return fmt.Errorf(
+							`'body' output is %s; want '1.0.0'`,
+							outputs["body"].Value,
+						)
+					}
+
+					return nil
+				},
+			},
+		},
+	})
+}
+
+const testDataSourceConfig_dry_run = `
+data "http" "http_test" {
+  url = "%s/post"
+  method = "POST"
+  dry_run = true
+  request_headers = {
+    content-type = "application/json"
+  }
+  request_body = jsonencode({
+    foo = "bar"
+  })
+}
+
+output "method" {
+  value = data.http.http_test.dry_run_request[0].method
+}
+
+output "body" {
+  value = data.http.http_test.dry_run_request[0].body
+}
+`
+
+func TestDataSource_dry_run(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testDataSourceConfig_dry_run, testHttpMock.server.URL),
+				Check: func(s *terraform.State) error {
+					if n := atomic.LoadInt32(&testHttpMock.requests); n != 0 {
+						return fmt.Errorf("dry run sent %d requests; want 0", n)
+					}
+
+					outputs := s.RootModule().Outputs
+
+					if outputs["method"].Value != "POST" {
+						return fmt.Errorf(
+							`'method' output is %s; want 'POST'`,
+							outputs["method"].Value,
+						)
+					}
+
+					if outputs["body"].Value != `{"foo":"bar"}` {
+						return fmt.Errorf(
+							`'body' output is %s; want '{"foo":"bar"}'`,
+							outputs["body"].Value,
+						)
+					}
+
+					return nil
+				},
+			},
+		},
+	})
+}
+
 // TODO:  i don't know how to do mTLS with https://pkg.go.dev/net/http/httptest#NewTLSServer
 // The following only does TLS even with the client_certs set
 // net/http/internal/testcert.go
@@ -427,8 +1057,10 @@ func TestDataSource_mtls(t *testing.T) {
 }
 
 func setUpMockHttpServer() *TestHttpMock {
-	Server := httptest.NewServer(
+	testHttpMock := &TestHttpMock{}
+	testHttpMock.server = httptest.NewServer(
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&testHttpMock.requests, 1)
 
 			w.Header().Set("Content-Type", "text/plain")
 			w.Header().Add("X-Single", "foobar")
@@ -438,10 +1070,14 @@ func setUpMockHttpServer() *TestHttpMock {
 				w.WriteHeader(http.StatusOK)
 				w.Write([]byte("1.0.0"))
 			} else if r.URL.Path == "/restricted/meta_200.txt" {
-				if r.Header.Get("Authorization") == "Zm9vOmJhcg==" {
+				user, password, ok := r.BasicAuth()
+				switch {
+				case r.Header.Get("Authorization") == "Zm9vOmJhcg==",
+					r.Header.Get("Authorization") == "Bearer Zm9vOmJhcg==",
+					ok && user == "foo" && password == "bar":
 					w.WriteHeader(http.StatusOK)
 					w.Write([]byte("1.0.0"))
-				} else {
+				default:
 					w.WriteHeader(http.StatusForbidden)
 				}
 			} else if r.URL.Path == "/utf-8/meta_200.txt" {
@@ -486,6 +1122,27 @@ func setUpMockHttpServer() *TestHttpMock {
 				w.Write([]byte("1.0.0"))
 			} else if r.URL.Path == "/post" && r.Method == http.MethodGet {
 				w.WriteHeader(http.StatusMethodNotAllowed)
+			} else if r.URL.Path == "/binary" {
+				w.Header().Set("Content-Type", "application/octet-stream")
+				w.Write([]byte{0xff, 0x00, 0x01, 0x80})
+			} else if r.URL.Path == "/redirect" {
+				http.Redirect(w, r, "/meta_200.txt", http.StatusFound)
+			} else if r.URL.Path == "/slow" {
+				time.Sleep(500 * time.Millisecond)
+				w.Write([]byte("1.0.0"))
+			} else if r.URL.Path == "/flaky" {
+				// Fails the first three requests, one more than the
+				// provider's default retry policy allows.
+				if atomic.LoadInt32(&testHttpMock.requests) <= 3 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Header().Set("Content-Type", "text/plain")
+				w.Write([]byte("1.0.0"))
+			} else if r.URL.Path == "/cookies" {
+				http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123", HttpOnly: true})
+				http.SetCookie(w, &http.Cookie{Name: "lang", Value: "en"})
+				w.Write([]byte("1.0.0"))
 			} else if r.URL.Path == "/errorwithbody" {
 				w.WriteHeader(http.StatusInternalServerError)
 				w.Write([]byte("ruh-roh"))
@@ -495,9 +1152,7 @@ func setUpMockHttpServer() *TestHttpMock {
 		}),
 	)
 
-	return &TestHttpMock{
-		server: Server,
-	}
+	return testHttpMock
 }
 
 func setUpMockTLSHttpServer() *TestHttpMock {
@@ -518,3 +1173,126 @@ func setUpMockTLSHttpServer() *TestHttpMock {
 		server: Server,
 	}
 }
+
+func TestNewRequest_defaultHeaders(t *testing.T) {
+	config := &providerConfig{
+		baseURL:        "https://api.example.com/v1",
+		defaultHeaders: map[string]interface{}{"authorization": "Bearer default", "X-Team": "platform"},
+	}
+	d := schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{
+		"url":             "items",
+		"request_headers": map[string]interface{}{"Authorization": "Bearer override"},
+	})
+	req, _, diags := newRequest(context.Background(), d, config)
+	if diags.HasError() {
+		t.Fatalf("err: %v", diags)
+	}
+	if req.URL.String() != "https://api.example.com/v1/items" {
+		t.Errorf("got URL %s", req.URL)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer override" {
+		t.Errorf("got Authorization %q; want the data source's", got)
+	}
+	if got := req.Header.Get("X-Team"); got != "platform" {
+		t.Errorf("got X-Team %q; want the provider default", got)
+	}
+}
+
+func TestNewRequest_method(t *testing.T) {
+	cases := []struct {
+		method string
+		strict bool
+		err    bool
+	}{
+		{"PROPFIND", false, false},
+		{"MKCOL", false, false},
+		{"PROPFIND", true, true},
+		{"PATCH", true, false},
+		{"PUT", true, false},
+		{"OPTIONS", true, false},
+		{"TRACE", true, false},
+	}
+	for _, tc := range cases {
+		d := schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{
+			"url":           "https://dav.example.com/files/",
+			"method":        tc.method,
+			"strict_method": tc.strict,
+		})
+		req, _, diags := newRequest(context.Background(), d, &providerConfig{})
+		if diags.HasError() != tc.err {
+			t.Errorf("%s (strict %t): got %v", tc.method, tc.strict, diags)
+			continue
+		}
+		if !tc.err && req.Method != tc.method {
+			t.Errorf("%s: got method %s", tc.method, req.Method)
+		}
+	}
+
+	for _, method := range []string{"PROPFIND", "VERSION-CONTROL", "M-SEARCH"} {
+		if _, errs := validateVerb(method, "method"); len(errs) != 0 {
+			t.Errorf("%s: got %v", method, errs)
+		}
+	}
+	for _, method := range []string{"", "GET /", "BAD\r\n"} {
+		if _, errs := validateVerb(method, "method"); len(errs) == 0 {
+			t.Errorf("%q: got no error", method)
+		}
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	cases := []struct {
+		name          string
+		body          string
+		contentLength int64
+		limit         int64
+		err           bool
+	}{
+		{"within limit", "12345", 5, 5, false},
+		{"no limit", "12345", 5, 0, false},
+		{"over limit", "123456", -1, 5, true},
+		{"declared over limit", "", 1 << 30, 5, true},
+	}
+	for _, tc := range cases {
+		resp := &http.Response{Body: ioutil.NopCloser(strings.NewReader(tc.body)), ContentLength: tc.contentLength}
+		body, err := readBody(resp, tc.limit)
+		if (err != nil) != tc.err {
+			t.Errorf("%s: got error %v", tc.name, err)
+			continue
+		}
+		if err == nil && string(body) != tc.body {
+			t.Errorf("%s: got %q", tc.name, body)
+		}
+	}
+}
+
+func TestApplyHTTPVersion(t *testing.T) {
+	var proto string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto = r.Proto
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	cases := []struct {
+		version string
+		want    string
+	}{
+		{"", "HTTP/1.1"},
+		{"1.1", "HTTP/1.1"},
+		{"2", "HTTP/2.0"},
+	}
+	for _, tc := range cases {
+		tr := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs}}
+		applyHTTPVersion(tr, tc.version)
+		resp, err := (&http.Client{Transport: tr}).Get(server.URL)
+		if err != nil {
+			t.Fatalf("%q: err: %s", tc.version, err)
+		}
+		resp.Body.Close()
+		if proto != tc.want {
+			t.Errorf("%q: got %s; want %s", tc.version, proto, tc.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// debugTransport logs every request and response at DEBUG level, visible
+// with TF_LOG=DEBUG, with credentials redacted: the headers, query
+// parameters and body fields redact.go considers sensitive and any header
+// named in redact. Response bodies are shown up to max_response_body_size,
+// and not at all for sensitive_response.
+type debugTransport struct {
+	next   http.RoundTripper
+	redact []string
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	log.Printf("[DEBUG] http-full request:\n%s", t.dumpRequest(req))
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		log.Printf("[DEBUG] http-full request to %s failed: %s", redactURL(req.URL), err)
+		return nil, err
+	}
+
+	dumped := *resp
+	dumped.Header = t.redactHeaders(resp.Header)
+	dumped.Body = nil
+	dump, err := httputil.DumpResponse(&dumped, false)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	// Only the start of the body is read ahead for the log; the caller
+	// gets it back in front of the rest, still streaming.
+	policy := recordingPolicyFrom(req.Context())
+	if policy.omitBody {
+		log.Printf("[DEBUG] http-full response:\n%s[body omitted: sensitive_response is set]", dump)
+		return resp, nil
+	}
+	head, err := ioutil.ReadAll(io.LimitReader(resp.Body, policy.limit+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+	shown, note := head, ""
+	if int64(len(head)) > policy.limit {
+		shown, note = head[:policy.limit], fmt.Sprintf("\n[body truncated to %d bytes]", policy.limit)
+	}
+	log.Printf("[DEBUG] http-full response:\n%s%s%s", dump, redactBody(resp.Header.Get("Content-Type"), shown), note)
+	return resp, nil
+}
+
+// dumpRequest dumps a redacted copy of req, leaving req itself untouched;
+// the body is only shown when it can be read again.
+func (t *debugTransport) dumpRequest(req *http.Request) []byte {
+	dumped := req.Clone(req.Context())
+	dumped.Header = t.redactHeaders(req.Header)
+	dumped.URL, _ = url.Parse(redactURL(req.URL))
+	withBody := req.Body == nil || req.GetBody != nil
+	if req.GetBody != nil {
+		dumped.Body = nil
+		if body, err := req.GetBody(); err != nil {
+			withBody = false
+		} else if b, err := ioutil.ReadAll(body); err != nil {
+			withBody = false
+		} else {
+			b = redactBody(req.Header.Get("Content-Type"), b)
+			dumped.Body, dumped.ContentLength = ioutil.NopCloser(bytes.NewReader(b)), int64(len(b))
+		}
+	} else {
+		dumped.Body = nil
+	}
+	dump, err := httputil.DumpRequestOut(dumped, withBody)
+	if err != nil {
+		return []byte(err.Error())
+	}
+	return dump
+}
+
+func (t *debugTransport) redactHeaders(h http.Header) http.Header {
+	out := redactHeaders(h)
+	for _, name := range t.redact {
+		name = http.CanonicalHeaderKey(name)
+		if _, ok := out[name]; ok {
+			out[name] = []string{redacted}
+		}
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDebugTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=server-secret")
+		w.Write(append([]byte("echo:"), body...))
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	client := &http.Client{Transport: &debugTransport{next: http.DefaultTransport, redact: []string{"x-tenant"}}}
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/items?api_key=query-secret&page=2", strings.NewReader("payload"))
+	req.Header.Set("Authorization", "Bearer header-secret")
+	req.Header.Set("X-Tenant", "tenant-secret")
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "echo:payload" {
+		t.Errorf("got body %q; the dump must not consume it", body)
+	}
+
+	out := logs.String()
+	for _, want := range []string{"POST /items?", "page=2", "Accept: application/json", "payload", "echo:payload", "200 OK"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log is missing %q:\n%s", want, out)
+		}
+	}
+	for _, secret := range []string{"header-secret", "tenant-secret", "query-secret", "server-secret"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log leaks %q:\n%s", secret, out)
+		}
+	}
+}
+
+func TestDebugTransport_bodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"password": "response-secret", "name": "n"}`))
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	client := &http.Client{Transport: &debugTransport{next: http.DefaultTransport}}
+	cases := []struct {
+		name    string
+		policy  recordingPolicy
+		want    string
+		notWant string
+	}{
+		{"redacted", recordingPolicy{}, `"name":"n"`, "response-secret"},
+		{"sensitive", recordingPolicy{omitBody: true}, "body omitted", `"name"`},
+		{"truncated", recordingPolicy{limit: 5}, "body truncated to 5 bytes", "response-secret"},
+	}
+	for _, tc := range cases {
+		logs.Reset()
+		ctx := withRecordingPolicy(context.Background(), tc.policy)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, strings.NewReader(`{"api_key": "request-secret"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.name, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !strings.Contains(string(body), "response-secret") {
+			t.Errorf("%s: got body %q; the dump must not consume it", tc.name, body)
+		}
+
+		out := logs.String()
+		if !strings.Contains(out, tc.want) || strings.Contains(out, tc.notWant) || strings.Contains(out, "request-secret") {
+			t.Errorf("%s: log should contain %q but not %q or the request secret:\n%s", tc.name, tc.want, tc.notWant, out)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	decodeFormatAuto = "auto"
+	decodeFormatJSON = "json"
+	decodeFormatYAML = "yaml"
+	decodeFormatXML  = "xml"
+	decodeFormatForm = "form"
+)
+
+var decodeFormats = []string{decodeFormatAuto, decodeFormatJSON, decodeFormatYAML, decodeFormatXML, decodeFormatForm}
+
+// detectDecodeFormat maps a Content-Type to the format used to decode the
+// body, returning "" for types that are not structured.
+func detectDecodeFormat(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return decodeFormatJSON
+	case mediaType == "application/yaml" || mediaType == "application/x-yaml" ||
+		mediaType == "text/yaml" || mediaType == "text/x-yaml" || strings.HasSuffix(mediaType, "+yaml"):
+		return decodeFormatYAML
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return decodeFormatXML
+	case mediaType == "application/x-www-form-urlencoded":
+		return decodeFormatForm
+	}
+	return ""
+}
+
+// decodeBody parses body according to format (or the Content-Type when the
+// format is auto) and returns the result re-encoded as JSON, ready for
+// jsondecode(). It returns "" when the body is not of a structured type.
+func decodeBody(contentType, format string, body []byte) (string, error) {
+	if format == "" || format == decodeFormatAuto {
+		format = detectDecodeFormat(contentType)
+	}
+
+	var value interface{}
+	switch format {
+	case "":
+		return "", nil
+	case decodeFormatJSON:
+		if err := json.Unmarshal(body, &value); err != nil {
+			return "", fmt.Errorf("decoding JSON body: %s", err)
+		}
+	case decodeFormatYAML:
+		if err := yaml.Unmarshal(body, &value); err != nil {
+			return "", fmt.Errorf("decoding YAML body: %s", err)
+		}
+		value = normalizeYAML(value)
+	case decodeFormatXML:
+		v, err := decodeXML(body)
+		if err != nil {
+			return "", fmt.Errorf("decoding XML body: %s", err)
+		}
+		value = v
+	case decodeFormatForm:
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "", fmt.Errorf("decoding form body: %s", err)
+		}
+		form := make(map[string]interface{}, len(values))
+		for k, v := range values {
+			if len(v) == 1 {
+				form[k] = v[0]
+			} else {
+				form[k] = v
+			}
+		}
+		value = form
+	default:
+		return "", fmt.Errorf("unsupported decode format %q", format)
+	}
+
+	out, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// jsonFields returns the top-level fields of a JSON object body served with
+// a JSON Content-Type, as response_body_json: strings as they are, other
+// scalars in their JSON form and nested objects and arrays as JSON, ready
+// for jsondecode(). It returns nil for any other body.
+func jsonFields(contentType string, body []byte) map[string]string {
+	if detectDecodeFormat(contentType) != decodeFormatJSON {
+		return nil
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(body, &object); err != nil {
+		return nil
+	}
+	fields := make(map[string]string, len(object))
+	for key, raw := range object {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			fields[key] = s
+			continue
+		}
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, raw); err != nil {
+			return nil
+		}
+		fields[key] = buf.String()
+	}
+	return fields
+}
+
+// decodeXML converts an XML document into nested maps: the root element
+// becomes a single key, attributes are prefixed with "@", text content of
+// elements that also have attributes or children is stored under "#text" and
+// repeated child elements become lists.
+func decodeXML(body []byte) (interface{}, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no root element")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			v, err := decodeXMLElement(decoder, start)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{start.Name.Local: v}, nil
+		}
+	}
+}
+
+func decodeXMLElement(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	node := map[string]interface{}{}
+	for _, attr := range start.Attr {
+		node["@"+attr.Name.Local] = attr.Value
+	}
+
+	var text strings.Builder
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLElement(decoder, t)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			switch existing := node[name].(type) {
+			case nil:
+				node[name] = child
+			case []interface{}:
+				node[name] = append(existing, child)
+			default:
+				node[name] = []interface{}{existing, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			content := strings.TrimSpace(text.String())
+			if len(node) == 0 {
+				return content, nil
+			}
+			if content != "" {
+				node["#text"] = content
+			}
+			return node, nil
+		}
+	}
+}
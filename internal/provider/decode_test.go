@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"testing"
+)
+
+func TestDecodeBody(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		format      string
+		body        string
+		want        string
+	}{
+		{"json", "application/json; charset=utf-8", decodeFormatAuto, `{"b": [1, 2], "a": "x"}`, `{"a":"x","b":[1,2]}`},
+		{"problem json", "application/problem+json", decodeFormatAuto, `{"title": "t"}`, `{"title":"t"}`},
+		{"yaml", "application/yaml", decodeFormatAuto, "a: 1\nb:\n  - x\n", `{"a":1,"b":["x"]}`},
+		{"xml", "text/xml", decodeFormatAuto, `<r id="1"><i>a</i><i>b</i><n k="v">t</n></r>`, `{"r":{"@id":"1","i":["a","b"],"n":{"#text":"t","@k":"v"}}}`},
+		{"form", "application/x-www-form-urlencoded", decodeFormatAuto, "a=1&b=2&b=3", `{"a":"1","b":["2","3"]}`},
+		{"override", "text/plain", decodeFormatJSON, `[true]`, `[true]`},
+		{"unstructured", "text/plain", decodeFormatAuto, "hello", ``},
+	}
+
+	for _, tc := range cases {
+		got, err := decodeBody(tc.contentType, tc.format, []byte(tc.body))
+		if err != nil {
+			t.Errorf("%s: err: %s", tc.name, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %s; want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDecodeBody_invalid(t *testing.T) {
+	if _, err := decodeBody("application/json", decodeFormatAuto, []byte("{")); err == nil {
+		t.Fatalf("expected an error for invalid JSON")
+	}
+}
+
+func TestJSONFields(t *testing.T) {
+	got := jsonFields("application/json", []byte(`{"id": "a1", "count": 3, "ready": true, "spec": {"tier": "web"}, "tags": ["x"], "owner": null}`))
+	want := map[string]string{"id": "a1", "count": "3", "ready": "true", "spec": `{"tier":"web"}`, "tags": `["x"]`, "owner": ""}
+	if len(got) != len(want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: got %q; want %q", k, got[k], v)
+		}
+	}
+
+	if got := jsonFields("text/plain", []byte(`{"id": "a1"}`)); got != nil {
+		t.Errorf("non-JSON Content-Type: got %v", got)
+	}
+	if got := jsonFields("application/json", []byte(`[1, 2]`)); got != nil {
+		t.Errorf("array body: got %v", got)
+	}
+}
@@ -0,0 +1,111 @@
+package provider
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// defaultMaxDecompressedBytes bounds decoded response bodies unless
+// configured otherwise.
+const defaultMaxDecompressedBytes = 64 << 20
+
+// decompressTransport asks for gzip, br or zstd encoded responses and decodes
+// them, failing once a body decodes to more than limit bytes so that a
+// malicious or misconfigured endpoint cannot exhaust the runner's memory. A
+// limit of 0 disables the check. Like net/http, it leaves the response
+// encoded when the request sets its own Accept-Encoding. The wrapped
+// transport must have DisableCompression set.
+type decompressTransport struct {
+	next  http.RoundTripper
+	limit int64
+}
+
+func (t *decompressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requested := withAcceptEncoding(req)
+	resp, err := t.next.RoundTrip(requested)
+	if err != nil || requested == req || req.Method == http.MethodHead {
+		return resp, err
+	}
+
+	var body io.Reader
+	var closer func()
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("decoding gzip response: %s", err)
+		}
+		body = zr
+	case "br":
+		body = brotli.NewReader(resp.Body)
+	case "zstd":
+		opts := []zstd.DOption{zstd.WithDecoderLowmem(true)}
+		if t.limit > 0 {
+			// Also bounds the window the decoder allocates up front.
+			opts = append(opts, zstd.WithDecoderMaxMemory(uint64(t.limit)))
+		}
+		zr, err := zstd.NewReader(resp.Body, opts...)
+		if err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("decoding zstd response: %s", err)
+		}
+		body, closer = zr, zr.Close
+	default:
+		return resp, nil
+	}
+
+	resp.Body = &limitedBody{r: body, raw: resp.Body, limit: t.limit, closer: closer}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// withAcceptEncoding returns req asking for the encodings
+// decompressTransport decodes, unless it sets Accept-Encoding or asks for a
+// range, which would be a range of the encoded body.
+func withAcceptEncoding(req *http.Request) *http.Request {
+	if req.Header.Get("Accept-Encoding") != "" || req.Header.Get("Range") != "" {
+		return req
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", "gzip, br, zstd")
+	return req
+}
+
+// limitedBody is a decoded response body that errors once more than limit
+// bytes have been read from it.
+type limitedBody struct {
+	r      io.Reader
+	raw    io.ReadCloser
+	limit  int64
+	read   int64
+	closer func()
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.read += int64(n)
+	if b.limit > 0 && b.read > b.limit {
+		return n, fmt.Errorf("decompressed response body exceeds %d bytes", b.limit)
+	}
+	if err == zstd.ErrWindowSizeExceeded || err == zstd.ErrDecoderSizeExceeded {
+		return n, fmt.Errorf("zstd window of the response body exceeds %d bytes", b.limit)
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	if b.closer != nil {
+		b.closer()
+	}
+	return b.raw.Close()
+}
@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestDecompressTransport(t *testing.T) {
+	payload := strings.Repeat("a", 4096)
+	encoded := map[string][]byte{}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(payload))
+	gz.Close()
+	encoded["gzip"] = append([]byte(nil), buf.Bytes()...)
+
+	buf.Reset()
+	br := brotli.NewWriter(&buf)
+	br.Write([]byte(payload))
+	br.Close()
+	encoded["br"] = append([]byte(nil), buf.Bytes()...)
+
+	zw, _ := zstd.NewWriter(nil)
+	encoded["zstd"] = zw.EncodeAll([]byte(payload), nil)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := r.URL.Query().Get("encoding")
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), encoding) {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(encoded[encoding])
+	}))
+	defer server.Close()
+
+	get := func(encoding string, limit int64) (string, error) {
+		client := &http.Client{Transport: &decompressTransport{
+			next:  &http.Transport{DisableCompression: true},
+			limit: limit,
+		}}
+		resp, err := client.Get(server.URL + "?encoding=" + encoding)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	for encoding := range encoded {
+		body, err := get(encoding, 1<<20)
+		if err != nil {
+			t.Errorf("%s: err: %s", encoding, err)
+		} else if body != payload {
+			t.Errorf("%s: decoded %d bytes; want %d", encoding, len(body), len(payload))
+		}
+
+		if _, err := get(encoding, 1024); err == nil || !strings.Contains(err.Error(), "exceeds 1024 bytes") {
+			t.Errorf("%s: got %v; want the size limit to be enforced", encoding, err)
+		}
+	}
+
+	// A request asking for an encoding itself gets the encoded body.
+	client := &http.Client{Transport: &decompressTransport{next: &http.Transport{DisableCompression: true}}}
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"?encoding=gzip", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if !bytes.Equal(body, encoded["gzip"]) || resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("requested encoding: got %d bytes with Content-Encoding %q; want the gzip body untouched", len(body), resp.Header.Get("Content-Encoding"))
+	}
+}
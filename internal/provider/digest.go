@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+const (
+	digestContentMD5    = "content-md5"
+	digestDigest        = "digest"
+	digestContentDigest = "content-digest"
+)
+
+var (
+	digestHeaders    = []string{digestContentMD5, digestDigest, digestContentDigest}
+	digestAlgorithms = []string{"sha-256", "sha-512"}
+)
+
+// bodyDigest returns the value of the integrity header for body: a plain
+// base64 MD5 for Content-MD5 (RFC 1864), "SHA-256=<base64>" for Digest
+// (RFC 3230) and "sha-256=:<base64>:" for Content-Digest (RFC 9530).
+func bodyDigest(header, algorithm string, body []byte) (string, error) {
+	var sum []byte
+	switch algorithm {
+	case "md5":
+		s := md5.Sum(body)
+		sum = s[:]
+	case "sha-256":
+		s := sha256.Sum256(body)
+		sum = s[:]
+	case "sha-512":
+		s := sha512.Sum512(body)
+		sum = s[:]
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+	encoded := base64.StdEncoding.EncodeToString(sum)
+
+	switch header {
+	case digestContentMD5:
+		return encoded, nil
+	case digestDigest:
+		name := map[string]string{"md5": "MD5", "sha-256": "SHA-256", "sha-512": "SHA-512"}[algorithm]
+		return name + "=" + encoded, nil
+	case digestContentDigest:
+		return algorithm + "=:" + encoded + ":", nil
+	}
+	return "", fmt.Errorf("unsupported digest header %q", header)
+}
+
+// setBodyDigests adds the integrity headers in headers to req, leaving any
+// already set by request_headers alone. Content-MD5 is always MD5; the
+// others use algorithm.
+func setBodyDigests(req *http.Request, body []byte, headers []string, algorithm string) error {
+	for _, header := range headers {
+		if req.Header.Get(header) != "" {
+			continue
+		}
+		alg := algorithm
+		if header == digestContentMD5 {
+			alg = "md5"
+		}
+		value, err := bodyDigest(header, alg, body)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(header, value)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package provider
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// digestAuthTransport answers HTTP Digest challenges (RFC 7616): a request
+// refused with 401 and a Digest WWW-Authenticate header is sent again with
+// the computed Authorization. Only the "auth" quality of protection, or
+// none for RFC 2069 servers, is supported, with MD5 or SHA-256 and their
+// -sess variants.
+type digestAuthTransport struct {
+	next     http.RoundTripper
+	username string
+	password string
+}
+
+// digestChallenge holds the parameters of a WWW-Authenticate: Digest header.
+type digestChallenge map[string]string
+
+func (t *digestAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge := selectDigestChallenge(resp.Header.Values("WWW-Authenticate"))
+	if challenge == nil {
+		return resp, nil
+	}
+	// The body was sent with the first attempt.
+	retry := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return resp, nil
+		}
+		if retry.Body, err = req.GetBody(); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("digest authentication: %s", err)
+	}
+	authorization := challenge.authorize(req.Method, req.URL.RequestURI(), t.username, t.password, hex.EncodeToString(b))
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	retry.Header.Set("Authorization", authorization)
+	return t.next.RoundTrip(retry)
+}
+
+// selectDigestChallenge returns the strongest supported Digest challenge
+// among the WWW-Authenticate header values, or nil if there is none.
+func selectDigestChallenge(values []string) digestChallenge {
+	var best digestChallenge
+	for _, value := range values {
+		if len(value) < 7 || !strings.EqualFold(value[:7], "Digest ") {
+			continue
+		}
+		c := parseDigestChallenge(value[7:])
+		if c.hash() == nil || c.qop() == "unsupported" {
+			continue
+		}
+		if best == nil || (strings.HasPrefix(c.algorithm(), "SHA-256") && !strings.HasPrefix(best.algorithm(), "SHA-256")) {
+			best = c
+		}
+	}
+	return best
+}
+
+// parseDigestChallenge parses the comma separated name=value parameters of
+// a challenge, values being tokens or quoted strings.
+func parseDigestChallenge(s string) digestChallenge {
+	c := digestChallenge{}
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		eq := strings.IndexByte(s, '=')
+		if eq == -1 {
+			return c
+		}
+		name := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		var value strings.Builder
+		if strings.HasPrefix(s, `"`) {
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end == -1 {
+				end = len(s)
+			}
+			value.WriteString(strings.TrimSpace(s[:end]))
+			s = s[end:]
+		}
+		c[name] = value.String()
+	}
+}
+
+func (c digestChallenge) algorithm() string {
+	if a := c["algorithm"]; a != "" {
+		return strings.ToUpper(a)
+	}
+	return "MD5"
+}
+
+func (c digestChallenge) hash() func() hash.Hash {
+	switch strings.TrimSuffix(c.algorithm(), "-SESS") {
+	case "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	}
+	return nil
+}
+
+// qop returns "auth" when offered, "" when the server sent no qop and
+// "unsupported" for any other, such as auth-int alone.
+func (c digestChallenge) qop() string {
+	v, ok := c["qop"]
+	if !ok {
+		return ""
+	}
+	for _, qop := range strings.Split(v, ",") {
+		if strings.TrimSpace(qop) == "auth" {
+			return "auth"
+		}
+	}
+	return "unsupported"
+}
+
+// authorize returns the Authorization header answering the challenge with
+// the client nonce cnonce.
+func (c digestChallenge) authorize(method, uri, username, password, cnonce string) string {
+	newHash := c.hash()
+	h := func(s string) string {
+		sum := newHash()
+		io.WriteString(sum, s)
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+	const nc = "00000001"
+
+	ha1 := h(username + ":" + c["realm"] + ":" + password)
+	if strings.HasSuffix(c.algorithm(), "-SESS") {
+		ha1 = h(ha1 + ":" + c["nonce"] + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+
+	qop := c.qop()
+	var response string
+	if qop == "" {
+		response = h(ha1 + ":" + c["nonce"] + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + c["nonce"] + ":" + nc + ":" + cnonce + ":" + qop + ":" + ha2)
+	}
+
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace
+	params := []string{
+		fmt.Sprintf(`username="%s"`, quote(username)),
+		fmt.Sprintf(`realm="%s"`, quote(c["realm"])),
+		fmt.Sprintf(`nonce="%s"`, quote(c["nonce"])),
+		fmt.Sprintf(`uri="%s"`, quote(uri)),
+		fmt.Sprintf(`algorithm=%s`, c.algorithm()),
+		fmt.Sprintf(`response="%s"`, response),
+	}
+	if qop != "" {
+		params = append(params, "qop="+qop, "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	}
+	if opaque, ok := c["opaque"]; ok {
+		params = append(params, fmt.Sprintf(`opaque="%s"`, quote(opaque)))
+	}
+	return "Digest " + strings.Join(params, ", ")
+}
@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDigestChallengeAuthorize(t *testing.T) {
+	// The examples of RFC 7616 section 3.9.1.
+	const header = `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=%s, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`
+	const cnonce = "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+	cases := []struct {
+		algorithm string
+		response  string
+	}{
+		{"MD5", "8ca523f5e9506fed4657c9700eebdbec"},
+		{"SHA-256", "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+	}
+	for _, tc := range cases {
+		c := selectDigestChallenge([]string{strings.Replace(header, "%s", tc.algorithm, 1)})
+		if c == nil {
+			t.Fatalf("%s: challenge not supported", tc.algorithm)
+		}
+		got := c.authorize(http.MethodGet, "/dir/index.html", "Mufasa", "Circle of Life", cnonce)
+		if !strings.Contains(got, `response="`+tc.response+`"`) {
+			t.Errorf("%s: got %s; want response %s", tc.algorithm, got, tc.response)
+		}
+		if !strings.Contains(got, `opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`) || !strings.Contains(got, "qop=auth,") {
+			t.Errorf("%s: got %s; want opaque and qop echoed", tc.algorithm, got)
+		}
+	}
+
+	// The strongest algorithm is preferred; auth-int alone is unsupported.
+	c := selectDigestChallenge([]string{
+		`Basic realm="x"`,
+		`Digest realm="x", nonce="n", algorithm=MD5, qop="auth"`,
+		`Digest realm="x", nonce="n", algorithm=SHA-256, qop="auth"`,
+	})
+	if c == nil || c.algorithm() != "SHA-256" {
+		t.Errorf("got %v; want the SHA-256 challenge", c)
+	}
+	if c := selectDigestChallenge([]string{`Digest realm="x", nonce="n", qop="auth-int"`}); c != nil {
+		t.Errorf("got %v; want auth-int only to be unsupported", c)
+	}
+}
+
+func TestDigestAuthTransport(t *testing.T) {
+	var attempts int
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		data, _ := ioutil.ReadAll(r.Body)
+		auth := r.Header.Get("Authorization")
+		if auth == "" {
+			w.Header().Set("WWW-Authenticate", `Digest realm="device", nonce="abc", qop="auth"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		params := parseDigestChallenge(strings.TrimPrefix(auth, "Digest "))
+		want := selectDigestChallenge([]string{`Digest realm="device", nonce="abc", qop="auth"`}).authorize(r.Method, r.URL.RequestURI(), "admin", "secret", params["cnonce"])
+		if auth != want {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body = string(data)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &digestAuthTransport{next: http.DefaultTransport, username: "admin", password: "secret"}}
+	resp, err := client.Post(server.URL+"/config?x=1", "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d; want 200", resp.StatusCode)
+	}
+	if attempts != 2 || body != "payload" {
+		t.Errorf("got %d attempts with body %q; want the body sent again after the challenge", attempts, body)
+	}
+}
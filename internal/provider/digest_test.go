@@ -0,0 +1,37 @@
+package provider
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSetBodyDigests(t *testing.T) {
+	body := []byte("hello world")
+
+	req, _ := http.NewRequest(http.MethodPut, "https://example.com/upload", nil)
+	if err := setBodyDigests(req, body, digestHeaders, "sha-256"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	want := map[string]string{
+		"Content-MD5":    "XrY7u+Ae7tCTyyK7j1rNww==",
+		"Digest":         "SHA-256=uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek=",
+		"Content-Digest": "sha-256=:uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek=:",
+	}
+	for name, value := range want {
+		if got := req.Header.Get(name); got != value {
+			t.Errorf("%s: got %q; want %q", name, got, value)
+		}
+	}
+
+	req, _ = http.NewRequest(http.MethodPut, "https://example.com/upload", nil)
+	req.Header.Set("Content-Digest", "sha-256=:preset:")
+	if err := setBodyDigests(req, body, []string{digestDigest, digestContentDigest}, "sha-512"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := req.Header.Get("Content-Digest"); got != "sha-256=:preset:" {
+		t.Errorf("preset Content-Digest was replaced with %q", got)
+	}
+	if got, want := req.Header.Get("Digest"), "SHA-512=MJ7MSJwS1utMxA9QyQLytNDtd+5RGnx6m808qG1M2G+YndNbxf9JlnDaNCVbRbDP2DDoH2Bdz33FVC6TrpzXbw=="; got != want {
+		t.Errorf("Digest: got %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,167 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// dialFunc is the signature of http.Transport's DialContext hook.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dialUnix returns a dial func connecting every request to the Unix domain
+// socket at path, whatever the host in the URL.
+func dialUnix(path string) dialFunc {
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", path)
+	}
+}
+
+// dnsRecorder dials connections to host itself so that every address the
+// host resolved to, and the one actually connected to, can be reported.
+// Connections to any other host (e.g. pagination links) are dialed normally.
+// The dial func can be replaced to connect through a proxy.
+//
+// connectTo maps "host:port" or "host" to the "address:port" or "address"
+// to dial instead, like curl --connect-to: the request keeps its URL, so the
+// Host header and TLS verification still use the original hostname.
+//
+// resolver, when set, resolves every hostname instead of the system
+// resolver.
+type dnsRecorder struct {
+	host      string
+	dial      dialFunc
+	connectTo map[string]string
+	resolver  *net.Resolver
+
+	mu        sync.Mutex
+	addresses []string
+	used      string
+}
+
+func newDNSRecorder(host string) *dnsRecorder {
+	return &dnsRecorder{host: host, dial: (&net.Dialer{}).DialContext}
+}
+
+// dialContext is an http.Transport DialContext hook.
+func (r *dnsRecorder) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return r.dial(ctx, network, addr)
+	}
+	if target, ok := r.connectTarget(host, port); ok {
+		if host == r.host {
+			targetHost, _, _ := net.SplitHostPort(target)
+			r.mu.Lock()
+			r.addresses, r.used = []string{targetHost}, targetHost
+			r.mu.Unlock()
+		}
+		return r.dial(ctx, network, target)
+	}
+	if host != r.host {
+		return r.dial(ctx, network, addr)
+	}
+
+	ips, err := r.lookupResolver().LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]string, len(ips))
+	for i, ip := range ips {
+		addresses[i] = ip.String()
+	}
+	r.mu.Lock()
+	r.addresses = addresses
+	r.mu.Unlock()
+
+	var lastErr error
+	for _, ip := range addresses {
+		conn, err := r.dial(ctx, network, net.JoinHostPort(ip, port))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		r.mu.Lock()
+		r.used = ip
+		r.mu.Unlock()
+		return conn, nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no addresses found for %s", host)
+	}
+	return nil, lastErr
+}
+
+// useDNSServers resolves hostnames with the DNS servers at addresses, given
+// as "ip" or "ip:port", trying each in turn. It must be called before the
+// dial func is replaced.
+func (r *dnsRecorder) useDNSServers(addresses []string) {
+	servers := make([]string, len(addresses))
+	for i, address := range addresses {
+		servers[i] = dnsServerAddress(address)
+	}
+	r.resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			var lastErr error
+			for _, server := range servers {
+				conn, err := dialer.DialContext(ctx, network, server)
+				if err == nil {
+					return conn, nil
+				}
+				lastErr = err
+			}
+			return nil, lastErr
+		},
+	}
+	r.dial = (&net.Dialer{Resolver: r.resolver}).DialContext
+}
+
+// dnsServerAddress adds the default DNS port to an address without one.
+func dnsServerAddress(address string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(strings.Trim(address, "[]"), "53")
+}
+
+func validateDNSServer(val interface{}, key string) (warns []string, errs []error) {
+	host, _, err := net.SplitHostPort(dnsServerAddress(val.(string)))
+	if err != nil || net.ParseIP(host) == nil {
+		errs = append(errs, fmt.Errorf("%s must be an IP address, optionally with a port, got %q", key, val))
+	}
+	return
+}
+
+func (r *dnsRecorder) lookupResolver() *net.Resolver {
+	if r.resolver != nil {
+		return r.resolver
+	}
+	return net.DefaultResolver
+}
+
+// connectTarget returns the address connectTo gives for host and port, if
+// any; a target without a port keeps the original one.
+func (r *dnsRecorder) connectTarget(host, port string) (string, bool) {
+	for _, key := range []string{net.JoinHostPort(host, port), host} {
+		target, ok := r.connectTo[key]
+		if !ok {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(target); err == nil {
+			return target, true
+		}
+		return net.JoinHostPort(strings.Trim(target, "[]"), port), true
+	}
+	return "", false
+}
+
+// result returns the resolved addresses and the one that was used.
+func (r *dnsRecorder) result() ([]string, string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.addresses, r.used
+}
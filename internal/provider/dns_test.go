@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestDNSRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	recorder := newDNSRecorder("localhost")
+	client := &http.Client{Transport: &http.Transport{DialContext: recorder.dialContext}}
+	resp, err := client.Get(strings.Replace(server.URL, "127.0.0.1", "localhost", 1))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+
+	addresses, used := recorder.result()
+	if used != "127.0.0.1" {
+		t.Fatalf("used %q; want 127.0.0.1", used)
+	}
+	found := false
+	for _, a := range addresses {
+		found = found || a == used
+	}
+	if !found {
+		t.Fatalf("resolved addresses %v do not include %s", addresses, used)
+	}
+}
+
+func TestDNSRecorder_connectTo(t *testing.T) {
+	var host string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+	_, port, _ := net.SplitHostPort(addr)
+
+	cases := []struct {
+		name      string
+		connectTo map[string]string
+	}{
+		{"host and port", map[string]string{"cutover.test:" + port: addr}},
+		{"host only", map[string]string{"cutover.test": "127.0.0.1"}},
+	}
+	for _, tc := range cases {
+		recorder := newDNSRecorder("cutover.test")
+		recorder.connectTo = tc.connectTo
+		client := &http.Client{Transport: &http.Transport{DialContext: recorder.dialContext}}
+		resp, err := client.Get("http://cutover.test:" + port + "/")
+		if err != nil {
+			t.Errorf("%s: err: %s", tc.name, err)
+			continue
+		}
+		resp.Body.Close()
+		if host != "cutover.test:"+port {
+			t.Errorf("%s: got Host %q; want the URL's", tc.name, host)
+		}
+		if _, used := recorder.result(); used != "127.0.0.1" {
+			t.Errorf("%s: used %q; want 127.0.0.1", tc.name, used)
+		}
+	}
+}
+
+// setUpMockDNSServer answers A queries for every name with 127.0.0.1 over
+// UDP and returns its address.
+func setUpMockDNSServer(t *testing.T) (string, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) == 0 {
+				continue
+			}
+			q := query.Questions[0]
+			reply := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true},
+				Questions: query.Questions,
+			}
+			if q.Type == dnsmessage.TypeA {
+				reply.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+					Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+				}}
+			}
+			packed, err := reply.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(packed, addr)
+		}
+	}()
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+func TestDNSRecorder_dnsServers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	dnsServer, stop := setUpMockDNSServer(t)
+	defer stop()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+
+	recorder := newDNSRecorder("split-horizon.test")
+	recorder.useDNSServers([]string{dnsServer})
+	client := &http.Client{Transport: &http.Transport{DialContext: recorder.dialContext}}
+	resp, err := client.Get("http://split-horizon.test:" + port + "/")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if _, used := recorder.result(); used != "127.0.0.1" {
+		t.Errorf("used %q; want the address from the DNS server", used)
+	}
+
+	// Other hosts, e.g. redirect targets, use the same servers.
+	resp, err = client.Get("http://other.test:" + port + "/")
+	if err != nil {
+		t.Fatalf("other host: err: %s", err)
+	}
+	resp.Body.Close()
+
+	for _, tc := range []struct {
+		address string
+		valid   bool
+	}{{"10.0.0.2", true}, {"10.0.0.2:5353", true}, {"[::1]:53", true}, {"dns.internal", false}} {
+		if _, errs := validateDNSServer(tc.address, "dns_servers"); (len(errs) == 0) != tc.valid {
+			t.Errorf("%s: got errors %v; want valid %t", tc.address, errs, tc.valid)
+		}
+	}
+}
+
+func TestDialUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unix")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "daemon.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var requested string
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.Host + r.URL.Path
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{DialContext: dialUnix(path)}}
+	resp, err := client.Get("http://unix/v1.41/info")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if requested != "unix/v1.41/info" {
+		t.Errorf("got request for %q; want unix/v1.41/info", requested)
+	}
+}
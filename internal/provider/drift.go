@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"encoding/json"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// jsonSubset reports whether remote matches desired once the fields remote
+// has but desired doesn't mention are disregarded. Virtually every API adds
+// computed or defaulted fields to the objects it stores, which would
+// otherwise show up as a permanent diff.
+func jsonSubset(desired, remote interface{}) bool {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		r, ok := remote.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range d {
+			rv, ok := r[k]
+			if !ok || !jsonSubset(v, rv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		r, ok := remote.([]interface{})
+		if !ok || len(r) != len(d) {
+			return false
+		}
+		for i := range d {
+			if !jsonSubset(d[i], r[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return jsonEqual(desired, remote)
+	}
+}
+
+// jsonDriftSuppressFunc returns a DiffSuppressFunc for a JSON string
+// attribute whose state holds the remote object. The diff is suppressed when
+// the remote object only adds fields to the configured one, unless the
+// boolean attribute strictKey is set. Bodies that aren't JSON are compared as
+// plain strings.
+func jsonDriftSuppressFunc(strictKey string) schema.SchemaDiffSuppressFunc {
+	return func(k, old, new string, d *schema.ResourceData) bool {
+		if old == "" || new == "" {
+			return old == new
+		}
+		var remote, desired interface{}
+		if json.Unmarshal([]byte(old), &remote) != nil || json.Unmarshal([]byte(new), &desired) != nil {
+			return old == new
+		}
+		if d.Get(strictKey).(bool) {
+			return jsonEqual(desired, remote)
+		}
+		return jsonSubset(desired, remote)
+	}
+}
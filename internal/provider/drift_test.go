@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestJSONSubset(t *testing.T) {
+	cases := []struct {
+		desired string
+		remote  string
+		want    bool
+	}{
+		{`{"name": "a"}`, `{"name": "a", "id": 7, "created": "now"}`, true},
+		{`{"spec": {"size": 1}}`, `{"spec": {"size": 1, "zone": "x"}}`, true},
+		{`{"tags": [{"k": "a"}]}`, `{"tags": [{"k": "a", "v": ""}]}`, true},
+		{`{"tags": ["a"]}`, `{"tags": ["a", "b"]}`, false},
+		{`{"name": "a"}`, `{"name": "b", "id": 7}`, false},
+		{`{"name": "a", "size": 1}`, `{"name": "a"}`, false},
+		{`{"size": 1}`, `{"size": 1.0}`, true},
+	}
+
+	for _, tc := range cases {
+		var desired, remote interface{}
+		json.Unmarshal([]byte(tc.desired), &desired)
+		json.Unmarshal([]byte(tc.remote), &remote)
+		if got := jsonSubset(desired, remote); got != tc.want {
+			t.Errorf("jsonSubset(%s, %s) = %v; want %v", tc.desired, tc.remote, got, tc.want)
+		}
+	}
+}
+
+func TestJSONDriftSuppressFunc(t *testing.T) {
+	cases := []struct {
+		name   string
+		old    string
+		new    string
+		strict bool
+		want   bool
+	}{
+		{"added fields", `{"uid": "abc", "title": "api"}`, `{"title": "api"}`, false, true},
+		{"added fields, strict", `{"uid": "abc", "title": "api"}`, `{"title": "api"}`, true, false},
+		{"same object, strict", `{"title": "api", "size": 1}`, `{"size": 1.0, "title": "api"}`, true, true},
+		{"changed field", `{"uid": "abc", "title": "api"}`, `{"title": "API"}`, false, false},
+		{"not JSON", `title=api`, `title=api`, false, true},
+		{"not JSON, changed", `title=api`, `title=API`, false, false},
+		{"created", "", `{"title": "api"}`, false, false},
+		{"removed", `{"title": "api"}`, "", false, false},
+	}
+
+	suppress := jsonDriftSuppressFunc("strict_drift")
+	for _, tc := range cases {
+		d := schema.TestResourceDataRaw(t, resourceRequest().Schema, map[string]interface{}{
+			"url":          "https://example.com/dashboards",
+			"strict_drift": tc.strict,
+		})
+		if got := suppress("body", tc.old, tc.new, d); got != tc.want {
+			t.Errorf("%s: got %v; want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// dataSourceExtract runs the extraction engine of the http data source, jq,
+// json_extract and regex_extract, on a string from anywhere in the
+// configuration, such as the output of another provider. It stands in for a
+// provider function, which the plugin SDK cannot declare.
+func dataSourceExtract() *schema.Resource {
+	return &schema.Resource{
+		Description: "Extracts values from a JSON or text document.",
+
+		ReadContext: dataSourceExtractRead,
+
+		Schema: map[string]*schema.Schema{
+			"body": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"jq": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateJQ,
+			},
+
+			"json_extract": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"regex_extract": {
+				Type:         schema.TypeMap,
+				Optional:     true,
+				ValidateFunc: validateRegexMap,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"result": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"outputs": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+func dataSourceExtractRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	body := []byte(d.Get("body").(string))
+
+	// As on the http data source, json_extract and regex_extract see the
+	// output of jq when one is set.
+	if v, ok := d.GetOk("jq"); ok {
+		var err error
+		if body, err = applyJQ(ctx, v.(string), body); err != nil {
+			return diag.Errorf("Error applying jq: %s", err)
+		}
+	}
+
+	outputs := map[string]string{}
+	if v, ok := d.GetOk("json_extract"); ok {
+		var err error
+		if outputs, err = jsonExtract(body, v.(map[string]interface{})); err != nil {
+			return diag.Errorf("Error extracting JSON values: %s", err)
+		}
+	}
+	if v, ok := d.GetOk("regex_extract"); ok {
+		if err := regexExtract(body, v.(map[string]interface{}), outputs); err != nil {
+			return diag.Errorf("Error extracting values: %s", err)
+		}
+	}
+
+	d.Set("result", string(body))
+	d.Set("outputs", outputs)
+	sum := sha256.Sum256([]byte(d.Get("body").(string)))
+	d.SetId(hex.EncodeToString(sum[:]))
+	return nil
+}
@@ -0,0 +1,36 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceExtractRead(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourceExtract().Schema, map[string]interface{}{
+		"body":          `{"items": [{"name": "a", "version": "v1.2"}, {"name": "b"}]}`,
+		"jq":            ".items[0]",
+		"json_extract":  map[string]interface{}{"name": "$.name"},
+		"regex_extract": map[string]interface{}{"major": `"v(\d+)`},
+	})
+	if diags := dataSourceExtractRead(context.Background(), d, nil); diags.HasError() {
+		t.Fatalf("err: %v", diags)
+	}
+
+	if got, want := d.Get("result").(string), `{"name":"a","version":"v1.2"}`; got != want {
+		t.Errorf("got result %s; want %s", got, want)
+	}
+	outputs := d.Get("outputs").(map[string]interface{})
+	if outputs["name"] != "a" || outputs["major"] != "1" {
+		t.Errorf("got outputs %v", outputs)
+	}
+
+	d = schema.TestResourceDataRaw(t, dataSourceExtract().Schema, map[string]interface{}{
+		"body":         "<html>",
+		"json_extract": map[string]interface{}{"name": "$.name"},
+	})
+	if diags := dataSourceExtractRead(context.Background(), d, nil); !diags.HasError() {
+		t.Errorf("extracting JSON values from a non-JSON body did not fail")
+	}
+}
@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	gcpAccessToken = "access_token"
+	gcpIDToken     = "id_token"
+)
+
+var gcpTokenTypes = []string{gcpAccessToken, gcpIDToken}
+
+// gcpToken returns a Google access token or ID token obtained from the
+// credentials of a gcp_auth block, or Application Default Credentials when
+// none are given. ID tokens, as expected by Cloud Run and IAP, are minted
+// for audience, which defaults to the origin of target.
+func gcpToken(ctx context.Context, config *providerConfig, client *http.Client, block map[string]interface{}, target string) (string, error) {
+	tokenType := block["token_type"].(string)
+	var scopes []string
+	for _, scope := range block["scopes"].([]interface{}) {
+		scopes = append(scopes, scope.(string))
+	}
+	if len(scopes) == 0 {
+		scopes = []string{"https://www.googleapis.com/auth/cloud-platform"}
+	}
+	audience := block["audience"].(string)
+	if tokenType == gcpIDToken && audience == "" {
+		u, err := url.Parse(target)
+		if err != nil {
+			return "", err
+		}
+		audience = u.Scheme + "://" + u.Host
+	}
+	credentialsJSON := block["credentials"].(string)
+
+	key := strings.Join([]string{"gcp", tokenType, audience, strings.Join(scopes, " "), credentialsJSON}, "|")
+	return config.tokens.token(ctx, key, func(ctx context.Context) (string, time.Time, error) {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, client)
+		var creds *google.Credentials
+		var err error
+		if credentialsJSON != "" {
+			creds, err = google.CredentialsFromJSON(ctx, []byte(credentialsJSON), scopes...)
+		} else {
+			creds, err = google.FindDefaultCredentials(ctx, scopes...)
+		}
+		if err != nil {
+			return "", time.Time{}, err
+		}
+
+		if tokenType == gcpAccessToken {
+			token, err := creds.TokenSource.Token()
+			if err != nil {
+				return "", time.Time{}, err
+			}
+			return token.AccessToken, token.Expiry, nil
+		}
+		return gcpIDTokenFor(ctx, creds, audience)
+	})
+}
+
+// gcpIDTokenFor mints an ID token for audience from a service account key,
+// or from the metadata server when running on Google Cloud.
+func gcpIDTokenFor(ctx context.Context, creds *google.Credentials, audience string) (string, time.Time, error) {
+	if len(creds.JSON) == 0 {
+		if !metadata.OnGCE() {
+			return "", time.Time{}, fmt.Errorf("ID tokens require a service account key or the metadata server")
+		}
+		token, err := metadata.Get("instance/service-accounts/default/identity?format=full&audience=" + url.QueryEscape(audience))
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		return token, jwtExpiry(token), nil
+	}
+
+	var f struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(creds.JSON, &f); err != nil {
+		return "", time.Time{}, err
+	}
+	if f.Type != "service_account" {
+		return "", time.Time{}, fmt.Errorf("ID tokens can't be minted from %q credentials; use a service account", f.Type)
+	}
+	jwtConfig, err := google.JWTConfigFromJSON(creds.JSON)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	jwtConfig.PrivateClaims = map[string]interface{}{"target_audience": audience}
+	jwtConfig.UseIDToken = true
+	token, err := jwtConfig.TokenSource(ctx).Token()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token.AccessToken, jwtExpiry(token.AccessToken), nil
+}
+
+// jwtExpiry returns the exp claim of a JWT, or the zero time if it can't be
+// read.
+func jwtExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGCPToken_serviceAccount(t *testing.T) {
+	expiry := time.Now().Add(time.Hour).Unix()
+	idToken := "e30." + base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, expiry))) + ".sig"
+
+	var assertions []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.FormValue("assertion"), ".")
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var claims map[string]interface{}
+		json.Unmarshal(payload, &claims)
+		assertions = append(assertions, claims)
+
+		w.Header().Set("Content-Type", "application/json")
+		if _, ok := claims["target_audience"]; ok {
+			json.NewEncoder(w).Encode(map[string]interface{}{"id_token": idToken})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "ya29.token", "token_type": "Bearer", "expires_in": 3600})
+	}))
+	defer server.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	credentials, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "terraform@project.iam.gserviceaccount.com",
+		"private_key_id": "1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		"token_uri":      server.URL,
+	})
+
+	config := configFromMeta(nil)
+	block := map[string]interface{}{
+		"token_type":  gcpAccessToken,
+		"scopes":      []interface{}{},
+		"audience":    "",
+		"credentials": string(credentials),
+	}
+	token, err := gcpToken(context.Background(), config, http.DefaultClient, block, "https://service-abc-uc.a.run.app/path")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if token != "ya29.token" {
+		t.Errorf("got access token %q; want ya29.token", token)
+	}
+	if got := assertions[0]["scope"]; got != "https://www.googleapis.com/auth/cloud-platform" {
+		t.Errorf("access token requested for scope %v; want cloud-platform", got)
+	}
+
+	block["token_type"] = gcpIDToken
+	token, err = gcpToken(context.Background(), config, http.DefaultClient, block, "https://service-abc-uc.a.run.app/path")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if token != idToken {
+		t.Errorf("got ID token %q; want %q", token, idToken)
+	}
+	if got := assertions[1]["target_audience"]; got != "https://service-abc-uc.a.run.app" {
+		t.Errorf("ID token requested for audience %v; want the URL origin", got)
+	}
+	if got := jwtExpiry(token).Unix(); got != expiry {
+		t.Errorf("ID token expiry %d; want %d", got, expiry)
+	}
+}
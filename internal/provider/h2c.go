@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/http2"
+)
+
+// h2cTransport sends http:// requests with HTTP/2 over cleartext, assuming
+// the server speaks it (prior knowledge, RFC 7540 section 3.4) rather than
+// negotiating an upgrade. https:// requests go to next, which negotiates
+// HTTP/2 with ALPN when http_version is "2".
+type h2cTransport struct {
+	h2   *http2.Transport
+	next http.RoundTripper
+}
+
+// newH2CTransport dials h2c connections the way tr dials, so DNS
+// recording, connect_to and local sockets still apply; proxies don't.
+func newH2CTransport(ctx context.Context, tr *http.Transport) *h2cTransport {
+	dial := tr.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	return &h2cTransport{
+		h2: &http2.Transport{
+			AllowHTTP:          true,
+			DisableCompression: tr.DisableCompression,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(ctx, network, addr)
+			},
+		},
+		next: tr,
+	}
+}
+
+func (t *h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.h2.RoundTrip(req)
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestH2CTransport(t *testing.T) {
+	var proto string
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto = r.Proto
+	}), &http2.Server{}))
+	defer server.Close()
+
+	dns := newDNSRecorder("127.0.0.1")
+	tr := &http.Transport{DialContext: dns.dialContext}
+	client := &http.Client{Transport: newH2CTransport(context.Background(), tr)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if proto != "HTTP/2.0" {
+		t.Errorf("server got %s; want HTTP/2.0", proto)
+	}
+
+	// Without prior knowledge the same server is spoken to with HTTP/1.1.
+	resp, err = (&http.Client{Transport: tr}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if proto != "HTTP/1.1" {
+		t.Errorf("server got %s; want HTTP/1.1", proto)
+	}
+}
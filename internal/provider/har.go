@@ -0,0 +1,282 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// harRecorder writes every request sent through a provider instance, with
+// its response, to an HTTP Archive (HAR 1.2) file. Credentials are redacted
+// as for captured requests, and the bodies of sensitive responses are left
+// out. The archive is kept valid after each entry, which is written over the
+// closing brackets, so it needs no flushing by Shutdown, which a plugin
+// killed by Terraform never reaches.
+type harRecorder struct {
+	mu      sync.Mutex
+	path    string
+	entries int
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	Cookies     []harNameValue `json:"cookies"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	Cookies     []harNameValue `json:"cookies"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time              `json:"startedDateTime"`
+	Time            float64                `json:"time"`
+	Request         harRequest             `json:"request"`
+	Response        harResponse            `json:"response"`
+	Cache           map[string]interface{} `json:"cache"`
+	Timings         harTimings             `json:"timings"`
+	Comment         string                 `json:"comment,omitempty"`
+}
+
+func newHARRecorder(path string) *harRecorder {
+	return &harRecorder{path: expandHome(path)}
+}
+
+// wrap returns a RoundTripper recording the traffic of rt. A nil recorder
+// returns rt unchanged.
+func (h *harRecorder) wrap(rt http.RoundTripper) http.RoundTripper {
+	if h == nil {
+		return rt
+	}
+	return &harTransport{recorder: h, next: rt}
+}
+
+type harTransport struct {
+	recorder *harRecorder
+	next     http.RoundTripper
+}
+
+func (t *harTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			reqBody, _ = ioutil.ReadAll(body)
+			body.Close()
+		}
+	}
+
+	started := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	waited := time.Since(started)
+
+	entry := harEntry{
+		StartedDateTime: started,
+		Request:         harRequestFor(req, reqBody),
+		Cache:           map[string]interface{}{},
+	}
+	if err != nil {
+		entry.Comment = err.Error()
+		entry.Time = msec(waited)
+		entry.Timings = harTimings{Wait: msec(waited)}
+		t.recorder.add(entry)
+		return nil, err
+	}
+
+	entry.Response = harResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Headers:     harHeaders(redactHeaders(resp.Header)),
+		Cookies:     []harNameValue{},
+		Content:     harContent{MimeType: resp.Header.Get("Content-Type")},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+	}
+	// The entry is written once the caller is done with the body, which
+	// is recorded as it is read rather than buffered up front.
+	resp.Body = &harBody{
+		ReadCloser: resp.Body,
+		policy:     recordingPolicyFrom(req.Context()),
+		done: func(body []byte, size int, comment string) {
+			received := time.Since(started) - waited
+			entry.Response.Content.Size = size
+			entry.Response.BodySize = size
+			entry.Response.Content.Text = string(redactBody(entry.Response.Content.MimeType, body))
+			entry.Comment = comment
+			entry.Time = msec(waited + received)
+			entry.Timings = harTimings{Wait: msec(waited), Receive: msec(received)}
+			t.recorder.add(entry)
+		},
+	}
+	return resp, nil
+}
+
+// harBody records up to policy.limit bytes of a response body as the caller
+// reads it, and hands them to done on Close.
+type harBody struct {
+	io.ReadCloser
+	policy recordingPolicy
+	done   func(body []byte, size int, comment string)
+
+	recorded []byte
+	size     int
+	once     sync.Once
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += n
+	if room := b.policy.limit - int64(len(b.recorded)); !b.policy.omitBody && room > 0 {
+		if int64(n) > room {
+			b.recorded = append(b.recorded, p[:room]...)
+		} else {
+			b.recorded = append(b.recorded, p[:n]...)
+		}
+	}
+	return n, err
+}
+
+func (b *harBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		var comment string
+		switch {
+		case b.policy.omitBody:
+			comment = "response body omitted: sensitive_response is set"
+		case int64(b.size) > b.policy.limit:
+			comment = fmt.Sprintf("response body truncated to %d bytes", b.policy.limit)
+		}
+		b.done(b.recorded, b.size, comment)
+	})
+	return err
+}
+
+func harRequestFor(req *http.Request, body []byte) harRequest {
+	query := []harNameValue{}
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			if isSensitiveName(name) {
+				value = redacted
+			}
+			query = append(query, harNameValue{Name: name, Value: value})
+		}
+	}
+	sort.Slice(query, func(i, j int) bool { return query[i].Name < query[j].Name })
+
+	r := harRequest{
+		Method:      req.Method,
+		URL:         redactURL(req.URL),
+		HTTPVersion: "HTTP/1.1",
+		Headers:     harHeaders(redactHeaders(req.Header)),
+		QueryString: query,
+		Cookies:     []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	if body != nil {
+		contentType := req.Header.Get("Content-Type")
+		r.PostData = &harPostData{MimeType: contentType, Text: string(redactBody(contentType, body))}
+	}
+	return r
+}
+
+func harHeaders(h http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for name, values := range h {
+		for _, value := range values {
+			headers = append(headers, harNameValue{Name: name, Value: value})
+		}
+	}
+	sort.Slice(headers, func(i, j int) bool { return headers[i].Name < headers[j].Name })
+	return headers
+}
+
+func msec(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// harTrailer closes the entries array and the document.
+const harTrailer = "\n    ]\n  }\n}\n"
+
+// add writes entry to the archive, creating it with the first entry and
+// otherwise writing over the trailer of the last one, so that the archive
+// stays a valid document without rewriting earlier entries. Failing to write
+// the archive never fails the request it records.
+func (h *harRecorder) add(entry harEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out, err := json.MarshalIndent(entry, "      ", "  ")
+	if err != nil {
+		return
+	}
+
+	if h.entries == 0 {
+		if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+			return
+		}
+		creator, _ := json.Marshal(map[string]string{"name": "terraform-provider-http-full", "version": "1.0.0"})
+		header := fmt.Sprintf("{\n  \"log\": {\n    \"version\": \"1.2\",\n    \"creator\": %s,\n    \"entries\": [\n      ", creator)
+		if err := ioutil.WriteFile(h.path, []byte(header+string(out)+harTrailer), 0600); err != nil {
+			return
+		}
+		h.entries++
+		return
+	}
+
+	f, err := os.OpenFile(h.path, os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if _, err := f.Seek(-int64(len(harTrailer)), io.SeekEnd); err != nil {
+		return
+	}
+	if _, err := f.WriteString(",\n      " + string(out) + harTrailer); err != nil {
+		return
+	}
+	h.entries++
+}
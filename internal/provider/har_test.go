@@ -0,0 +1,131 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHARRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Write([]byte("pong"))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "har")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "traffic.har")
+	client := &http.Client{Transport: newHARRecorder(path).wrap(http.DefaultTransport)}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/ping?access_token=secret&a=1", bytes.NewReader([]byte("ping")))
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "pong" {
+		t.Fatalf("response body is %q after recording; want pong", body)
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if strings.Contains(string(raw), "secret") {
+		t.Fatalf("archive leaks a credential:\n%s", raw)
+	}
+
+	var har struct {
+		Log struct {
+			Version string     `json:"version"`
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(raw, &har); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 1 {
+		t.Fatalf("got version %q with %d entries; want 1.2 with 1", har.Log.Version, len(har.Log.Entries))
+	}
+	entry := har.Log.Entries[0]
+	if entry.Request.Method != http.MethodPost || entry.Request.PostData == nil || entry.Request.PostData.Text != "ping" {
+		t.Errorf("unexpected request %+v", entry.Request)
+	}
+	if entry.Response.Status != http.StatusOK || entry.Response.Content.Text != "pong" {
+		t.Errorf("unexpected response %+v", entry.Response)
+	}
+}
+
+func TestHARRecorder_bodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "response-secret", "scope": "read"}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "har")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "traffic.har")
+	client := &http.Client{Transport: newHARRecorder(path).wrap(http.DefaultTransport)}
+
+	policies := []recordingPolicy{{}, {omitBody: true}, {limit: 4}}
+	for _, policy := range policies {
+		ctx := withRecordingPolicy(context.Background(), policy)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, strings.NewReader("client_id=a&client_secret=request-secret"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, secret := range []string{"request-secret", "response-secret"} {
+		if strings.Contains(string(raw), secret) {
+			t.Fatalf("archive leaks %q:\n%s", secret, raw)
+		}
+	}
+
+	var har struct {
+		Log struct {
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(raw, &har); err != nil {
+		t.Fatalf("archive is not valid JSON: %s\n%s", err, raw)
+	}
+	if len(har.Log.Entries) != len(policies) {
+		t.Fatalf("got %d entries; want %d", len(har.Log.Entries), len(policies))
+	}
+	if text := har.Log.Entries[0].Response.Content.Text; !strings.Contains(text, `"scope":"read"`) {
+		t.Errorf("redacted response body lost its other fields: %s", text)
+	}
+	if entry := har.Log.Entries[1]; entry.Response.Content.Text != "" || entry.Comment == "" {
+		t.Errorf("sensitive response recorded: %+v", entry.Response.Content)
+	}
+	if entry := har.Log.Entries[2]; entry.Response.Content.Text != `{"ac` || entry.Response.Content.Size <= 4 {
+		t.Errorf("got %+v; want the first 4 bytes of the body", entry.Response.Content)
+	}
+}
@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/itchyny/gojq"
+)
+
+// compileJQ parses and compiles a jq program. The program has no access to
+// the environment of the provider process.
+func compileJQ(program string) (*gojq.Code, error) {
+	query, err := gojq.Parse(program)
+	if err != nil {
+		return nil, err
+	}
+	return gojq.Compile(query)
+}
+
+func validateJQ(val interface{}, key string) (warns []string, errs []error) {
+	if _, err := compileJQ(val.(string)); err != nil {
+		errs = append(errs, fmt.Errorf("%s is not a valid jq program: %s", key, err))
+	}
+	return
+}
+
+// applyJQ runs program on the JSON body and returns its results as JSON,
+// one per line like the jq command line does; a program producing a single
+// value, the usual case, returns just that value.
+func applyJQ(ctx context.Context, program string, body []byte) ([]byte, error) {
+	code, err := compileJQ(program)
+	if err != nil {
+		return nil, err
+	}
+	var input interface{}
+	if err := json.Unmarshal(body, &input); err != nil {
+		return nil, fmt.Errorf("decoding JSON body: %s", err)
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	iter := code.RunWithContext(ctx, input)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		if err := encoder.Encode(v); err != nil {
+			return nil, err
+		}
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"context"
+	"testing"
+)
+
+func TestApplyJQ(t *testing.T) {
+	body := []byte(`{"items": [{"name": "a", "tags": ["x"]}, {"name": "b", "tags": ["y", "z"]}], "next": null}`)
+	cases := []struct {
+		name    string
+		program string
+		want    string
+	}{
+		{"identity", ".next", `null`},
+		{"select", `[.items[] | select(.name == "b") | .tags[]]`, `["y","z"]`},
+		{"flatten", `[.items[].tags] | flatten`, `["x","y","z"]`},
+		{"object", `{names: [.items[].name]}`, `{"names":["a","b"]}`},
+		{"several values", `.items[].name`, "\"a\"\n\"b\""},
+		{"no values", `empty`, ``},
+	}
+
+	for _, tc := range cases {
+		got, err := applyJQ(context.Background(), tc.program, body)
+		if err != nil {
+			t.Errorf("%s: err: %s", tc.name, err)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("%s: got %s; want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestApplyJQ_invalid(t *testing.T) {
+	if _, errs := validateJQ(".items[", "jq"); len(errs) == 0 {
+		t.Errorf("expected a validation error for a syntax error")
+	}
+	if _, err := applyJQ(context.Background(), `error("boom")`, []byte(`{}`)); err == nil {
+		t.Errorf("expected the error raised by the program")
+	}
+	if _, err := applyJQ(context.Background(), ".", []byte("<html>")); err == nil {
+		t.Errorf("expected an error for a non-JSON body")
+	}
+}
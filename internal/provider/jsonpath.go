@@ -0,0 +1,155 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPathStep is a single step of a JSONPath expression: an object member,
+// an array index (negative counts from the end) or a wildcard.
+type jsonPathStep struct {
+	name     string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parseJSONPath parses the subset of JSONPath used by json_extract: a
+// leading $ followed by .name, ['name'], [n], [*] and .* steps.
+func parseJSONPath(expr string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("JSONPath %q must start with $", expr)
+	}
+	var steps []jsonPathStep
+	rest := expr[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			if name == "" {
+				return nil, fmt.Errorf("JSONPath %q has an empty member name", expr)
+			}
+			steps = append(steps, jsonPathStep{name: name, wildcard: name == "*"})
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("JSONPath %q has an unterminated [", expr)
+			}
+			selector := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case selector == "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+				steps = append(steps, jsonPathStep{name: selector[1 : len(selector)-1]})
+			default:
+				index, err := strconv.Atoi(selector)
+				if err != nil {
+					return nil, fmt.Errorf("JSONPath %q has an unsupported selector [%s]", expr, selector)
+				}
+				steps = append(steps, jsonPathStep{index: index, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("JSONPath %q has an unexpected %q", expr, rest[0])
+		}
+	}
+	return steps, nil
+}
+
+// evalJSONPath returns the values matched by expr in doc. wildcard reports
+// whether the expression can match several values.
+func evalJSONPath(doc interface{}, expr string) (matches []interface{}, wildcard bool, err error) {
+	steps, err := parseJSONPath(expr)
+	if err != nil {
+		return nil, false, err
+	}
+	current := []interface{}{doc}
+	for _, step := range steps {
+		var next []interface{}
+		for _, v := range current {
+			switch v := v.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					keys := make([]string, 0, len(v))
+					for k := range v {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, v[k])
+					}
+				} else if member, ok := v[step.name]; ok && !step.isIndex {
+					next = append(next, member)
+				}
+			case []interface{}:
+				if step.wildcard {
+					next = append(next, v...)
+				} else if step.isIndex {
+					i := step.index
+					if i < 0 {
+						i += len(v)
+					}
+					if i >= 0 && i < len(v) {
+						next = append(next, v[i])
+					}
+				}
+			}
+		}
+		wildcard = wildcard || step.wildcard
+		current = next
+	}
+	return current, wildcard, nil
+}
+
+// jsonExtract evaluates the json_extract expressions against body. A single
+// match is returned as the string itself, or as JSON for other values;
+// wildcard expressions always return a JSON array of their matches.
+func jsonExtract(body []byte, expressions map[string]interface{}) (map[string]string, error) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("decoding JSON body: %s", err)
+	}
+	outputs := make(map[string]string, len(expressions))
+	for name, expr := range expressions {
+		matches, wildcard, err := evalJSONPath(doc, expr.(string))
+		if err != nil {
+			return nil, err
+		}
+		if wildcard {
+			if matches == nil {
+				matches = []interface{}{}
+			}
+			out, err := json.Marshal(matches)
+			if err != nil {
+				return nil, err
+			}
+			outputs[name] = string(out)
+			continue
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s: %q matched nothing", name, expr)
+		}
+		if s, ok := matches[0].(string); ok {
+			outputs[name] = s
+			continue
+		}
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(matches[0]); err != nil {
+			return nil, err
+		}
+		outputs[name] = strings.TrimSuffix(buf.String(), "\n")
+	}
+	return outputs, nil
+}
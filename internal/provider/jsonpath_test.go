@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"testing"
+)
+
+func TestJSONExtract(t *testing.T) {
+	body := []byte(`{
+		"metadata": {"name": "api", "labels": {"tier": "web", "app.kubernetes.io/name": "api"}},
+		"status": {"replicas": 3, "ready": true, "conditions": [{"type": "Available"}, {"type": "Progressing"}]},
+		"html": "<b>"
+	}`)
+	cases := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{"string", "$.metadata.name", "api"},
+		{"number", "$.status.replicas", "3"},
+		{"bool", "$['status']['ready']", "true"},
+		{"object", "$.metadata.labels", `{"app.kubernetes.io/name":"api","tier":"web"}`},
+		{"quoted member", `$.metadata.labels["app.kubernetes.io/name"]`, "api"},
+		{"index", "$.status.conditions[0].type", "Available"},
+		{"negative index", "$.status.conditions[-1].type", "Progressing"},
+		{"array wildcard", "$.status.conditions[*].type", `["Available","Progressing"]`},
+		{"member wildcard", "$.metadata.labels.*", `["api","web"]`},
+		{"empty wildcard", "$.status.missing[*]", `[]`},
+		{"unescaped", "$.html", "<b>"},
+		{"root", "$", `{"html":"<b>","metadata":{"labels":{"app.kubernetes.io/name":"api","tier":"web"},"name":"api"},"status":{"conditions":[{"type":"Available"},{"type":"Progressing"}],"ready":true,"replicas":3}}`},
+	}
+
+	for _, tc := range cases {
+		got, err := jsonExtract(body, map[string]interface{}{"v": tc.expr})
+		if err != nil {
+			t.Errorf("%s: err: %s", tc.name, err)
+			continue
+		}
+		if got["v"] != tc.want {
+			t.Errorf("%s: got %s; want %s", tc.name, got["v"], tc.want)
+		}
+	}
+}
+
+func TestJSONExtract_invalid(t *testing.T) {
+	body := []byte(`{"items": [1]}`)
+	for _, expr := range []string{"items", "$.missing", "$.items[1]", "$.items[x]", "$.items[0", "$..items"} {
+		if _, err := jsonExtract(body, map[string]interface{}{"v": expr}); err == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+	if _, err := jsonExtract([]byte("<html>"), map[string]interface{}{"v": "$"}); err == nil {
+		t.Errorf("expected an error for a non-JSON body")
+	}
+}
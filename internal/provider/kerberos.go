@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+)
+
+// newKerberosClient returns a Kerberos client for the kerberos_auth block:
+// logging in with the keytab when one is set, or else using the tickets of
+// the host's credential cache, as obtained with kinit.
+func newKerberosClient(auth map[string]interface{}) (*client.Client, error) {
+	confPath := auth["krb5_conf"].(string)
+	if confPath == "" {
+		confPath = os.Getenv("KRB5_CONFIG")
+	}
+	if confPath == "" {
+		confPath = "/etc/krb5.conf"
+	}
+	conf, err := config.Load(expandHome(confPath))
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %s", confPath, err)
+	}
+
+	if path := auth["keytab"].(string); path != "" {
+		username, realm, err := splitPrincipal(auth["principal"].(string))
+		if err != nil {
+			return nil, err
+		}
+		kt, err := keytab.Load(expandHome(path))
+		if err != nil {
+			return nil, fmt.Errorf("loading keytab %s: %s", path, err)
+		}
+		// Active Directory KDCs don't support FAST.
+		return client.NewWithKeytab(username, realm, kt, conf, client.DisablePAFXFAST(true)), nil
+	}
+
+	path := auth["ccache"].(string)
+	if path == "" {
+		path = defaultCCachePath()
+	}
+	ccache, err := credentials.LoadCCache(expandHome(path))
+	if err != nil {
+		return nil, fmt.Errorf("loading credential cache %s: %s", path, err)
+	}
+	return client.NewFromCCache(ccache, conf, client.DisablePAFXFAST(true))
+}
+
+// splitPrincipal splits a user@REALM principal.
+func splitPrincipal(principal string) (username, realm string, err error) {
+	i := strings.LastIndex(principal, "@")
+	if i <= 0 || i == len(principal)-1 {
+		return "", "", fmt.Errorf("principal %q must be of the form user@REALM", principal)
+	}
+	return principal[:i], principal[i+1:], nil
+}
+
+// defaultCCachePath returns the file credential cache named by KRB5CCNAME,
+// or else the default of MIT Kerberos.
+func defaultCCachePath() string {
+	if name := os.Getenv("KRB5CCNAME"); name != "" {
+		return strings.TrimPrefix(name, "FILE:")
+	}
+	return fmt.Sprintf("/tmp/krb5cc_%d", os.Getuid())
+}
+
+// spnegoTransport authenticates requests with SPNEGO (RFC 4559), sending a
+// fresh Kerberos service ticket for spn, by default HTTP/<host>, in the
+// Authorization header of every request.
+type spnegoTransport struct {
+	next   http.RoundTripper
+	client *client.Client
+	spn    string
+}
+
+func (t *spnegoTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if err := spnego.SetSPNEGOHeader(t.client, req, t.spn); err != nil {
+		return nil, fmt.Errorf("kerberos authentication: %s", err)
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitPrincipal(t *testing.T) {
+	cases := []struct {
+		principal string
+		username  string
+		realm     string
+		err       bool
+	}{
+		{"svc-terraform@CORP.EXAMPLE.COM", "svc-terraform", "CORP.EXAMPLE.COM", false},
+		{"HTTP/web.example.com@EXAMPLE.COM", "HTTP/web.example.com", "EXAMPLE.COM", false},
+		{"svc-terraform", "", "", true},
+		{"@EXAMPLE.COM", "", "", true},
+		{"svc-terraform@", "", "", true},
+	}
+	for _, tc := range cases {
+		username, realm, err := splitPrincipal(tc.principal)
+		if (err != nil) != tc.err {
+			t.Errorf("%s: got error %v", tc.principal, err)
+			continue
+		}
+		if username != tc.username || realm != tc.realm {
+			t.Errorf("%s: got %q, %q", tc.principal, username, realm)
+		}
+	}
+}
+
+func TestNewKerberosClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krb5")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	conf := filepath.Join(dir, "krb5.conf")
+	if err := ioutil.WriteFile(conf, []byte("[libdefaults]\n  default_realm = EXAMPLE.COM\n"), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	auth := func(ccache, keytab, principal string) map[string]interface{} {
+		return map[string]interface{}{"krb5_conf": conf, "ccache": ccache, "keytab": keytab, "principal": principal, "spn": ""}
+	}
+	if _, err := newKerberosClient(auth(filepath.Join(dir, "missing"), "", "")); err == nil || !strings.Contains(err.Error(), "credential cache") {
+		t.Errorf("missing credential cache: got %v", err)
+	}
+	if _, err := newKerberosClient(auth("", filepath.Join(dir, "missing"), "svc@EXAMPLE.COM")); err == nil || !strings.Contains(err.Error(), "keytab") {
+		t.Errorf("missing keytab: got %v", err)
+	}
+	if _, err := newKerberosClient(map[string]interface{}{"krb5_conf": filepath.Join(dir, "missing.conf")}); err == nil {
+		t.Errorf("missing krb5.conf: got no error")
+	}
+}
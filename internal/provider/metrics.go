@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the latency histogram.
+var latencyBuckets = []time.Duration{
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// metrics counts the requests sent by a provider instance, so platform
+// teams can monitor how much external API traffic Terraform generates.
+// Every request is sent to a statsd server when one is configured, and a
+// summary of the run so far can be logged whenever the provider falls idle.
+// A nil *metrics records nothing.
+type metrics struct {
+	prefix string
+	conn   net.Conn
+
+	mu       sync.Mutex
+	requests int64
+	errors   int64
+	retries  int64
+	buckets  []int64
+
+	// idle logs the summary once no request has been sent for
+	// summaryDelay; logged is the number of requests it last covered.
+	idle   *time.Timer
+	logged int64
+}
+
+// summaryDelay is how long a provider instance must send no requests before
+// the summary of its metrics is logged. The summary must be logged while
+// Terraform still reads the provider's output, which it stops doing when it
+// shuts the provider down at the end of a run.
+var summaryDelay = time.Second
+
+func newMetrics(statsdAddress, prefix string, logSummary bool) (*metrics, error) {
+	m := &metrics{
+		prefix:  prefix,
+		buckets: make([]int64, len(latencyBuckets)+1),
+	}
+	if statsdAddress != "" {
+		conn, err := net.Dial("udp", statsdAddress)
+		if err != nil {
+			return nil, err
+		}
+		m.conn = conn
+	}
+	if logSummary {
+		m.idle = time.AfterFunc(summaryDelay, m.logSummary)
+		m.idle.Stop()
+		summarized.Lock()
+		summarized.metrics = append(summarized.metrics, m)
+		summarized.Unlock()
+	}
+	return m, nil
+}
+
+// summarized holds the metrics whose summary is logged by
+// logMetricsSummaries.
+var summarized struct {
+	sync.Mutex
+	metrics []*metrics
+}
+
+// logMetricsSummaries logs the summary of every metrics configured with
+// log_summary that has seen requests since its summary was last logged. It
+// is called by Shutdown, which a killed provider never reaches.
+func logMetricsSummaries() {
+	summarized.Lock()
+	defer summarized.Unlock()
+	for _, m := range summarized.metrics {
+		m.idle.Stop()
+		m.logSummary()
+	}
+	summarized.metrics = nil
+}
+
+// logSummary logs the summary of m, unless it covers no new requests.
+func (m *metrics) logSummary() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.requests == m.logged {
+		return
+	}
+	m.logged = m.requests
+	log.Printf("[INFO] http-full metrics: %s", m.summary())
+}
+
+// observe records a request that took latency, failed or not, and whether
+// it was a retry.
+func (m *metrics) observe(latency time.Duration, failed, retry bool) {
+	m.mu.Lock()
+	m.requests++
+	lines := []string{fmt.Sprintf("%s.requests:1|c", m.prefix), fmt.Sprintf("%s.latency:%d|ms", m.prefix, latency/time.Millisecond)}
+	if failed {
+		m.errors++
+		lines = append(lines, fmt.Sprintf("%s.errors:1|c", m.prefix))
+	}
+	if retry {
+		m.retries++
+		lines = append(lines, fmt.Sprintf("%s.retries:1|c", m.prefix))
+	}
+	i := 0
+	for i < len(latencyBuckets) && latency > latencyBuckets[i] {
+		i++
+	}
+	m.buckets[i]++
+	if m.idle != nil {
+		m.idle.Reset(summaryDelay)
+	}
+	m.mu.Unlock()
+
+	if m.conn != nil {
+		// Metrics are best effort; a lost datagram never fails a request.
+		m.conn.Write([]byte(strings.Join(lines, "\n")))
+	}
+}
+
+// summary formats the totals so far. m.mu must be held.
+func (m *metrics) summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "requests=%d errors=%d retries=%d latency", m.requests, m.errors, m.retries)
+	for i, bound := range latencyBuckets {
+		fmt.Fprintf(&b, " le_%s=%d", bound, m.buckets[i])
+	}
+	fmt.Fprintf(&b, " le_inf=%d", m.buckets[len(latencyBuckets)])
+	return b.String()
+}
+
+// wrap returns a RoundTripper recording the requests of rt. A nil metrics
+// returns rt unchanged.
+func (m *metrics) wrap(rt http.RoundTripper) http.RoundTripper {
+	if m == nil {
+		return rt
+	}
+	return &metricsTransport{metrics: m, next: rt}
+}
+
+type metricsTransport struct {
+	metrics *metrics
+	next    http.RoundTripper
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	started := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	t.metrics.observe(time.Since(started), err != nil || resp.StatusCode >= 500, isRetry(req))
+	return resp, err
+}
@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	statsd, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer statsd.Close()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	m, err := newMetrics(statsd.LocalAddr().String(), "tf", false)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	client := &http.Client{Transport: m.wrap(http.DefaultTransport)}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	policy := retryPolicy{attempts: 2, minDelay: time.Millisecond, maxDelay: time.Millisecond}
+	resp, err := doWithRetry(context.Background(), client, req, &policy, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+
+	m.mu.Lock()
+	requests, errors, retries := m.requests, m.errors, m.retries
+	summary := m.summary()
+	m.mu.Unlock()
+	if requests != 2 || errors != 1 || retries != 1 {
+		t.Fatalf("got %d requests, %d errors, %d retries; want 2, 1, 1", requests, errors, retries)
+	}
+	if !strings.HasPrefix(summary, "requests=2 errors=1 retries=1 ") || !strings.Contains(summary, "le_100ms=2") {
+		t.Errorf("unexpected summary %q", summary)
+	}
+
+	var received []string
+	buf := make([]byte, 1024)
+	statsd.SetReadDeadline(time.Now().Add(time.Second))
+	for len(received) < 2 {
+		n, _, err := statsd.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		received = append(received, string(buf[:n]))
+	}
+	if !strings.Contains(received[0], "tf.errors:1|c") || !strings.Contains(received[1], "tf.retries:1|c") {
+		t.Errorf("unexpected statsd datagrams %q", received)
+	}
+
+	var nilMetrics *metrics
+	if rt := nilMetrics.wrap(http.DefaultTransport); rt != http.DefaultTransport {
+		t.Errorf("nil metrics wrapped the transport")
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for the log writes of timers.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLogMetricsSummaries(t *testing.T) {
+	var logged syncBuffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	defer func(delay time.Duration) { summaryDelay = delay }(summaryDelay)
+	summaryDelay = 50 * time.Millisecond
+
+	m, err := newMetrics("", "tf", true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	m.observe(time.Millisecond, false, false)
+	m.observe(time.Millisecond, true, true)
+
+	// Logged while the provider is still serving, once it is idle.
+	const summary = "[INFO] http-full metrics: requests=2 errors=1 retries=1 "
+	for deadline := time.Now().Add(5 * time.Second); !strings.Contains(logged.String(), summary) && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !strings.Contains(logged.String(), summary) {
+		t.Fatalf("summary not logged while idle:\n%s", logged.String())
+	}
+
+	// Shutdown logs the requests since, once.
+	summaryDelay = time.Hour
+	m.observe(time.Millisecond, false, false)
+	logMetricsSummaries()
+	logMetricsSummaries()
+	if got := strings.Count(logged.String(), "[INFO] http-full metrics: requests=3 "); got != 1 {
+		t.Errorf("logged the final summary %d times; want once:\n%s", got, logged.String())
+	}
+	if got := strings.Count(logged.String(), "http-full metrics:"); got != 2 {
+		t.Errorf("logged %d summaries; want 2:\n%s", got, logged.String())
+	}
+}
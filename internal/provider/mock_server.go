@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// dataSourceMockServer starts a local HTTP server answering from a
+// declarative route table. It keeps running for the lifetime of the provider
+// process, so that modules built on this provider can be exercised with
+// `terraform test` without any external dependency. Terraform starts a new
+// provider process for apply, so the port is fixed: the URL recorded in the
+// plan stays the same, even though it only answers while a provider process
+// that read the data source is running.
+func dataSourceMockServer() *schema.Resource {
+	return &schema.Resource{
+		Description: "Starts a local mock HTTP server for tests.",
+
+		ReadContext: dataSourceMockServerRead,
+
+		Schema: map[string]*schema.Schema{
+			"port": {
+				Type:         schema.TypeInt,
+				Required:     true,
+				ValidateFunc: validation.IntBetween(1, 65535),
+			},
+
+			"route": {
+				Type:     schema.TypeList,
+				Required: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"path": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"method": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"status": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      http.StatusOK,
+							ValidateFunc: validation.IntBetween(100, 599),
+						},
+						"body": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"response_headers": {
+							Type:     schema.TypeMap,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+
+			"url": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+type mockRoute struct {
+	Path    string            `json:"path"`
+	Method  string            `json:"method"`
+	Status  int               `json:"status"`
+	Body    string            `json:"body"`
+	Headers map[string]string `json:"headers"`
+}
+
+// mockServers keeps the started servers so that reading the same data source
+// again within a run reuses its server instead of binding a new one, and so
+// that they are closed when the provider stops.
+var mockServers = struct {
+	sync.Mutex
+	servers map[string]*mockServer
+}{servers: map[string]*mockServer{}}
+
+type mockServer struct {
+	url    string
+	server *http.Server
+}
+
+// serveMockRoutes answers with the first route matching the path and method.
+func serveMockRoutes(routes []mockRoute) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, route := range routes {
+			if route.Path != r.URL.Path || (route.Method != "" && route.Method != r.Method) {
+				continue
+			}
+			for name, value := range route.Headers {
+				w.Header().Set(name, value)
+			}
+			w.WriteHeader(route.Status)
+			fmt.Fprint(w, route.Body)
+			return
+		}
+		http.NotFound(w, r)
+	})
+}
+
+// startMockServer starts a server for routes on port unless an identical one
+// is already running, and returns its URL.
+func startMockServer(port int, routes []mockRoute) (string, string, error) {
+	key, err := json.Marshal(struct {
+		Port   int         `json:"port"`
+		Routes []mockRoute `json:"routes"`
+	}{port, routes})
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256(key)
+	id := hex.EncodeToString(sum[:])
+
+	mockServers.Lock()
+	defer mockServers.Unlock()
+	if running, ok := mockServers.servers[id]; ok {
+		return id, running.url, nil
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return "", "", err
+	}
+	server := &http.Server{Handler: serveMockRoutes(routes)}
+	go server.Serve(listener)
+
+	url := "http://" + listener.Addr().String()
+	mockServers.servers[id] = &mockServer{url: url, server: server}
+	return id, url, nil
+}
+
+// closeMockServers stops the servers started by http_mock_server data
+// sources.
+func closeMockServers() {
+	mockServers.Lock()
+	defer mockServers.Unlock()
+	for id, running := range mockServers.servers {
+		running.server.Close()
+		delete(mockServers.servers, id)
+	}
+}
+
+func dataSourceMockServerRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var routes []mockRoute
+	for _, r := range d.Get("route").([]interface{}) {
+		route := r.(map[string]interface{})
+		headers := map[string]string{}
+		for name, value := range route["response_headers"].(map[string]interface{}) {
+			headers[name] = value.(string)
+		}
+		routes = append(routes, mockRoute{
+			Path:    route["path"].(string),
+			Method:  route["method"].(string),
+			Status:  route["status"].(int),
+			Body:    route["body"].(string),
+			Headers: headers,
+		})
+	}
+
+	id, url, err := startMockServer(d.Get("port").(int), routes)
+	if err != nil {
+		return diag.Errorf("Error starting mock server: %s", err)
+	}
+
+	d.Set("url", url)
+	d.SetId(id)
+	return nil
+}
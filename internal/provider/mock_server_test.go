@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestStartMockServer(t *testing.T) {
+	routes := []mockRoute{
+		{Path: "/items", Method: http.MethodPost, Status: http.StatusCreated, Body: "created"},
+		{Path: "/items", Status: http.StatusOK, Body: `["a"]`, Headers: map[string]string{"Content-Type": "application/json"}},
+	}
+	id, url, err := startMockServer(0, routes)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	resp, err := http.Get(url + "/items")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != `["a"]` || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("GET got %d %q (%s)", resp.StatusCode, body, resp.Header.Get("Content-Type"))
+	}
+
+	resp, err = http.Post(url+"/items", "text/plain", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST got %d; want 201", resp.StatusCode)
+	}
+
+	resp, err = http.Get(url + "/missing")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown path got %d; want 404", resp.StatusCode)
+	}
+
+	againID, againURL, err := startMockServer(0, routes)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if againID != id || againURL != url {
+		t.Fatalf("identical routes started a second server at %s", againURL)
+	}
+}
+
+func TestCloseMockServers(t *testing.T) {
+	_, url, err := startMockServer(0, []mockRoute{{Path: "/", Status: http.StatusOK}})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	closeMockServers()
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	if resp, err := client.Get(url); err == nil {
+		resp.Body.Close()
+		t.Fatalf("server still answers after closeMockServers")
+	}
+}
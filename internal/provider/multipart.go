@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+// formBody encodes request_form as an application/x-www-form-urlencoded
+// body, with keys in sorted order.
+func formBody(form map[string]interface{}) []byte {
+	values := url.Values{}
+	for key, value := range form {
+		values.Set(key, value.(string))
+	}
+	return []byte(values.Encode())
+}
+
+// jsonBody encodes request_body_json as a JSON object. Terraform turns
+// numbers and booleans in a map into strings, so values that are valid JSON,
+// such as 3, true, null or the result of jsonencode(), are embedded as JSON
+// and keep their type; all other values are strings. A string that looks
+// like JSON is sent as one with jsonencode("3"). Keys are sorted, so the
+// body does not change between runs.
+func jsonBody(object map[string]interface{}) ([]byte, error) {
+	fields := make(map[string]json.RawMessage, len(object))
+	for key, value := range object {
+		value := value.(string)
+		trimmed := strings.TrimSpace(value)
+		if trimmed != "" && json.Valid([]byte(trimmed)) {
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, []byte(trimmed)); err != nil {
+				return nil, err
+			}
+			fields[key] = buf.Bytes()
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		fields[key] = encoded
+	}
+	return json.Marshal(fields)
+}
+
+// multipartBody encodes the multipart blocks of a data source as a
+// multipart/form-data body and returns it with its Content-Type. Each part
+// is a plain form field, or a file upload when it has a filename; its
+// content is given inline or read from source_file.
+func multipartBody(parts []interface{}) ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for i, p := range parts {
+		part := p.(map[string]interface{})
+		field := part["field"].(string)
+		content, sourceFile := part["content"].(string), part["source_file"].(string)
+		if (content == "") == (sourceFile == "") {
+			return nil, "", fmt.Errorf("multipart[%d]: exactly one of content and source_file must be set", i)
+		}
+		data := []byte(content)
+		if sourceFile != "" {
+			var err error
+			if data, err = ioutil.ReadFile(expandHome(sourceFile)); err != nil {
+				return nil, "", fmt.Errorf("multipart[%d]: %s", i, err)
+			}
+		}
+
+		disposition := fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(field))
+		header := textproto.MIMEHeader{}
+		if filename := part["filename"].(string); filename != "" {
+			disposition += fmt.Sprintf(`; filename="%s"`, escapeQuotes(filename))
+			header.Set("Content-Type", "application/octet-stream")
+		}
+		header.Set("Content-Disposition", disposition)
+		if contentType := part["content_type"].(string); contentType != "" {
+			header.Set("Content-Type", contentType)
+		}
+
+		pw, err := w.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := pw.Write(data); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// escapeQuotes escapes a Content-Disposition parameter as mime/multipart
+// does.
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormBody(t *testing.T) {
+	got := string(formBody(map[string]interface{}{
+		"grant_type": "password",
+		"username":   "admin@example.com",
+		"scope":      "read write",
+	}))
+	want := "grant_type=password&scope=read+write&username=admin%40example.com"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestJSONBody(t *testing.T) {
+	got, err := jsonBody(map[string]interface{}{
+		"name":     "api",
+		"replicas": "3",
+		"labels":   `{"tier": "web", "env": "prod"}`,
+		"ports":    "[80, 443]",
+		"comment":  "{not json",
+		"enabled":  "true",
+		"ratio":    "0.5",
+		"owner":    "null",
+		"version":  `"3"`,
+		"empty":    "",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	want := `{"comment":"{not json","empty":"","enabled":true,"labels":{"tier":"web","env":"prod"},"name":"api","owner":null,"ports":[80,443],"ratio":0.5,"replicas":3,"version":"3"}`
+	if string(got) != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+}
+
+func TestMultipartBody(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multipart")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "cert.pem")
+	if err := ioutil.WriteFile(source, []byte("-----BEGIN CERTIFICATE-----"), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	body, contentType, err := multipartBody([]interface{}{
+		map[string]interface{}{"field": "overwrite", "filename": "", "content": "true", "source_file": "", "content_type": ""},
+		map[string]interface{}{"field": "certificate", "filename": "cert.pem", "content": "", "source_file": source, "content_type": "application/x-pem-file"},
+		map[string]interface{}{"field": "dashboard", "filename": "dash.json", "content": `{"title": "api"}`, "source_file": "", "content_type": ""},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/upload", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := req.FormValue("overwrite"); got != "true" {
+		t.Errorf("overwrite: got %q; want true", got)
+	}
+	files := req.MultipartForm.File
+	if cert := files["certificate"][0]; cert.Filename != "cert.pem" || cert.Header.Get("Content-Type") != "application/x-pem-file" {
+		t.Errorf("certificate: got filename %q, type %q", cert.Filename, cert.Header.Get("Content-Type"))
+	}
+	if dash := files["dashboard"][0]; dash.Header.Get("Content-Type") != "application/octet-stream" {
+		t.Errorf("dashboard: got type %q; want the default for files", dash.Header.Get("Content-Type"))
+	}
+
+	_, _, err = multipartBody([]interface{}{
+		map[string]interface{}{"field": "f", "filename": "", "content": "", "source_file": "", "content_type": ""},
+	})
+	if err == nil {
+		t.Errorf("part without content: got no error")
+	}
+}
@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// defaultNetrcPath is the netrc file read by curl and wget.
+const defaultNetrcPath = "~/.netrc"
+
+// netrcEntry is a machine, or the default entry when machine is empty, of a
+// netrc file.
+type netrcEntry struct {
+	machine  string
+	login    string
+	password string
+}
+
+// parseNetrc parses the entries of a netrc file as curl does: tokens
+// separated by white space, including new lines, macro definitions skipped
+// up to the next blank line and nothing read after the default entry.
+func parseNetrc(data string) []netrcEntry {
+	var tokens []string
+	lines := strings.Split(data, "\n")
+	for i := 0; i < len(lines); i++ {
+		for _, field := range strings.Fields(lines[i]) {
+			if field == "macdef" {
+				for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+					i++
+				}
+				break
+			}
+			tokens = append(tokens, field)
+		}
+	}
+
+	var entries []netrcEntry
+	for i := 0; i < len(tokens); i++ {
+		switch token := tokens[i]; {
+		case token == "machine" && i+1 < len(tokens):
+			if len(entries) > 0 && entries[len(entries)-1].machine == "" {
+				return entries
+			}
+			i++
+			entries = append(entries, netrcEntry{machine: tokens[i]})
+		case token == "default":
+			entries = append(entries, netrcEntry{})
+		case (token == "login" || token == "password" || token == "account") && i+1 < len(tokens):
+			i++
+			if len(entries) == 0 {
+				continue
+			}
+			entry := &entries[len(entries)-1]
+			if token == "login" {
+				entry.login = tokens[i]
+			} else if token == "password" {
+				entry.password = tokens[i]
+			}
+		}
+	}
+	return entries
+}
+
+// netrcCredentials returns the login and password for host in the netrc file
+// at path, or those of its default entry. A missing file is only an error
+// when required, that is when the path was configured.
+func netrcCredentials(path, host string, required bool) (login, password string, ok bool, err error) {
+	data, err := ioutil.ReadFile(expandHome(path))
+	if os.IsNotExist(err) && !required {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, err
+	}
+	var fallback *netrcEntry
+	entries := parseNetrc(string(data))
+	for i, entry := range entries {
+		if entry.machine == "" {
+			if fallback == nil {
+				fallback = &entries[i]
+			}
+			continue
+		}
+		if strings.EqualFold(entry.machine, host) {
+			return entry.login, entry.password, true, nil
+		}
+	}
+	if fallback != nil {
+		return fallback.login, fallback.password, true, nil
+	}
+	return "", "", false, nil
+}
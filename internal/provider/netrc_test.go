@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNetrcCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netrc")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, ".netrc")
+	netrc := `machine artifacts.example.com login deploy password s3cr3t
+
+macdef init
+machine ignored login x password y
+
+machine
+  repo.example.com
+  login reader
+  account ops
+  password r34d
+
+default login anonymous password guest
+machine after.example.com login no password no
+`
+	if err := ioutil.WriteFile(path, []byte(netrc), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		host     string
+		login    string
+		password string
+	}{
+		{"artifacts.example.com", "deploy", "s3cr3t"},
+		{"REPO.example.com", "reader", "r34d"},
+		{"ignored", "anonymous", "guest"},
+		{"after.example.com", "anonymous", "guest"},
+	}
+	for _, tc := range cases {
+		login, password, ok, err := netrcCredentials(path, tc.host, true)
+		if err != nil || !ok {
+			t.Errorf("%s: got %v, %v", tc.host, ok, err)
+			continue
+		}
+		if login != tc.login || password != tc.password {
+			t.Errorf("%s: got %s:%s; want %s:%s", tc.host, login, password, tc.login, tc.password)
+		}
+	}
+
+	missing := filepath.Join(dir, "missing")
+	if _, _, ok, err := netrcCredentials(missing, "artifacts.example.com", false); ok || err != nil {
+		t.Errorf("missing default file: got %v, %v; want no credentials", ok, err)
+	}
+	if _, _, _, err := netrcCredentials(missing, "artifacts.example.com", true); err == nil {
+		t.Errorf("missing configured file: got no error")
+	}
+}
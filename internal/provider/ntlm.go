@@ -0,0 +1,30 @@
+package provider
+
+import (
+	"net/http"
+
+	"github.com/Azure/go-ntlmssp"
+)
+
+// ntlmTransport authenticates requests with NTLM, or Negotiate falling back
+// to NTLM, for Windows servers such as IIS, Exchange or SharePoint. The
+// handshake needs the challenge and authenticated request to be sent on the
+// same connection, which keep-alive connections of next provide.
+type ntlmTransport struct {
+	next     http.RoundTripper
+	domain   string
+	username string
+	password string
+}
+
+func (t *ntlmTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The negotiator takes the credentials from a Basic Authorization
+	// header, with the domain as a DOMAIN\user prefix, and replaces it.
+	username := t.username
+	if t.domain != "" {
+		username = t.domain + `\` + username
+	}
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(username, t.password)
+	return ntlmssp.Negotiator{RoundTripper: t.next}.RoundTrip(req)
+}
@@ -0,0 +1,112 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+func testUTF16(s string) []byte {
+	var b bytes.Buffer
+	for _, r := range utf16.Encode([]rune(s)) {
+		binary.Write(&b, binary.LittleEndian, r)
+	}
+	return b.Bytes()
+}
+
+// testNTLMChallenge is a CHALLENGE message for domain CORP, negotiating
+// Unicode and NTLM.
+func testNTLMChallenge(serverChallenge []byte) []byte {
+	target := testUTF16("CORP")
+	var b bytes.Buffer
+	b.WriteString("NTLMSSP\x00")
+	binary.Write(&b, binary.LittleEndian, uint32(2))
+	binary.Write(&b, binary.LittleEndian, []uint16{uint16(len(target)), uint16(len(target))})
+	binary.Write(&b, binary.LittleEndian, uint32(48))
+	binary.Write(&b, binary.LittleEndian, uint32(0x00000205))
+	b.Write(serverChallenge)
+	b.Write(make([]byte, 8))
+	binary.Write(&b, binary.LittleEndian, []uint16{0, 0})
+	binary.Write(&b, binary.LittleEndian, uint32(48+len(target)))
+	b.Write(target)
+	return b.Bytes()
+}
+
+// testNTLMField returns the payload of the field of an NTLM message whose
+// length and offset are at index.
+func testNTLMField(message []byte, index int) []byte {
+	length := binary.LittleEndian.Uint16(message[index:])
+	offset := binary.LittleEndian.Uint32(message[index+4:])
+	return message[offset : offset+uint32(length)]
+}
+
+func TestNTLMTransport(t *testing.T) {
+	serverChallenge := []byte("12345678")
+	var steps []string
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		auth := r.Header.Get("Authorization")
+		switch {
+		case auth == "":
+			steps = append(steps, "anonymous")
+			w.Header().Set("WWW-Authenticate", "NTLM")
+			w.WriteHeader(http.StatusUnauthorized)
+		case strings.HasPrefix(auth, "NTLM "):
+			message, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "NTLM "))
+			switch message[8] {
+			case 1:
+				steps = append(steps, "negotiate")
+				w.Header().Set("WWW-Authenticate", "NTLM "+base64.StdEncoding.EncodeToString(testNTLMChallenge(serverChallenge)))
+				w.WriteHeader(http.StatusUnauthorized)
+			case 3:
+				steps = append(steps, "authenticate")
+				// Check the NTLMv2 response against the password.
+				user := testNTLMField(message, 36)
+				response := testNTLMField(message, 20)
+				md := md4.New()
+				md.Write(testUTF16("secret"))
+				key := hmac.New(md5.New, md.Sum(nil))
+				key.Write(testUTF16("ADMIN"))
+				key.Write(testUTF16("CORP"))
+				proof := hmac.New(md5.New, key.Sum(nil))
+				proof.Write(serverChallenge)
+				proof.Write(response[16:])
+				if !bytes.Equal(user, testUTF16("admin")) || !hmac.Equal(proof.Sum(nil), response[:16]) {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				body = string(data)
+			}
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &ntlmTransport{next: http.DefaultTransport, domain: "CORP", username: "admin", password: "secret"}}
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d; want 200", resp.StatusCode)
+	}
+	if got := strings.Join(steps, ","); got != "anonymous,negotiate,authenticate" {
+		t.Errorf("got handshake %s", got)
+	}
+	if body != "payload" {
+		t.Errorf("got body %q; want the body sent with the authenticated request", body)
+	}
+}
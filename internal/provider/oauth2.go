@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// oauth2Token returns an access token obtained with the OAuth2 client
+// credentials grant described by an oauth2 block. Tokens are shared through
+// the provider's token store, so data sources using the same client only
+// log in once per run (or once per cache lifetime with token_cache).
+func oauth2Token(ctx context.Context, config *providerConfig, client *http.Client, block map[string]interface{}) (string, error) {
+	cc := &clientcredentials.Config{
+		ClientID:     block["client_id"].(string),
+		ClientSecret: block["client_secret"].(string),
+		TokenURL:     block["token_url"].(string),
+	}
+	for _, scope := range block["scopes"].([]interface{}) {
+		cc.Scopes = append(cc.Scopes, scope.(string))
+	}
+	if params := block["endpoint_params"].(map[string]interface{}); len(params) > 0 {
+		cc.EndpointParams = map[string][]string{}
+		for name, value := range params {
+			cc.EndpointParams.Set(name, value.(string))
+		}
+	}
+
+	key := strings.Join([]string{"oauth2", cc.TokenURL, cc.ClientID, strings.Join(cc.Scopes, " "), cc.EndpointParams.Encode()}, "|")
+	return config.tokens.token(ctx, key, func(ctx context.Context) (string, time.Time, error) {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, client)
+		token, err := cc.Token(ctx)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		return token.AccessToken, token.Expiry, nil
+	})
+}
+
+// tokenClient returns the client token endpoints are called with. Like the
+// request itself it uses the TLS settings of the data source, ca included,
+// and its proxy_url or else the provider's proxy.
+func tokenClient(ctx context.Context, d *schema.ResourceData, config *providerConfig) (*http.Client, error) {
+	tlsConfig, diags := newTLSConfig(ctx, d, config)
+	if diags.HasError() {
+		return nil, fmt.Errorf("%s", diags[0].Summary)
+	}
+	tr, err := config.baseTransport(tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := d.GetOk("proxy_url"); ok {
+		if err := configureProxy(tr, newDNSRecorder(""), v.(string)); err != nil {
+			return nil, err
+		}
+	}
+	return &http.Client{Transport: config.wrapTransport(tr), Timeout: config.requestTimeout}, nil
+}
@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestOAuth2Token(t *testing.T) {
+	var logins int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&logins, 1)
+		user, password, _ := r.BasicAuth()
+		if user != "client" || password != "secret" || r.FormValue("grant_type") != "client_credentials" ||
+			r.FormValue("scope") != "read write" || r.FormValue("audience") != "api" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "t0k3n",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}))
+	defer server.Close()
+
+	config := configFromMeta(nil)
+	block := map[string]interface{}{
+		"token_url":       server.URL,
+		"client_id":       "client",
+		"client_secret":   "secret",
+		"scopes":          []interface{}{"read", "write"},
+		"endpoint_params": map[string]interface{}{"audience": "api"},
+	}
+	for i := 0; i < 3; i++ {
+		token, err := oauth2Token(context.Background(), config, http.DefaultClient, block)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if token != "t0k3n" {
+			t.Fatalf("got token %q; want t0k3n", token)
+		}
+	}
+	if n := atomic.LoadInt32(&logins); n != 1 {
+		t.Errorf("token endpoint called %d times; want 1", n)
+	}
+
+	block["client_secret"] = "wrong"
+	block["client_id"] = "other"
+	if _, err := oauth2Token(context.Background(), config, http.DefaultClient, block); err == nil {
+		t.Errorf("rejected client credentials: got a token")
+	}
+}
+
+func TestNewRequest_oauth2TokenClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "tls-token", "token_type": "Bearer"})
+	}))
+	defer server.Close()
+	ca := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	oauth2 := []interface{}{map[string]interface{}{
+		"token_url":     server.URL + "/token",
+		"client_id":     "ca-client",
+		"client_secret": "secret",
+	}}
+	d := schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{
+		"url":    server.URL,
+		"ca":     ca,
+		"oauth2": oauth2,
+	})
+	req, _, diags := newRequest(context.Background(), d, configFromMeta(nil))
+	if diags.HasError() {
+		t.Fatalf("token endpoint not trusted through ca: %v", diags)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer tls-token" {
+		t.Errorf("got Authorization %q; want Bearer tls-token", got)
+	}
+
+	d = schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{
+		"url":       server.URL,
+		"ca":        ca,
+		"proxy_url": "http://127.0.0.1:1",
+		"oauth2":    []interface{}{map[string]interface{}{"token_url": server.URL + "/token", "client_id": "proxied-client", "client_secret": "secret"}},
+	})
+	if _, _, diags := newRequest(context.Background(), d, configFromMeta(nil)); !diags.HasError() {
+		t.Errorf("token request bypassed proxy_url")
+	}
+}
+
+func TestOAuth2Token_rejected(t *testing.T) {
+	var logins int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			n := atomic.AddInt32(&logins, 1)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": fmt.Sprintf("token-%d", n), "token_type": "Bearer", "expires_in": 3600})
+			return
+		}
+		// token-1 was revoked.
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	d := schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{
+		"url":    server.URL + "/api",
+		"oauth2": []interface{}{map[string]interface{}{"token_url": server.URL + "/token", "client_id": "revoked", "client_secret": "secret"}},
+	})
+	if diags := dataSourceRead(context.Background(), d, configFromMeta(nil)); diags.HasError() {
+		t.Fatalf("err: %v", diags)
+	}
+	if d.Get("status_code").(int) != http.StatusOK || d.Get("body").(string) != "ok" {
+		t.Errorf("got %d %q; want the request retried with a new token", d.Get("status_code"), d.Get("body"))
+	}
+	if n := atomic.LoadInt32(&logins); n != 2 {
+		t.Errorf("token endpoint called %d times; want 2", n)
+	}
+}
@@ -0,0 +1,119 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/crypto/ocsp"
+)
+
+// ocspModes are the values of the ocsp attribute: off, or checking the
+// server certificate's revocation status and failing when it is revoked
+// (soft_fail) or whenever it is not known to be good (hard_fail).
+var ocspModes = []string{"off", "soft_fail", "hard_fail"}
+
+// ocspTimeout bounds queries to OCSP responders, which are made during the
+// handshake.
+const ocspTimeout = 10 * time.Second
+
+// maxOCSPResponseSize bounds the responses read from OCSP responders, which
+// are a few kilobytes at most.
+const maxOCSPResponseSize = 64 << 10
+
+// ocspClient returns the client querying OCSP responders, which goes through
+// the proxy_url of d or else the provider's proxy, as the request does.
+func ocspClient(d *schema.ResourceData, defaults *providerConfig) (*http.Client, error) {
+	tr := &http.Transport{}
+	dns := newDNSRecorder("")
+	if v, ok := d.GetOk("proxy_url"); ok {
+		if err := configureProxy(tr, dns, v.(string)); err != nil {
+			return nil, err
+		}
+	} else if err := defaults.applyDefaultProxy(tr, dns); err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: tr, Timeout: ocspTimeout}, nil
+}
+
+// checkOCSP checks the revocation status of the server certificate on every
+// handshake: from the OCSP response stapled by the server, or else by
+// querying the responder named in the certificate through client.
+func checkOCSP(tlsConfig *tls.Config, mode string, client *http.Client) {
+	addConnectionCheck(tlsConfig, func(cs tls.ConnectionState) error {
+		status, err := ocspStatus(cs, client)
+		switch {
+		case status != nil && status.Status == ocsp.Revoked:
+			return fmt.Errorf("server certificate %s was revoked at %s", cs.PeerCertificates[0].Subject, status.RevokedAt.UTC().Format(time.RFC3339))
+		case mode == "soft_fail" || (status != nil && status.Status == ocsp.Good):
+			return nil
+		case err != nil:
+			return fmt.Errorf("checking OCSP status: %s", err)
+		default:
+			return fmt.Errorf("OCSP status of server certificate %s is unknown", cs.PeerCertificates[0].Subject)
+		}
+	})
+}
+
+// ocspStatus returns the OCSP response for the server certificate, or an
+// error when there is no current, valid one.
+func ocspStatus(cs tls.ConnectionState, client *http.Client) (*ocsp.Response, error) {
+	leaf := cs.PeerCertificates[0]
+	var issuer *x509.Certificate
+	if len(cs.VerifiedChains) > 0 && len(cs.VerifiedChains[0]) > 1 {
+		issuer = cs.VerifiedChains[0][1]
+	} else if len(cs.PeerCertificates) > 1 {
+		issuer = cs.PeerCertificates[1]
+	} else {
+		return nil, fmt.Errorf("the issuer of the server certificate is unknown")
+	}
+
+	raw := cs.OCSPResponse
+	if raw == nil {
+		var err error
+		if raw, err = queryOCSP(client, leaf, issuer); err != nil {
+			return nil, err
+		}
+	}
+	status, err := ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if !status.NextUpdate.IsZero() && status.NextUpdate.Before(time.Now()) {
+		return nil, fmt.Errorf("OCSP response expired at %s", status.NextUpdate.UTC().Format(time.RFC3339))
+	}
+	return status, nil
+}
+
+// queryOCSP asks the first responder named by leaf for its status.
+func queryOCSP(client *http.Client, leaf, issuer *x509.Certificate) ([]byte, error) {
+	if len(leaf.OCSPServer) == 0 {
+		return nil, fmt.Errorf("the server stapled no OCSP response and its certificate names no responder")
+	}
+	req, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Post(leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCSP responder %s returned %s", leaf.OCSPServer[0], resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxOCSPResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxOCSPResponseSize {
+		return nil, fmt.Errorf("OCSP response from %s exceeds %d bytes", leaf.OCSPServer[0], maxOCSPResponseSize)
+	}
+	return body, nil
+}
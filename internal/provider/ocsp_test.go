@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestCheckOCSP(t *testing.T) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "OCSP CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	// The responder answers with the status set for the current case.
+	var responderStatus int
+	respond := func(leaf *x509.Certificate, status int) []byte {
+		resp, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+			Status:       status,
+			SerialNumber: leaf.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute),
+			NextUpdate:   time.Now().Add(time.Hour),
+			RevokedAt:    time.Now().Add(-time.Minute),
+		}, caKey)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return resp
+	}
+	var leaf *x509.Certificate
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(respond(leaf, responderStatus))
+	}))
+	defer responder.Close()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "server"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		OCSPServer:   []string{responder.URL},
+	}, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	leaf, _ = x509.ParseCertificate(der)
+
+	serverCert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: crypto.Signer(key)}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{serverCert}}
+	server.StartTLS()
+	defer server.Close()
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}))
+
+	cases := []struct {
+		name      string
+		mode      string
+		stapled   int
+		responder int
+		err       string
+	}{
+		{"stapled good", "hard_fail", ocsp.Good, ocsp.Revoked, ""},
+		{"stapled revoked", "soft_fail", ocsp.Revoked, ocsp.Good, "revoked"},
+		{"responder good", "hard_fail", -1, ocsp.Good, ""},
+		{"responder revoked", "soft_fail", -1, ocsp.Revoked, "revoked"},
+		{"responder unknown", "hard_fail", -1, ocsp.Unknown, "unknown"},
+		{"responder unknown soft", "soft_fail", -1, ocsp.Unknown, ""},
+		{"off", "off", ocsp.Revoked, ocsp.Revoked, ""},
+	}
+	for _, tc := range cases {
+		serverCert.OCSPStaple = nil
+		if tc.stapled >= 0 {
+			serverCert.OCSPStaple = respond(leaf, tc.stapled)
+		}
+		server.TLS.Certificates[0] = serverCert
+		responderStatus = tc.responder
+
+		d := schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{"ca": caPEM, "ocsp": tc.mode})
+		tlsConfig, diags := newTLSConfig(context.Background(), d, &providerConfig{})
+		if diags.HasError() {
+			t.Fatalf("%s: err: %v", tc.name, diags)
+		}
+		tr := &http.Transport{TLSClientConfig: tlsConfig}
+		resp, err := (&http.Client{Transport: tr}).Get(server.URL)
+		tr.CloseIdleConnections()
+		if err == nil {
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%s: err: %s", tc.name, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("%s: got error %v; want one mentioning %q", tc.name, err, tc.err)
+		}
+	}
+
+	// The responder is queried through the provider's proxy.
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write(respond(leaf, ocsp.Good))
+	}))
+	defer proxy.Close()
+	serverCert.OCSPStaple = nil
+	server.TLS.Certificates[0] = serverCert
+	d := schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{"ca": caPEM, "ocsp": "hard_fail"})
+	tlsConfig, diags := newTLSConfig(context.Background(), d, &providerConfig{proxyURL: proxy.URL})
+	if diags.HasError() {
+		t.Fatalf("proxy: err: %v", diags)
+	}
+	tr := &http.Transport{TLSClientConfig: tlsConfig}
+	resp, err := (&http.Client{Transport: tr}).Get(server.URL)
+	tr.CloseIdleConnections()
+	if err != nil {
+		t.Fatalf("proxy: err: %s", err)
+	}
+	resp.Body.Close()
+	if !strings.HasPrefix(proxied, responder.URL) {
+		t.Errorf("proxy: got %q through the proxy; want the OCSP query to %s", proxied, responder.URL)
+	}
+}
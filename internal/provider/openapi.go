@@ -0,0 +1,411 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// validateOpenAPIResponse checks a JSON response body against the schema the
+// OpenAPI document in the openapi block declares for the operation.
+func validateOpenAPIResponse(ctx context.Context, client *http.Client, openapi map[string]interface{}, statusCode int, body []byte) error {
+	spec := []byte(openapi["spec"].(string))
+
+	if specURL := openapi["spec_url"].(string); specURL != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, specURL, nil)
+		if err != nil {
+			return fmt.Errorf("creating OpenAPI document request: %s", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("fetching OpenAPI document: %s", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("fetching OpenAPI document: response code %d", resp.StatusCode)
+		}
+		if spec, err = ioutil.ReadAll(resp.Body); err != nil {
+			return fmt.Errorf("reading OpenAPI document: %s", err)
+		}
+	}
+
+	doc, err := parseOpenAPIDocument(spec)
+	if err != nil {
+		return err
+	}
+
+	operationID := openapi["operation_id"].(string)
+	schema, err := doc.responseSchema(operationID, statusCode)
+	if err != nil {
+		return err
+	}
+	if schema == nil {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("response body is not valid JSON: %s", err)
+	}
+
+	if errs := doc.validate(schema, value); len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, e := range errs {
+			msgs[i] = e.Error()
+		}
+		return fmt.Errorf("response does not match the schema of operation %q:\n%s", operationID, strings.Join(msgs, "\n"))
+	}
+	return nil
+}
+
+// openAPIDocument is a parsed OpenAPI 3.x or Swagger 2.0 document.
+type openAPIDocument struct {
+	root map[string]interface{}
+}
+
+func parseOpenAPIDocument(data []byte) (*openAPIDocument, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing OpenAPI document: %s", err)
+	}
+
+	root, ok := normalizeYAML(raw).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("parsing OpenAPI document: top level is not an object")
+	}
+	if _, ok := root["paths"].(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("parsing OpenAPI document: no paths defined")
+	}
+
+	return &openAPIDocument{root: root}, nil
+}
+
+// normalizeYAML converts the map[interface{}]interface{} values produced by
+// yaml.v2 into the map[string]interface{} shape produced by encoding/json.
+func normalizeYAML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = normalizeYAML(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalizeYAML(val)
+		}
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	default:
+		return v
+	}
+}
+
+// responseSchema returns the JSON schema describing the body of the response
+// to operationID for the given status code, or nil when the document does not
+// describe one.
+func (doc *openAPIDocument) responseSchema(operationID string, statusCode int) (interface{}, error) {
+	operation := doc.findOperation(operationID)
+	if operation == nil {
+		return nil, fmt.Errorf("operation %q not found in OpenAPI document", operationID)
+	}
+
+	responses, _ := operation["responses"].(map[string]interface{})
+	code := strconv.Itoa(statusCode)
+	response, ok := responses[code]
+	if !ok {
+		response, ok = responses[code[:1]+"XX"]
+	}
+	if !ok {
+		response, ok = responses["default"]
+	}
+	if !ok {
+		return nil, fmt.Errorf("operation %q does not declare a %d response", operationID, statusCode)
+	}
+
+	resolved, err := doc.resolve(response)
+	if err != nil {
+		return nil, err
+	}
+	responseObj, _ := resolved.(map[string]interface{})
+
+	// Swagger 2.0 puts the schema directly on the response.
+	if s, ok := responseObj["schema"]; ok {
+		return s, nil
+	}
+
+	content, _ := responseObj["content"].(map[string]interface{})
+	for mediaType, v := range content {
+		if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+			media, _ := v.(map[string]interface{})
+			return media["schema"], nil
+		}
+	}
+	return nil, nil
+}
+
+func (doc *openAPIDocument) findOperation(operationID string) map[string]interface{} {
+	paths, _ := doc.root["paths"].(map[string]interface{})
+	for _, item := range paths {
+		pathItem, _ := item.(map[string]interface{})
+		for _, v := range pathItem {
+			operation, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if id, _ := operation["operationId"].(string); id == operationID {
+				return operation
+			}
+		}
+	}
+	return nil
+}
+
+// resolve follows local JSON references such as "#/components/schemas/Pet".
+func (doc *openAPIDocument) resolve(v interface{}) (interface{}, error) {
+	for i := 0; i < 32; i++ {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return v, nil
+		}
+		ref, ok := obj["$ref"].(string)
+		if !ok {
+			return v, nil
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			return nil, fmt.Errorf("unsupported non-local reference %q", ref)
+		}
+
+		var cur interface{} = doc.root
+		for _, token := range strings.Split(ref[2:], "/") {
+			token, _ = url.PathUnescape(token)
+			token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+			m, ok := cur.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("unresolvable reference %q", ref)
+			}
+			if cur, ok = m[token]; !ok {
+				return nil, fmt.Errorf("unresolvable reference %q", ref)
+			}
+		}
+		v = cur
+	}
+	return nil, fmt.Errorf("too many nested references")
+}
+
+// validate checks value, a decoded JSON document, against schema and returns
+// every violation found.
+func (doc *openAPIDocument) validate(schema, value interface{}) []error {
+	var errs []error
+	doc.validateAt("$", schema, value, &errs)
+	return errs
+}
+
+func (doc *openAPIDocument) validateAt(path string, schema, value interface{}, errs *[]error) {
+	resolved, err := doc.resolve(schema)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s: %s", path, err))
+		return
+	}
+	s, ok := resolved.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	fail := func(format string, a ...interface{}) {
+		*errs = append(*errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, a...)))
+	}
+
+	if value == nil {
+		if nullable, _ := s["nullable"].(bool); nullable || schemaAllowsType(s, "null") {
+			return
+		}
+		if _, typed := s["type"]; typed {
+			fail("value must not be null")
+		}
+		return
+	}
+
+	if _, typed := s["type"]; typed && !schemaAllowsType(s, jsonType(value)) {
+		fail("expected type %s, got %s", schemaTypes(s), jsonType(value))
+		return
+	}
+
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if jsonEqual(e, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value %v is not one of the allowed values", value)
+		}
+	}
+
+	for _, sub := range schemaList(s, "allOf") {
+		doc.validateAt(path, sub, value, errs)
+	}
+	if anyOf := schemaList(s, "anyOf"); len(anyOf) > 0 {
+		if doc.countMatches(path, anyOf, value) == 0 {
+			fail("value does not match any schema in anyOf")
+		}
+	}
+	if oneOf := schemaList(s, "oneOf"); len(oneOf) > 0 {
+		if n := doc.countMatches(path, oneOf, value); n != 1 {
+			fail("value matches %d schemas in oneOf; want exactly 1", n)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := s["properties"].(map[string]interface{})
+		if required, ok := s["required"].([]interface{}); ok {
+			for _, r := range required {
+				if name, ok := r.(string); ok {
+					if _, present := v[name]; !present {
+						fail("missing required property %q", name)
+					}
+				}
+			}
+		}
+		for name, pv := range v {
+			if ps, ok := properties[name]; ok {
+				doc.validateAt(path+"."+name, ps, pv, errs)
+				continue
+			}
+			switch additional := s["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					fail("unexpected property %q", name)
+				}
+			case map[string]interface{}:
+				doc.validateAt(path+"."+name, additional, pv, errs)
+			}
+		}
+	case []interface{}:
+		if items, ok := s["items"]; ok {
+			for i, item := range v {
+				doc.validateAt(fmt.Sprintf("%s[%d]", path, i), items, item, errs)
+			}
+		}
+		if min, ok := s["minItems"].(float64); ok && float64(len(v)) < min {
+			fail("expected at least %v items, got %d", min, len(v))
+		}
+		if max, ok := s["maxItems"].(float64); ok && float64(len(v)) > max {
+			fail("expected at most %v items, got %d", max, len(v))
+		}
+	case string:
+		length := float64(len([]rune(v)))
+		if min, ok := s["minLength"].(float64); ok && length < min {
+			fail("expected at least %v characters, got %v", min, length)
+		}
+		if max, ok := s["maxLength"].(float64); ok && length > max {
+			fail("expected at most %v characters, got %v", max, length)
+		}
+		if pattern, ok := s["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				fail("invalid pattern %q: %s", pattern, err)
+			} else if !re.MatchString(v) {
+				fail("value %q does not match pattern %q", v, pattern)
+			}
+		}
+	case float64:
+		if min, ok := s["minimum"].(float64); ok && v < min {
+			fail("value %v is less than minimum %v", v, min)
+		}
+		if max, ok := s["maximum"].(float64); ok && v > max {
+			fail("value %v is greater than maximum %v", v, max)
+		}
+	}
+}
+
+func (doc *openAPIDocument) countMatches(path string, schemas []interface{}, value interface{}) int {
+	n := 0
+	for _, sub := range schemas {
+		var subErrs []error
+		doc.validateAt(path, sub, value, &subErrs)
+		if len(subErrs) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func schemaList(s map[string]interface{}, key string) []interface{} {
+	l, _ := s[key].([]interface{})
+	return l
+}
+
+// schemaTypes returns the types allowed by s; OpenAPI 3.1 permits a list.
+func schemaTypes(s map[string]interface{}) []string {
+	switch t := s["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, v := range t {
+			types = append(types, fmt.Sprint(v))
+		}
+		return types
+	}
+	return nil
+}
+
+func schemaAllowsType(s map[string]interface{}, actual string) bool {
+	for _, t := range schemaTypes(s) {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func jsonEqual(a, b interface{}) bool {
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	jb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(ja) == string(jb)
+}
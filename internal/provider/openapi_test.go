@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const testOpenAPIDocument = `
+openapi: 3.0.0
+info:
+  title: pets
+  version: 1.0.0
+paths:
+  /pets/{id}:
+    get:
+      operationId: getPet
+      responses:
+        "200":
+          description: a pet
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Pet"
+        default:
+          description: an error
+          content:
+            application/json:
+              schema:
+                type: object
+                required: [message]
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [id, name]
+      properties:
+        id:
+          type: integer
+        name:
+          type: string
+          minLength: 1
+        tag:
+          type: string
+          nullable: true
+        status:
+          type: string
+          enum: [available, sold]
+        photos:
+          type: array
+          items:
+            type: string
+`
+
+func TestOpenAPIDocument_validate(t *testing.T) {
+	doc, err := parseOpenAPIDocument([]byte(testOpenAPIDocument))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		name       string
+		statusCode int
+		body       string
+		wantErrs   int
+	}{
+		{"valid", 200, `{"id": 1, "name": "rex", "tag": null, "status": "sold", "photos": ["a"]}`, 0},
+		{"missing required", 200, `{"id": 1}`, 1},
+		{"wrong type", 200, `{"id": "1", "name": "rex"}`, 1},
+		{"enum and items", 200, `{"id": 1, "name": "rex", "status": "lost", "photos": [1]}`, 2},
+		{"empty string", 200, `{"id": 1.5, "name": ""}`, 2},
+		{"default response", 500, `{"message": "boom"}`, 0},
+	}
+
+	for _, tc := range cases {
+		schema, err := doc.responseSchema("getPet", tc.statusCode)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.name, err)
+		}
+
+		var value interface{}
+		if err := json.Unmarshal([]byte(tc.body), &value); err != nil {
+			t.Fatalf("%s: err: %s", tc.name, err)
+		}
+
+		if errs := doc.validate(schema, value); len(errs) != tc.wantErrs {
+			t.Errorf("%s: got %d errors %v; want %d", tc.name, len(errs), errs, tc.wantErrs)
+		}
+	}
+}
+
+func TestOpenAPIDocument_unknownOperation(t *testing.T) {
+	doc, err := parseOpenAPIDocument([]byte(testOpenAPIDocument))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := doc.responseSchema("deletePet", 200); err == nil {
+		t.Fatalf("expected an error for an unknown operation")
+	}
+}
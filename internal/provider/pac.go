@@ -0,0 +1,352 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// pacTimeout bounds the download of a PAC script from pac_url.
+const pacTimeout = 30 * time.Second
+
+// maxPACScriptSize bounds the PAC scripts downloaded from pac_url.
+const maxPACScriptSize = 1 << 20
+
+// pacUtils are the pure helper functions every PAC script may call, as
+// defined by the original Netscape specification. The DNS and time based
+// helpers are provided from Go.
+const pacUtils = `
+function isPlainHostName(host) {
+	return host.indexOf('.') < 0;
+}
+function dnsDomainIs(host, domain) {
+	return host.length >= domain.length &&
+		host.substring(host.length - domain.length) == domain;
+}
+function localHostOrDomainIs(host, hostdom) {
+	return host == hostdom || hostdom.lastIndexOf(host + '.', 0) == 0;
+}
+function dnsDomainLevels(host) {
+	return host.split('.').length - 1;
+}
+function shExpMatch(str, shexp) {
+	shexp = shexp.replace(/\./g, '\\.').replace(/\*/g, '.*').replace(/\?/g, '.');
+	return new RegExp('^' + shexp + '$').test(str);
+}
+function convertAddr(ipchars) {
+	var bytes = ipchars.split('.');
+	return ((bytes[0] & 0xff) << 24) | ((bytes[1] & 0xff) << 16) |
+		((bytes[2] & 0xff) << 8) | (bytes[3] & 0xff);
+}
+function isInNet(ipaddr, pattern, maskstr) {
+	if (!/^\d+\.\d+\.\d+\.\d+$/.test(ipaddr)) {
+		ipaddr = dnsResolve(ipaddr);
+		if (!ipaddr) {
+			return false;
+		}
+	}
+	var host = convertAddr(ipaddr);
+	var pat = convertAddr(pattern);
+	var mask = convertAddr(maskstr);
+	return (host & mask) == (pat & mask);
+}
+function isResolvable(host) {
+	return !!dnsResolve(host);
+}
+`
+
+// pacScript evaluates a proxy auto-config script. The JavaScript runtime is
+// not safe for concurrent use, so calls are serialised.
+type pacScript struct {
+	mu              sync.Mutex
+	vm              *goja.Runtime
+	findProxyForURL goja.Callable
+}
+
+func newPACScript(source string) (*pacScript, error) {
+	vm := goja.New()
+	vm.Set("dnsResolve", func(host string) string {
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			return ""
+		}
+		for _, ip := range ips {
+			if ip4 := ip.To4(); ip4 != nil {
+				return ip4.String()
+			}
+		}
+		return ""
+	})
+	vm.Set("myIpAddress", func() string {
+		conn, err := net.Dial("udp", "198.51.100.1:80")
+		if err != nil {
+			return "127.0.0.1"
+		}
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).IP.String()
+	})
+	for name, fn := range map[string]func([]string, time.Time) bool{
+		"weekdayRange": weekdayRange,
+		"dateRange":    dateRange,
+		"timeRange":    timeRange,
+	} {
+		fn := fn
+		vm.Set(name, func(call goja.FunctionCall) goja.Value {
+			args := make([]string, len(call.Arguments))
+			for i, arg := range call.Arguments {
+				args[i] = arg.String()
+			}
+			return vm.ToValue(fn(args, pacNow()))
+		})
+	}
+	if _, err := vm.RunString(pacUtils); err != nil {
+		return nil, err
+	}
+	if _, err := vm.RunString(source); err != nil {
+		return nil, fmt.Errorf("evaluating PAC script: %s", err)
+	}
+	fn, ok := goja.AssertFunction(vm.Get("FindProxyForURL"))
+	if !ok {
+		return nil, fmt.Errorf("PAC script does not define FindProxyForURL")
+	}
+	return &pacScript{vm: vm, findProxyForURL: fn}, nil
+}
+
+// loadPACScript reads a PAC script from pacFile or downloads it, without a
+// proxy, from pacURL.
+func loadPACScript(ctx context.Context, pacURL, pacFile string) (*pacScript, error) {
+	var source []byte
+	if pacFile != "" {
+		b, err := ioutil.ReadFile(expandHome(pacFile))
+		if err != nil {
+			return nil, err
+		}
+		source = b
+	} else {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, pacURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := (&http.Client{Transport: &http.Transport{}, Timeout: pacTimeout}).Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching %s: HTTP request error. Response code: %d", pacURL, resp.StatusCode)
+		}
+		if source, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxPACScriptSize+1)); err != nil {
+			return nil, err
+		}
+		if len(source) > maxPACScriptSize {
+			return nil, fmt.Errorf("fetching %s: PAC script exceeds %d bytes", pacURL, maxPACScriptSize)
+		}
+	}
+	return newPACScript(string(source))
+}
+
+// findProxy returns the result of FindProxyForURL for u, such as
+// "PROXY proxy:8080; DIRECT".
+func (p *pacScript) findProxy(u *url.URL) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	v, err := p.findProxyForURL(goja.Undefined(), p.vm.ToValue(u.String()), p.vm.ToValue(u.Hostname()))
+	if err != nil {
+		return "", fmt.Errorf("running FindProxyForURL: %s", err)
+	}
+	return v.String(), nil
+}
+
+// proxy is an http.Transport Proxy hook choosing the proxy for every request
+// from the script.
+func (p *pacScript) proxy(req *http.Request) (*url.URL, error) {
+	result, err := p.findProxy(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	return parsePACResult(result)
+}
+
+// parsePACResult turns the first entry of a FindProxyForURL result into a
+// proxy URL, nil meaning DIRECT.
+func parsePACResult(result string) (*url.URL, error) {
+	for _, entry := range strings.Split(result, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		var scheme string
+		switch strings.ToUpper(fields[0]) {
+		case "DIRECT":
+			return nil, nil
+		case "PROXY", "HTTP":
+			scheme = "http"
+		case "HTTPS":
+			scheme = "https"
+		case "SOCKS", "SOCKS5":
+			scheme = "socks5"
+		default:
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("PAC result %q has no proxy address", entry)
+		}
+		return &url.URL{Scheme: scheme, Host: fields[1]}, nil
+	}
+	return nil, fmt.Errorf("PAC result %q has no usable proxy", result)
+}
+
+// pacNow returns the time the PAC time helpers compare against; tests
+// override it.
+var pacNow = time.Now
+
+var pacWeekdays = map[string]int{"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6}
+
+var pacMonths = map[string]int{"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6, "JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12}
+
+// pacZone drops a trailing "GMT" from args and returns now in UTC if there
+// was one, or in local time otherwise.
+func pacZone(args []string, now time.Time) ([]string, time.Time) {
+	if len(args) > 0 && args[len(args)-1] == "GMT" {
+		return args[:len(args)-1], now.UTC()
+	}
+	return args, now.Local()
+}
+
+// inPACRange reports whether v is within from and to, inclusive, wrapping
+// around when from is after to, as in weekdayRange("FRI", "MON").
+func inPACRange(v, from, to int) bool {
+	if from <= to {
+		return from <= v && v <= to
+	}
+	return v >= from || v <= to
+}
+
+// weekdayRange implements weekdayRange(wd1[, wd2][, "GMT"]).
+func weekdayRange(args []string, now time.Time) bool {
+	args, now = pacZone(args, now)
+	if len(args) < 1 || len(args) > 2 {
+		return false
+	}
+	from, ok := pacWeekdays[args[0]]
+	if !ok {
+		return false
+	}
+	to := from
+	if len(args) == 2 {
+		if to, ok = pacWeekdays[args[1]]; !ok {
+			return false
+		}
+	}
+	return inPACRange(int(now.Weekday()), from, to)
+}
+
+// dateRange implements dateRange with a day, a month or a year, a range of
+// them, or a range of day and month, month and year, or day, month and
+// year, optionally followed by "GMT". Numbers up to 31 are days, larger
+// ones years.
+func dateRange(args []string, now time.Time) bool {
+	args, now = pacZone(args, now)
+	if len(args) == 1 {
+		args = append(args, args[0])
+	}
+	if len(args) == 0 || len(args) > 6 || len(args)%2 != 0 {
+		return false
+	}
+	from, fromFields, ok := pacDate(args[:len(args)/2])
+	if !ok {
+		return false
+	}
+	to, toFields, ok := pacDate(args[len(args)/2:])
+	if !ok || fromFields != toFields {
+		return false
+	}
+	// Compare now on the fields the range names only.
+	var y, m, d int
+	if fromFields&pacYear != 0 {
+		y = now.Year()
+	}
+	if fromFields&pacMonth != 0 {
+		m = int(now.Month())
+	}
+	if fromFields&pacDay != 0 {
+		d = now.Day()
+	}
+	return inPACRange(y*10000+m*100+d, from, to)
+}
+
+const (
+	pacDay = 1 << iota
+	pacMonth
+	pacYear
+)
+
+// pacDate returns one end of a dateRange as year*10000+month*100+day,
+// leaving out the fields it does not name, and which fields it names.
+func pacDate(args []string) (int, int, bool) {
+	var date, fields int
+	for _, arg := range args {
+		if m, ok := pacMonths[arg]; ok {
+			if fields&pacMonth != 0 {
+				return 0, 0, false
+			}
+			date, fields = date+m*100, fields|pacMonth
+			continue
+		}
+		n, err := strconv.Atoi(arg)
+		switch {
+		case err != nil || n < 1:
+			return 0, 0, false
+		case n <= 31:
+			if fields&pacDay != 0 {
+				return 0, 0, false
+			}
+			date, fields = date+n, fields|pacDay
+		default:
+			if fields&pacYear != 0 {
+				return 0, 0, false
+			}
+			date, fields = date+n*10000, fields|pacYear
+		}
+	}
+	return date, fields, true
+}
+
+// timeRange implements timeRange(hour), timeRange(hour1, hour2),
+// timeRange(hour1, min1, hour2, min2) and timeRange(hour1, min1, sec1,
+// hour2, min2, sec2), optionally followed by "GMT". Ranges include their
+// last hour or minute, as browsers do.
+func timeRange(args []string, now time.Time) bool {
+	args, now = pacZone(args, now)
+	n := make([]int, len(args))
+	for i, arg := range args {
+		v, err := strconv.Atoi(arg)
+		if err != nil || v < 0 {
+			return false
+		}
+		n[i] = v
+	}
+	clock := now.Hour()*3600 + now.Minute()*60 + now.Second()
+	switch len(n) {
+	case 1:
+		return now.Hour() == n[0]
+	case 2:
+		return inPACRange(clock, n[0]*3600, n[1]*3600+3599)
+	case 4:
+		return inPACRange(clock, n[0]*3600+n[1]*60, n[2]*3600+n[3]*60+59)
+	case 6:
+		return inPACRange(clock, n[0]*3600+n[1]*60+n[2], n[3]*3600+n[4]*60+n[5])
+	}
+	return false
+}
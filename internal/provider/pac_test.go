@@ -0,0 +1,131 @@
+package provider
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPACScript = `
+function FindProxyForURL(url, host) {
+	if (isPlainHostName(host) || dnsDomainIs(host, ".internal.example.com")) {
+		return "DIRECT";
+	}
+	if (shExpMatch(url, "https://*.corp.example.com/*")) {
+		return "SOCKS5 bastion:1080";
+	}
+	if (isInNet(host, "10.0.0.0", "255.0.0.0")) {
+		return "PROXY lab-proxy:3128; DIRECT";
+	}
+	return "PROXY proxy.example.com:8080; DIRECT";
+}
+`
+
+func TestPACScript(t *testing.T) {
+	pac, err := newPACScript(testPACScript)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := map[string]string{
+		"http://intranet/":                        "",
+		"https://api.internal.example.com/v1":     "",
+		"https://build.corp.example.com/jobs":     "socks5://bastion:1080",
+		"http://10.1.2.3/status":                  "http://lab-proxy:3128",
+		"https://registry.terraform.io/providers": "http://proxy.example.com:8080",
+	}
+	for target, want := range cases {
+		u, _ := url.Parse(target)
+		result, err := pac.findProxy(u)
+		if err != nil {
+			t.Errorf("%s: err: %s", target, err)
+			continue
+		}
+		proxy, err := parsePACResult(result)
+		if err != nil {
+			t.Errorf("%s: err: %s", target, err)
+			continue
+		}
+		got := ""
+		if proxy != nil {
+			got = proxy.String()
+		}
+		if got != want {
+			t.Errorf("%s: proxy %q; want %q", target, got, want)
+		}
+	}
+
+	if _, err := newPACScript("var x = 1;"); err == nil {
+		t.Errorf("expected an error for a script without FindProxyForURL")
+	}
+}
+
+func TestPACTimeHelpers(t *testing.T) {
+	// A Friday afternoon.
+	now := time.Date(2026, time.October, 16, 14, 30, 0, 0, time.UTC)
+
+	helpers := map[string]func([]string, time.Time) bool{
+		"weekdayRange": weekdayRange,
+		"dateRange":    dateRange,
+		"timeRange":    timeRange,
+	}
+	cases := []struct {
+		helper string
+		args   []string
+		want   bool
+	}{
+		{"weekdayRange", []string{"FRI", "GMT"}, true},
+		{"weekdayRange", []string{"MON", "FRI", "GMT"}, true},
+		{"weekdayRange", []string{"THU", "MON", "GMT"}, true},
+		{"weekdayRange", []string{"SAT", "SUN", "GMT"}, false},
+		{"weekdayRange", []string{"FRIDAY", "GMT"}, false},
+		{"weekdayRange", []string{"GMT"}, false},
+		{"dateRange", []string{"16", "GMT"}, true},
+		{"dateRange", []string{"OCT", "GMT"}, true},
+		{"dateRange", []string{"2026", "GMT"}, true},
+		{"dateRange", []string{"2025", "GMT"}, false},
+		{"dateRange", []string{"1", "15", "GMT"}, false},
+		{"dateRange", []string{"SEP", "NOV", "GMT"}, true},
+		{"dateRange", []string{"NOV", "MAR", "GMT"}, false},
+		{"dateRange", []string{"1", "OCT", "16", "OCT", "GMT"}, true},
+		{"dateRange", []string{"17", "OCT", "1", "NOV", "GMT"}, false},
+		{"dateRange", []string{"20", "DEC", "20", "OCT", "GMT"}, true},
+		{"dateRange", []string{"OCT", "2025", "JAN", "2026", "GMT"}, false},
+		{"dateRange", []string{"1", "JAN", "2026", "31", "DEC", "2026", "GMT"}, true},
+		{"dateRange", []string{"1", "OCT", "NOV", "GMT"}, false},
+		{"dateRange", []string{"OCT", "1", "2026", "GMT"}, false},
+		{"timeRange", []string{"14", "GMT"}, true},
+		{"timeRange", []string{"9", "14", "GMT"}, true},
+		{"timeRange", []string{"15", "17", "GMT"}, false},
+		{"timeRange", []string{"22", "15", "GMT"}, true},
+		{"timeRange", []string{"14", "0", "14", "29", "GMT"}, false},
+		{"timeRange", []string{"14", "30", "15", "0", "GMT"}, true},
+		{"timeRange", []string{"14", "29", "59", "14", "30", "0", "GMT"}, true},
+		{"timeRange", []string{"14", "30", "1", "14", "31", "0", "GMT"}, false},
+		{"timeRange", []string{"1", "2", "3", "GMT"}, false},
+	}
+	for _, tc := range cases {
+		if got := helpers[tc.helper](tc.args, now); got != tc.want {
+			t.Errorf("%s(%s): %t; want %t", tc.helper, strings.Join(tc.args, ", "), got, tc.want)
+		}
+	}
+
+	defer func(now func() time.Time) { pacNow = now }(pacNow)
+	pacNow = func() time.Time { return now }
+	pac, err := newPACScript(`
+function FindProxyForURL(url, host) {
+	if (weekdayRange("MON", "FRI", "GMT") && timeRange(9, 17, "GMT")) {
+		return "PROXY office-proxy:3128";
+	}
+	return "DIRECT";
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	u, _ := url.Parse("https://example.com/")
+	if result, err := pac.findProxy(u); err != nil || result != "PROXY office-proxy:3128" {
+		t.Errorf("findProxy: %q, %v; want the office proxy", result, err)
+	}
+}
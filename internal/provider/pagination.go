@@ -0,0 +1,274 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	paginateModeOData      = "odata"
+	paginateModeLinkHeader = "link_header"
+	paginateModeCursor     = "cursor"
+)
+
+var paginateModes = []string{paginateModeOData, paginateModeLinkHeader, paginateModeCursor}
+
+// pageResult is the aggregated outcome of following pagination.
+type pageResult struct {
+	body      []byte
+	pages     int
+	truncated bool
+}
+
+// pageParser extracts the items of the page at current and the link to the
+// next one, "" on the last page.
+type pageParser func(current *url.URL, header http.Header, body []byte) ([]interface{}, string, error)
+
+// paginate follows the pages that come after the first response (whose
+// header and body are firstHeader and firstBody) and merges their items
+// into a single JSON array. Each page, and the merged array, must fit in
+// limit bytes when limit is positive.
+func paginate(ctx context.Context, client *http.Client, first *http.Request, firstHeader http.Header, firstBody []byte, settings map[string]interface{}, policy *retryPolicy, budget *retryBudget, limit int64) (*pageResult, error) {
+	maxPages := settings["max_pages"].(int)
+	var parse pageParser
+	switch mode := settings["mode"].(string); mode {
+	case paginateModeOData:
+		parse = odataPage
+	case paginateModeLinkHeader:
+		parse = linkHeaderPage
+	case paginateModeCursor:
+		cursorPath, cursorParam := settings["cursor_path"].(string), settings["cursor_param"].(string)
+		if cursorPath == "" || cursorParam == "" {
+			return nil, fmt.Errorf("cursor pagination requires cursor_path and cursor_param")
+		}
+		parse = cursorPage(settings["items_path"].(string), cursorPath, cursorParam)
+	default:
+		return nil, fmt.Errorf("unsupported pagination mode %q", mode)
+	}
+
+	items, next, err := parse(first.URL, firstHeader, firstBody)
+	if err != nil {
+		return nil, fmt.Errorf("page 1: %s", err)
+	}
+
+	result := &pageResult{pages: 1}
+	current := first.URL
+	// A server that hands out the same link or cursor again would otherwise
+	// be paged forever when max_pages is 0.
+	seen := map[string]bool{first.URL.String(): true}
+	for next != "" {
+		if maxPages > 0 && result.pages >= maxPages {
+			result.truncated = true
+			break
+		}
+
+		nextURL, err := current.Parse(next)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: invalid next link %q: %s", result.pages+1, next, err)
+		}
+		if seen[nextURL.String()] {
+			return nil, fmt.Errorf("page %d: next link %q repeats an earlier page", result.pages+1, nextURL)
+		}
+		seen[nextURL.String()] = true
+
+		header, body, err := fetchPage(ctx, client, first, nextURL, policy, budget, limit)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %s", result.pages+1, err)
+		}
+		pageItems, pageNext, err := parse(nextURL, header, body)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %s", result.pages+1, err)
+		}
+
+		items = append(items, pageItems...)
+		next = pageNext
+		current = nextURL
+		result.pages++
+	}
+
+	if items == nil {
+		items = []interface{}{}
+	}
+	if result.body, err = json.Marshal(items); err != nil {
+		return nil, err
+	}
+	if limit > 0 && int64(len(result.body)) > limit {
+		return nil, fmt.Errorf("the items of %d pages exceed max_response_body_size (%d bytes)", result.pages, limit)
+	}
+	return result, nil
+}
+
+// fetchPage repeats the original request, method, headers and body
+// included, for u. Credentials are only sent to the original host.
+func fetchPage(ctx context.Context, client *http.Client, first *http.Request, u *url.URL, policy *retryPolicy, budget *retryBudget, limit int64) (http.Header, []byte, error) {
+	req := first.Clone(ctx)
+	req.URL = u
+	req.Host = ""
+	if first.GetBody != nil {
+		body, err := first.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Body = body
+	}
+	if u.Host != first.URL.Host {
+		stripCredentials(req.Header)
+	}
+
+	resp, err := doWithRetry(ctx, client, req, policy, budget)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("HTTP request error. Response code: %d", resp.StatusCode)
+	}
+	body, err := readBody(resp, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Header, body, nil
+}
+
+// stripCredentials removes the headers net/http also drops when a redirect
+// leaves the original host.
+func stripCredentials(header http.Header) {
+	for _, name := range []string{"Authorization", "Proxy-Authorization", "Cookie", "Cookie2", "Www-Authenticate"} {
+		header.Del(name)
+	}
+}
+
+// odataPage extracts the items and next link of an OData collection page
+// such as those returned by Microsoft Graph and Azure Resource Manager.
+func odataPage(current *url.URL, header http.Header, body []byte) ([]interface{}, string, error) {
+	var page struct {
+		Value     []interface{} `json:"value"`
+		ODataNext string        `json:"@odata.nextLink"`
+		Next      string        `json:"nextLink"`
+	}
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, "", fmt.Errorf("decoding OData page: %s", err)
+	}
+	if page.ODataNext != "" {
+		return page.Value, page.ODataNext, nil
+	}
+	return page.Value, page.Next, nil
+}
+
+// linkHeaderPage extracts the items of a page whose body is a JSON array and
+// whose next page is linked with rel="next" in the Link header (RFC 8288,
+// formerly RFC 5988), as GitHub and GitLab list APIs do.
+func linkHeaderPage(current *url.URL, header http.Header, body []byte) ([]interface{}, string, error) {
+	var items []interface{}
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, "", fmt.Errorf("decoding page as a JSON array: %s", err)
+	}
+	return items, nextLink(header["Link"]), nil
+}
+
+// cursorPage returns a parser for APIs returning an opaque cursor at
+// cursorPath, which is passed back in the cursorParam query parameter to get
+// the next page. The items are the array at itemsPath, or the body itself
+// when itemsPath is empty. A missing, null or empty cursor ends pagination.
+func cursorPage(itemsPath, cursorPath, cursorParam string) pageParser {
+	return func(current *url.URL, header http.Header, body []byte) ([]interface{}, string, error) {
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, "", fmt.Errorf("decoding JSON page: %s", err)
+		}
+
+		list := doc
+		if itemsPath != "" {
+			matches, _, err := evalJSONPath(doc, itemsPath)
+			if err != nil {
+				return nil, "", err
+			}
+			if len(matches) == 0 {
+				return nil, "", fmt.Errorf("items_path %q matched nothing", itemsPath)
+			}
+			list = matches[0]
+		}
+		items, ok := list.([]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("the page items are not a JSON array")
+		}
+
+		matches, _, err := evalJSONPath(doc, cursorPath)
+		if err != nil {
+			return nil, "", err
+		}
+		var cursor string
+		if len(matches) > 0 && matches[0] != nil {
+			switch v := matches[0].(type) {
+			case string:
+				cursor = v
+			case float64:
+				cursor = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				return nil, "", fmt.Errorf("cursor_path %q is not a string or number", cursorPath)
+			}
+		}
+		if cursor == "" {
+			return items, "", nil
+		}
+
+		next := *current
+		query := next.Query()
+		query.Set(cursorParam, cursor)
+		next.RawQuery = query.Encode()
+		return items, next.String(), nil
+	}
+}
+
+// nextLink returns the target of the rel="next" link among the Link header
+// values, or "".
+func nextLink(values []string) string {
+	for _, value := range values {
+		for value != "" {
+			start, end := strings.Index(value, "<"), strings.Index(value, ">")
+			if start == -1 || end < start {
+				break
+			}
+			target := value[start+1 : end]
+			value = value[end+1:]
+
+			// The parameters run up to the next link.
+			params := value
+			if next := strings.Index(value, "<"); next != -1 {
+				params, value = value[:next], value[next:]
+			} else {
+				value = ""
+			}
+			params = strings.TrimSuffix(strings.TrimSpace(params), ",")
+			for _, param := range strings.Split(params, ";") {
+				name, rels := splitParam(param)
+				if !strings.EqualFold(name, "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(rels) {
+					if strings.EqualFold(rel, "next") {
+						return target
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// splitParam splits a link parameter such as rel="next" into its name and
+// unquoted value.
+func splitParam(param string) (string, string) {
+	eq := strings.Index(param, "=")
+	if eq == -1 {
+		return strings.TrimSpace(param), ""
+	}
+	value := strings.Trim(strings.TrimSpace(param[eq+1:]), `"`)
+	return strings.TrimSpace(param[:eq]), value
+}
@@ -0,0 +1,223 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func setUpMockPagedServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("page") {
+		case "":
+			fmt.Fprintf(w, `{"value": [1, 2], "@odata.nextLink": "http://%s/items?page=2"}`, r.Host)
+		case "2":
+			fmt.Fprint(w, `{"value": [3], "nextLink": "/items?page=3"}`)
+		case "3":
+			fmt.Fprint(w, `{"value": [4]}`)
+		}
+	}))
+}
+
+func TestPaginate_odata(t *testing.T) {
+	server := setUpMockPagedServer()
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/items", nil)
+	req.Header.Set("Authorization", "Bearer t")
+	first := []byte(fmt.Sprintf(`{"value": [1, 2], "@odata.nextLink": "%s/items?page=2"}`, server.URL))
+
+	result, err := paginate(context.Background(), server.Client(), req, nil, first, map[string]interface{}{
+		"mode":      paginateModeOData,
+		"max_pages": 0,
+	}, nil, nil, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(result.body) != "[1,2,3,4]" || result.pages != 3 || result.truncated {
+		t.Fatalf("got %s after %d pages (truncated %v); want [1,2,3,4] after 3", result.body, result.pages, result.truncated)
+	}
+
+	result, err = paginate(context.Background(), server.Client(), req, nil, first, map[string]interface{}{
+		"mode":      paginateModeOData,
+		"max_pages": 2,
+	}, nil, nil, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(result.body) != "[1,2,3]" || !result.truncated {
+		t.Fatalf("got %s (truncated %v); want [1,2,3] truncated", result.body, result.truncated)
+	}
+}
+
+func TestPaginate_linkHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("page") {
+		case "2":
+			w.Header().Set("Link", `</repos?page=3>; rel="next", </repos?page=1>; rel="prev first"`)
+			fmt.Fprint(w, `[{"id": 3}]`)
+		case "3":
+			w.Header().Set("Link", `</repos?page=2>; rel="prev"`)
+			fmt.Fprint(w, `[{"id": 4}]`)
+		}
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/repos", nil)
+	header := http.Header{"Link": []string{fmt.Sprintf(`<%s/repos?page=2>; rel="next"`, server.URL)}}
+	result, err := paginate(context.Background(), server.Client(), req, header, []byte(`[{"id": 1}, {"id": 2}]`), map[string]interface{}{
+		"mode":      paginateModeLinkHeader,
+		"max_pages": 0,
+	}, nil, nil, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(result.body) != `[{"id":1},{"id":2},{"id":3},{"id":4}]` || result.pages != 3 {
+		t.Fatalf("got %s after %d pages; want ids 1 to 4 after 3", result.body, result.pages)
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	cases := []struct {
+		name   string
+		values []string
+		want   string
+	}{
+		{"github", []string{`<https://api.github.com/user/repos?page=3>; rel="next", <https://api.github.com/user/repos?page=50>; rel="last"`}, "https://api.github.com/user/repos?page=3"},
+		{"next not first", []string{`<https://x.test/?page=1>; rel="first", <https://x.test/?page=2>; rel=next`}, "https://x.test/?page=2"},
+		{"several rels", []string{`<https://x.test/?page=2>; title="a, b"; rel="last next"`}, "https://x.test/?page=2"},
+		{"several headers", []string{`<https://x.test/?page=1>; rel="prev"`, `<https://x.test/?page=3>; REL="Next"`}, "https://x.test/?page=3"},
+		{"last page", []string{`<https://x.test/?page=1>; rel="prev"`}, ""},
+		{"none", nil, ""},
+	}
+
+	for _, tc := range cases {
+		if got := nextLink(tc.values); got != tc.want {
+			t.Errorf("%s: got %q; want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPaginate_cursor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("limit") != "2" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("after") {
+		case "c2":
+			fmt.Fprint(w, `{"data": {"users": ["c", "d"]}, "meta": {"next": 7}}`)
+		case "7":
+			fmt.Fprint(w, `{"data": {"users": ["e"]}, "meta": {"next": null}}`)
+		}
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/users?limit=2", nil)
+	result, err := paginate(context.Background(), server.Client(), req, nil, []byte(`{"data": {"users": ["a", "b"]}, "meta": {"next": "c2"}}`), map[string]interface{}{
+		"mode":         paginateModeCursor,
+		"max_pages":    0,
+		"cursor_path":  "$.meta.next",
+		"cursor_param": "after",
+		"items_path":   "$.data.users",
+	}, nil, nil, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(result.body) != `["a","b","c","d","e"]` || result.pages != 3 {
+		t.Fatalf("got %s after %d pages; want a to e after 3", result.body, result.pages)
+	}
+
+	_, err = paginate(context.Background(), server.Client(), req, nil, []byte(`[]`), map[string]interface{}{
+		"mode":         paginateModeCursor,
+		"max_pages":    0,
+		"cursor_path":  "",
+		"cursor_param": "",
+		"items_path":   "",
+	}, nil, nil, 0)
+	if err == nil {
+		t.Fatalf("expected an error without cursor_path and cursor_param")
+	}
+}
+
+func TestPaginate_repeatsRequest(t *testing.T) {
+	var other []string
+	otherServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		other = append(other, r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"value": [3]}`)
+	}))
+	defer otherServer.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Method != http.MethodPost || string(body) != `{"q":"x"}` || r.Header.Get("Authorization") != "Bearer t" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"value": [2], "nextLink": "%s/search?page=3"}`, otherServer.URL)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/search", strings.NewReader(`{"q":"x"}`))
+	req.Header.Set("Authorization", "Bearer t")
+	first := []byte(fmt.Sprintf(`{"value": [1], "nextLink": "%s/search?page=2"}`, server.URL))
+	result, err := paginate(context.Background(), server.Client(), req, nil, first, map[string]interface{}{
+		"mode":      paginateModeOData,
+		"max_pages": 0,
+	}, nil, nil, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(result.body) != "[1,2,3]" {
+		t.Fatalf("got %s; want [1,2,3]", result.body)
+	}
+	if len(other) != 1 || other[0] != "" {
+		t.Fatalf("Authorization sent to another host: %q", other)
+	}
+}
+
+func TestPaginate_loop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": ["b"], "next": "same"}`)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/items", nil)
+	_, err := paginate(context.Background(), server.Client(), req, nil, []byte(`{"data": ["a"], "next": "same"}`), map[string]interface{}{
+		"mode":         paginateModeCursor,
+		"max_pages":    0,
+		"cursor_path":  "$.next",
+		"cursor_param": "after",
+		"items_path":   "$.data",
+	}, nil, nil, 0)
+	if err == nil || !strings.Contains(err.Error(), "repeats an earlier page") {
+		t.Fatalf("got %v; want a repeated page error", err)
+	}
+}
+
+func TestPaginate_maxResponseBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"value": ["a long item past the limit"]}`)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/items", nil)
+	_, err := paginate(context.Background(), server.Client(), req, nil, []byte(`{"value": [], "nextLink": "/items?page=2"}`), map[string]interface{}{
+		"mode":      paginateModeOData,
+		"max_pages": 0,
+	}, nil, nil, 16)
+	if err == nil || !strings.Contains(err.Error(), "max_response_body_size") {
+		t.Fatalf("got %v; want a max_response_body_size error", err)
+	}
+}
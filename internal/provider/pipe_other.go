@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package provider
+
+import "fmt"
+
+// dialPipe fails: named pipes only exist on Windows.
+func dialPipe(path string) (dialFunc, error) {
+	return nil, fmt.Errorf("named pipes are only supported on Windows")
+}
@@ -0,0 +1,16 @@
+package provider
+
+import (
+	"context"
+	"net"
+
+	"github.com/Microsoft/go-winio"
+)
+
+// dialPipe returns a dial func connecting every request to the Windows
+// named pipe at path, whatever the host in the URL.
+func dialPipe(path string) (dialFunc, error) {
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return winio.DialPipeContext(ctx, path)
+	}, nil
+}
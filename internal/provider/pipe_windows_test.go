@@ -0,0 +1,35 @@
+package provider
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Microsoft/go-winio"
+)
+
+func TestDialPipe(t *testing.T) {
+	path := `\\.\pipe\terraform-provider-http-full-test`
+	listener, err := winio.ListenPipe(path, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var requested string
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.Host + r.URL.Path
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	dial, err := dialPipe(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp, err := (&http.Client{Transport: &http.Transport{DialContext: dial}}).Get("http://pipe/v1.41/info")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if requested != "pipe/v1.41/info" {
+		t.Errorf("got request for %q; want pipe/v1.41/info", requested)
+	}
+}
@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"encoding/pem"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// pkcs11Settings identifies a client key held in a PKCS#11 token: the
+// module implementing the token's interface, the token, by label or slot,
+// and the label of the key pair.
+type pkcs11Settings struct {
+	modulePath string
+	tokenLabel string
+	slot       int
+	pin        string
+	keyLabel   string
+}
+
+// pkcs11Schema is the pkcs11 block of the data source.
+func pkcs11Schema() *schema.Schema {
+	return &schema.Schema{
+		Type:          schema.TypeList,
+		Optional:      true,
+		MaxItems:      1,
+		ConflictsWith: []string{"client_key", "client_key_file", "client_pkcs12", "client_pkcs12_file", "client_certificates", "spiffe_socket_path"},
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"module_path": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				"token_label": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+				"slot": {
+					Type:     schema.TypeInt,
+					Optional: true,
+					Default:  -1,
+				},
+				"pin": {
+					Type:      schema.TypeString,
+					Required:  true,
+					Sensitive: true,
+				},
+				"key_label": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+			},
+		},
+	}
+}
+
+func expandPKCS11(v interface{}) pkcs11Settings {
+	m := v.([]interface{})[0].(map[string]interface{})
+	return pkcs11Settings{
+		modulePath: expandHome(m["module_path"].(string)),
+		tokenLabel: m["token_label"].(string),
+		slot:       m["slot"].(int),
+		pin:        m["pin"].(string),
+		keyLabel:   m["key_label"].(string),
+	}
+}
+
+// pemCertificates returns the DER certificates of a PEM chain.
+func pemCertificates(chain string) [][]byte {
+	var certs [][]byte
+	rest := []byte(chain)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return certs
+		}
+		if block.Type == "CERTIFICATE" {
+			certs = append(certs, block.Bytes)
+		}
+	}
+}
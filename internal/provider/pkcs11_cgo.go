@@ -0,0 +1,69 @@
+//go:build cgo
+// +build cgo
+
+package provider
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync"
+
+	"github.com/ThalesIgnite/crypto11"
+)
+
+// pkcs11Contexts keeps a logged in context per token for the life of the
+// provider process: keys are used during handshakes after the read that
+// configured them, and logging in again on every read is slow on most HSMs.
+var pkcs11Contexts = struct {
+	sync.Mutex
+	m map[pkcs11Settings]*crypto11.Context
+}{m: map[pkcs11Settings]*crypto11.Context{}}
+
+func pkcs11Context(s pkcs11Settings) (*crypto11.Context, error) {
+	pkcs11Contexts.Lock()
+	defer pkcs11Contexts.Unlock()
+	if ctx, ok := pkcs11Contexts.m[s]; ok {
+		return ctx, nil
+	}
+	config := &crypto11.Config{Path: s.modulePath, TokenLabel: s.tokenLabel, Pin: s.pin}
+	if s.slot >= 0 {
+		slot := s.slot
+		config.SlotNumber = &slot
+	}
+	ctx, err := crypto11.Configure(config)
+	if err != nil {
+		return nil, err
+	}
+	pkcs11Contexts.m[s] = ctx
+	return ctx, nil
+}
+
+// pkcs11Certificate returns a client certificate whose private key stays
+// in the token, every signature being made by it. The certificate chain is
+// certPEM, or the certificate stored in the token with the key's label.
+func pkcs11Certificate(s pkcs11Settings, certPEM string) (tls.Certificate, error) {
+	ctx, err := pkcs11Context(s)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	key, err := ctx.FindKeyPair(nil, []byte(s.keyLabel))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if key == nil {
+		return tls.Certificate{}, fmt.Errorf("no key pair labelled %q in the token", s.keyLabel)
+	}
+
+	chain := pemCertificates(certPEM)
+	if len(chain) == 0 {
+		cert, err := ctx.FindCertificate(nil, []byte(s.keyLabel), nil)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		if cert == nil {
+			return tls.Certificate{}, fmt.Errorf("no certificate labelled %q in the token; set client_crt", s.keyLabel)
+		}
+		chain = [][]byte{cert.Raw}
+	}
+	return tls.Certificate{Certificate: chain, PrivateKey: key}, nil
+}
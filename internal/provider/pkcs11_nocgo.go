@@ -0,0 +1,14 @@
+//go:build !cgo
+// +build !cgo
+
+package provider
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// pkcs11Certificate needs cgo to load the PKCS#11 module.
+func pkcs11Certificate(s pkcs11Settings, certPEM string) (tls.Certificate, error) {
+	return tls.Certificate{}, fmt.Errorf("this build of the provider has no PKCS#11 support: it must be built with cgo enabled")
+}
@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"context"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestNewTLSConfig_pkcs11MissingModule(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{
+		"pkcs11": []interface{}{map[string]interface{}{
+			"module_path": "/nonexistent/libpkcs11.so",
+			"token_label": "token",
+			"pin":         "1234",
+			"key_label":   "client",
+		}},
+	})
+	_, diags := newTLSConfig(context.Background(), d, &providerConfig{})
+	if !diags.HasError() {
+		t.Fatalf("expected an error for a missing PKCS#11 module")
+	}
+	if !strings.Contains(diags[0].Summary, "PKCS#11") {
+		t.Errorf("got %q; want a PKCS#11 error", diags[0].Summary)
+	}
+}
+
+func TestPEMCertificates(t *testing.T) {
+	_, ca := testClientCertificate(t, "chain")
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}))
+	chain := "junk\n" + caPEM + caPEM
+	if got := pemCertificates(chain); len(got) != 2 {
+		t.Fatalf("got %d certificates; want 2", len(got))
+	}
+	if got := pemCertificates(""); len(got) != 0 {
+		t.Fatalf("got %d certificates from an empty chain", len(got))
+	}
+}
@@ -1,15 +1,323 @@
 package provider
 
 import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
+// providerConfig is the state shared by every data source read through a
+// single configured provider instance.
+type providerConfig struct {
+	retryBudget    *retryBudget
+	retryPolicy    *retryPolicy
+	requestTimeout time.Duration
+	tokenCache     *tokenFileCache
+	tokens         *tokenStore
+	har            *harRecorder
+	rateLimiter    *rateLimiter
+	throttle       *throttle
+	metrics        *metrics
+	proxyURL       string
+	noEnvProxy     bool
+	httpVersion    string
+	baseURL        string
+	defaultHeaders map[string]interface{}
+	tls            *providerTLS
+}
+
 func New() *schema.Provider {
 	return &schema.Provider{
-		Schema: map[string]*schema.Schema{},
+		Schema: map[string]*schema.Schema{
+			"retry_budget": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"max_retries": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							ValidateFunc: validateNonNegative,
+						},
+						"max_delay_ms": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							ValidateFunc: validateNonNegative,
+						},
+					},
+				},
+			},
+			"retry": retrySchema(),
+			"request_timeout_ms": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validateNonNegative,
+			},
+			"proxy_url": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsURLWithScheme(proxySchemes),
+			},
+			"proxy_from_environment": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  true,
+			},
+			"http_version": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice(httpVersions, false),
+			},
+			"base_url": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+			},
+			"default_request_headers": {
+				Type:      schema.TypeMap,
+				Optional:  true,
+				Sensitive: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"tls": tlsSchema(),
+			"rate_limit_pacing": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"rate_limit_max_wait_ms": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      int(defaultRateLimitMaxWait / time.Millisecond),
+				ValidateFunc: validateNonNegative,
+			},
+			"requests_per_second": {
+				Type:         schema.TypeFloat,
+				Optional:     true,
+				ValidateFunc: validation.FloatAtLeast(0),
+			},
+			"requests_burst": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      1,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"metrics": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"statsd_address": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"prefix": {
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "http_full",
+						},
+						"log_summary": {
+							Type:     schema.TypeBool,
+							Optional: true,
+							Default:  false,
+						},
+					},
+				},
+			},
+			"har_file": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"token_cache": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"path": {
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "~/.terraform.d/http-full/token-cache",
+						},
+						"key_env": {
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "HTTP_FULL_TOKEN_CACHE_KEY",
+						},
+						"key_command": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+		},
 		DataSourcesMap: map[string]*schema.Resource{
-			"http": dataSource(),
+			"http":             dataSource(),
+			"http_batch":       dataSourceBatch(),
+			"http_compare":     dataSourceCompare(),
+			"http_extract":     dataSourceExtract(),
+			"http_mock_server": dataSourceMockServer(),
+			"http_sequence":    dataSourceSequence(),
+		},
+		ResourcesMap: map[string]*schema.Resource{
+			"http_collection": resourceCollection(),
+			"http_request":    resourceRequest(),
 		},
-		ResourcesMap: map[string]*schema.Resource{},
+		ConfigureContextFunc: providerConfigure,
+	}
+}
+
+func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
+	config := &providerConfig{}
+
+	if v, ok := d.GetOk("retry_budget"); ok {
+		budget := v.([]interface{})[0].(map[string]interface{})
+		config.retryBudget = newRetryBudget(
+			budget["max_retries"].(int),
+			time.Duration(budget["max_delay_ms"].(int))*time.Millisecond,
+		)
+	}
+
+	config.retryPolicy = expandRetryPolicy(d.Get("retry"))
+	config.requestTimeout = time.Duration(d.Get("request_timeout_ms").(int)) * time.Millisecond
+
+	config.proxyURL = d.Get("proxy_url").(string)
+	config.noEnvProxy = !d.Get("proxy_from_environment").(bool)
+	config.httpVersion = d.Get("http_version").(string)
+
+	config.baseURL = d.Get("base_url").(string)
+	config.defaultHeaders = d.Get("default_request_headers").(map[string]interface{})
+	tlsDefaults, err := expandProviderTLS(d.Get("tls"))
+	if err != nil {
+		return nil, diag.Errorf("Error configuring tls: %s", err)
+	}
+	config.tls = tlsDefaults
+
+	if d.Get("rate_limit_pacing").(bool) {
+		config.rateLimiter = newRateLimiter(time.Duration(d.Get("rate_limit_max_wait_ms").(int)) * time.Millisecond)
+	}
+	if v, ok := d.GetOk("requests_per_second"); ok {
+		config.throttle = newThrottle(v.(float64), d.Get("requests_burst").(int))
+	}
+
+	if v, ok := d.GetOk("metrics"); ok {
+		m := v.([]interface{})[0].(map[string]interface{})
+		metrics, err := newMetrics(m["statsd_address"].(string), m["prefix"].(string), m["log_summary"].(bool))
+		if err != nil {
+			return nil, diag.Errorf("Error configuring metrics: %s", err)
+		}
+		config.metrics = metrics
+	}
+
+	if v, ok := d.GetOk("har_file"); ok {
+		config.har = newHARRecorder(v.(string))
+	}
+
+	if v, ok := d.GetOk("token_cache"); ok {
+		cache := v.([]interface{})[0].(map[string]interface{})
+		var keyCommand []string
+		for _, arg := range cache["key_command"].([]interface{}) {
+			keyCommand = append(keyCommand, arg.(string))
+		}
+		tokenCache, err := newTokenFileCache(cache["path"].(string), cache["key_env"].(string), keyCommand)
+		if err != nil {
+			return nil, diag.Errorf("Error configuring token cache: %s", err)
+		}
+		config.tokenCache = tokenCache
+	}
+
+	config.tokens = newTokenStore(config.tokenCache)
+
+	return config, nil
+}
+
+// wrapTransport adds the provider-wide behaviour, throttling, rate limit
+// pacing, traffic recording and metrics, to the transport of a client.
+func (c *providerConfig) wrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return c.metrics.wrap(c.har.wrap(c.throttle.wrap(c.rateLimiter.wrap(rt))))
+}
+
+// resolveURL returns the URL requested for the url of a data source:
+// relative URLs are appended to the provider's base_url, absolute ones are
+// used unchanged.
+func (c *providerConfig) resolveURL(raw string) string {
+	if c.baseURL == "" {
+		return raw
+	}
+	if u, err := url.Parse(raw); err == nil && u.IsAbs() {
+		return raw
+	}
+	return strings.TrimSuffix(c.baseURL, "/") + "/" + strings.TrimPrefix(raw, "/")
+}
+
+// applyDefaultProxy sets up the proxy of clients that don't configure their
+// own: the provider's proxy_url or else, unless disabled, the proxy named by
+// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func (c *providerConfig) applyDefaultProxy(tr *http.Transport, dns *dnsRecorder) error {
+	if c.proxyURL != "" {
+		return configureProxy(tr, dns, c.proxyURL)
+	}
+	if !c.noEnvProxy {
+		tr.Proxy = http.ProxyFromEnvironment
+	}
+	return nil
+}
+
+// baseTransport returns the transport of clients without proxy settings of
+// their own.
+func (c *providerConfig) baseTransport(tlsConfig *tls.Config) (*http.Transport, error) {
+	tr := &http.Transport{TLSClientConfig: tlsConfig, DisableCompression: true}
+	if err := c.applyDefaultProxy(tr, newDNSRecorder("")); err != nil {
+		return nil, err
+	}
+	applyHTTPVersion(tr, c.httpVersion)
+	return tr, nil
+}
+
+// httpVersions are the values of http_version.
+var httpVersions = []string{"1.1", "2"}
+
+// applyHTTPVersion makes tr negotiate HTTP/2 over TLS with ALPN for version
+// "2", and only ever use HTTP/1.1 otherwise, as the transports here always
+// did: net/http does not attempt HTTP/2 with a custom TLS configuration.
+func applyHTTPVersion(tr *http.Transport, version string) {
+	if version == "2" {
+		tr.ForceAttemptHTTP2 = true
+		return
+	}
+	tr.ForceAttemptHTTP2 = false
+	tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+}
+
+// Shutdown releases what the provider keeps for the lifetime of its process:
+// it logs the metrics summaries that are out of date and stops the mock
+// servers. main calls it
+// once the provider has stopped serving Terraform.
+func Shutdown() {
+	logMetricsSummaries()
+	closeMockServers()
+}
+
+// configFromMeta returns the provider configuration, tolerating a nil meta
+// so that callers never need to special-case an unconfigured provider.
+func configFromMeta(meta interface{}) *providerConfig {
+	if config, ok := meta.(*providerConfig); ok && config != nil {
+		return config
 	}
+	return &providerConfig{tokens: newTokenStore(nil)}
 }
@@ -15,3 +15,24 @@ func TestProvider(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 }
+
+func TestResolveURL(t *testing.T) {
+	cases := []struct {
+		name    string
+		baseURL string
+		url     string
+		want    string
+	}{
+		{"no base", "", "https://api.example.com/v1/items", "https://api.example.com/v1/items"},
+		{"relative", "https://api.example.com/v1", "items?limit=10", "https://api.example.com/v1/items?limit=10"},
+		{"slashes", "https://api.example.com/v1/", "/items", "https://api.example.com/v1/items"},
+		{"absolute", "https://api.example.com/v1", "https://other.example.com/", "https://other.example.com/"},
+	}
+
+	for _, tc := range cases {
+		config := &providerConfig{baseURL: tc.baseURL}
+		if got := config.resolveURL(tc.url); got != tc.want {
+			t.Errorf("%s: got %s; want %s", tc.name, got, tc.want)
+		}
+	}
+}
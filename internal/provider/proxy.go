@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/proxy"
+)
+
+// proxySchemes are the schemes accepted in proxy_url.
+var proxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// configureProxy routes the connections of tr through proxyURL. HTTP and
+// HTTPS proxies are forward proxies, tunnelling https:// requests with
+// CONNECT; credentials in the URL are sent as Proxy-Authorization. With
+// socks5:// hostnames are resolved locally, as the target's resolved
+// addresses are recorded, and the proxy is asked to connect to an IP; with
+// socks5h:// the hostname is passed to the proxy and resolved on its side,
+// for targets that only resolve inside the network behind it. SOCKS
+// credentials in the URL use username/password authentication (RFC 1929).
+func configureProxy(tr *http.Transport, dns *dnsRecorder, proxyURL string) error {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return err
+	}
+	if u.Host == "" {
+		return fmt.Errorf("proxy URL %q has no host", proxyURL)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		tr.Proxy = http.ProxyURL(u)
+		return nil
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if u.User != nil {
+			password, _ := u.User.Password()
+			auth = &proxy.Auth{User: u.User.Username(), Password: password}
+		}
+		dialer, err := proxy.SOCKS5("tcp", u.Host, auth, &net.Dialer{})
+		if err != nil {
+			return err
+		}
+		dial := dialer.(proxy.ContextDialer).DialContext
+		if u.Scheme == "socks5h" {
+			tr.DialContext = dial
+			return nil
+		}
+		dns.dial = resolveLocally(dns.lookupResolver(), dial)
+		tr.DialContext = dns.dialContext
+		return nil
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+}
+
+// refuseRemoteResolution fails the requests of tr that would go through a
+// proxy which resolves the target's hostname itself, as HTTP, HTTPS and
+// socks5h proxies do, so that connect_to can't silently be ignored.
+// proxyURL is the proxy configured for tr, if any; a proxy chosen per
+// request, by a PAC script or the environment, is checked when it is used.
+func refuseRemoteResolution(tr *http.Transport, proxyURL string) error {
+	if u, err := url.Parse(proxyURL); err == nil && u.Scheme == "socks5h" {
+		return fmt.Errorf("the socks5h proxy %s resolves hostnames itself; use socks5:// instead", u.Host)
+	}
+	next := tr.Proxy
+	if next == nil {
+		return nil
+	}
+	tr.Proxy = func(req *http.Request) (*url.URL, error) {
+		u, err := next(req)
+		if err == nil && u != nil {
+			return nil, fmt.Errorf("connect_to does not apply through the proxy %s, which resolves hostnames itself", u.Host)
+		}
+		return u, err
+	}
+	return nil
+}
+
+// resolveLocally returns a dial func that resolves hostnames itself with
+// resolver and hands dial the IP addresses, trying each in turn.
+func resolveLocally(resolver *net.Resolver, dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+		ips, err := resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error
+		for _, ip := range ips {
+			conn, err := dial(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no addresses found for %s", host)
+		}
+		return nil, lastErr
+	}
+}
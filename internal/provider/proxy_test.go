@@ -0,0 +1,280 @@
+package provider
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testSOCKS5Server is a minimal SOCKS5 proxy recording the destination of
+// every CONNECT as requested by the client: a hostname or an IP address.
+// When credentials are set, clients must authenticate with them.
+type testSOCKS5Server struct {
+	listener    net.Listener
+	credentials string
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func setUpMockSOCKS5Server(t *testing.T) *testSOCKS5Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	s := &testSOCKS5Server{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testSOCKS5Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	// Greeting: version, methods. Reply: no authentication, or
+	// username/password when credentials are required.
+	buf := make([]byte, 262)
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		return
+	}
+	if s.credentials == "" {
+		conn.Write([]byte{5, 0})
+	} else {
+		conn.Write([]byte{5, 2})
+
+		// Subnegotiation: version, username, password.
+		io.ReadFull(conn, buf[:2])
+		user := make([]byte, buf[1])
+		io.ReadFull(conn, user)
+		io.ReadFull(conn, buf[:1])
+		password := make([]byte, buf[0])
+		io.ReadFull(conn, password)
+		if string(user)+":"+string(password) != s.credentials {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+	}
+
+	// Request: version, CONNECT, reserved, address type.
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return
+	}
+	var host string
+	switch buf[3] {
+	case 1:
+		io.ReadFull(conn, buf[:4])
+		host = net.IP(buf[:4]).String()
+	case 3:
+		io.ReadFull(conn, buf[:1])
+		n := int(buf[0])
+		io.ReadFull(conn, buf[:n])
+		host = string(buf[:n])
+	case 4:
+		io.ReadFull(conn, buf[:16])
+		host = net.IP(buf[:16]).String()
+	}
+	io.ReadFull(conn, buf[:2])
+	port := binary.BigEndian.Uint16(buf[:2])
+
+	s.mu.Lock()
+	s.requests = append(s.requests, host)
+	s.mu.Unlock()
+
+	// Hostnames only resolve on the proxy side.
+	if host == "internal.test" {
+		host = "127.0.0.1"
+	}
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		conn.Write([]byte{5, 4, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+func (s *testSOCKS5Server) lastRequest() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return ""
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+func TestConfigureProxy_socks5(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	socks := setUpMockSOCKS5Server(t)
+	defer socks.listener.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	get := func(scheme, host string) error {
+		dns := newDNSRecorder(host)
+		tr := &http.Transport{DialContext: dns.dialContext}
+		if err := configureProxy(tr, dns, scheme+"://"+socks.listener.Addr().String()); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		resp, err := (&http.Client{Transport: tr}).Get("http://" + net.JoinHostPort(host, port))
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// socks5h hands the hostname to the proxy, which resolves it.
+	if err := get("socks5h", "internal.test"); err != nil {
+		t.Fatalf("socks5h: err: %s", err)
+	}
+	if got := socks.lastRequest(); got != "internal.test" {
+		t.Fatalf("socks5h: proxy was asked for %q; want the hostname", got)
+	}
+
+	// socks5 resolves locally and asks the proxy for an address.
+	if err := get("socks5", "localhost"); err != nil {
+		t.Fatalf("socks5: err: %s", err)
+	}
+	if got := socks.lastRequest(); net.ParseIP(got) == nil {
+		t.Fatalf("socks5: proxy was asked for %q; want an IP address", got)
+	}
+	if err := get("socks5", "internal.test"); err == nil || !strings.Contains(err.Error(), "internal.test") {
+		t.Fatalf("socks5: got %v; want local resolution of internal.test to fail", err)
+	}
+}
+
+func TestConfigureProxy_socks5Auth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	socks := setUpMockSOCKS5Server(t)
+	defer socks.listener.Close()
+	socks.credentials = "user:pass"
+
+	get := func(userinfo string) error {
+		tr := &http.Transport{}
+		if err := configureProxy(tr, newDNSRecorder(""), "socks5h://"+userinfo+socks.listener.Addr().String()); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		resp, err := (&http.Client{Transport: tr}).Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	if err := get("user:pass@"); err != nil {
+		t.Fatalf("valid credentials: err: %s", err)
+	}
+	if err := get("user:wrong@"); err == nil {
+		t.Fatalf("invalid credentials: got no error")
+	}
+	if err := get(""); err == nil {
+		t.Fatalf("no credentials: got no error")
+	}
+}
+
+func TestConfigureProxy_http(t *testing.T) {
+	var requested, auth string
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+		auth = r.Header.Get("Proxy-Authorization")
+		w.Write([]byte("proxied"))
+	}))
+	defer proxyServer.Close()
+
+	proxyURL := strings.Replace(proxyServer.URL, "http://", "http://user:pass@", 1)
+	config := &providerConfig{proxyURL: proxyURL}
+	tr, err := config.baseTransport(nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp, err := (&http.Client{Transport: tr}).Get("http://internal.test/status")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+
+	if requested != "http://internal.test/status" {
+		t.Errorf("proxy was asked for %q; want the absolute target URL", requested)
+	}
+	if auth != "Basic dXNlcjpwYXNz" {
+		t.Errorf("got Proxy-Authorization %q; want the URL credentials", auth)
+	}
+}
+
+func TestApplyDefaultProxy(t *testing.T) {
+	tr := &http.Transport{}
+	if err := (&providerConfig{}).applyDefaultProxy(tr, newDNSRecorder("")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if tr.Proxy == nil {
+		t.Errorf("environment proxy settings are not used by default")
+	}
+
+	tr = &http.Transport{}
+	if err := (&providerConfig{noEnvProxy: true}).applyDefaultProxy(tr, newDNSRecorder("")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if tr.Proxy != nil {
+		t.Errorf("environment proxy settings are used although disabled")
+	}
+}
+
+func TestRefuseRemoteResolution(t *testing.T) {
+	cases := []struct {
+		proxyURL string
+		proxied  bool
+		err      string
+	}{
+		{"", false, ""},
+		{"socks5://bastion:1080", false, ""},
+		{"socks5h://bastion:1080", false, "socks5h proxy bastion:1080"},
+		{"http://proxy.example.com:8080", true, "through the proxy proxy.example.com:8080"},
+	}
+	for _, tc := range cases {
+		tr := &http.Transport{}
+		if tc.proxied {
+			tr.Proxy = http.ProxyURL(&url.URL{Scheme: "http", Host: "proxy.example.com:8080"})
+		}
+		err := refuseRemoteResolution(tr, tc.proxyURL)
+		if err == nil && tr.Proxy != nil {
+			req, _ := http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+			_, err = tr.Proxy(req)
+		}
+		if tc.err == "" && err != nil {
+			t.Errorf("%q: err: %s", tc.proxyURL, err)
+		} else if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%q: got %v; want an error containing %q", tc.proxyURL, err, tc.err)
+		}
+	}
+
+	// A proxy chosen per request only fails the requests it is used for.
+	tr := &http.Transport{Proxy: func(req *http.Request) (*url.URL, error) { return nil, nil }}
+	if err := refuseRemoteResolution(tr, ""); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	if u, err := tr.Proxy(req); u != nil || err != nil {
+		t.Errorf("direct request: got %v, %v; want no proxy", u, err)
+	}
+}
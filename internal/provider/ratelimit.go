@@ -0,0 +1,192 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// defaultRateLimitMaxWait is the longest a request waits for a quota by
+// default; GitHub's hourly window would otherwise stall a run silently.
+const defaultRateLimitMaxWait = time.Minute
+
+// rateLimiter paces the requests sent to each host by a provider instance
+// from the rate limit headers of earlier responses, so that a run slows down
+// before exhausting a quota instead of hitting hard 429s. A request that
+// would wait longer than maxWait (unless 0) fails instead. A nil
+// *rateLimiter never waits.
+type rateLimiter struct {
+	mu      sync.Mutex
+	hosts   map[string]*rateState
+	now     func() time.Time
+	maxWait time.Duration
+}
+
+type rateState struct {
+	limit     int
+	remaining int
+	reset     time.Time
+	next      time.Time
+}
+
+func newRateLimiter(maxWait time.Duration) *rateLimiter {
+	return &rateLimiter{hosts: map[string]*rateState{}, now: time.Now, maxWait: maxWait}
+}
+
+// rateLimitHeaders lists the limit, remaining and reset header triples
+// understood, in order of preference: GitHub and most APIs, the IETF draft
+// and Twitter style.
+var rateLimitHeaders = [][3]string{
+	{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
+	{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"},
+	{"X-Rate-Limit-Limit", "X-Rate-Limit-Remaining", "X-Rate-Limit-Reset"},
+}
+
+// observe records the quota advertised by a response from host.
+func (l *rateLimiter) observe(host string, h http.Header) {
+	if l == nil {
+		return
+	}
+	for _, names := range rateLimitHeaders {
+		remaining, err := strconv.Atoi(h.Get(names[1]))
+		if err != nil {
+			continue
+		}
+		reset, err := strconv.ParseInt(h.Get(names[2]), 10, 64)
+		if err != nil {
+			continue
+		}
+		limit, _ := strconv.Atoi(h.Get(names[0]))
+
+		now := l.now()
+		state := &rateState{limit: limit, remaining: remaining}
+		// Large values are epoch seconds (GitHub), small ones a delay in
+		// seconds (IETF draft).
+		if reset > 1000000000 {
+			state.reset = time.Unix(reset, 0)
+		} else {
+			state.reset = now.Add(time.Duration(reset) * time.Second)
+		}
+
+		switch {
+		case remaining <= 0:
+			state.next = state.reset
+		case limit > 0 && remaining < limit/10:
+			// Spread the last tenth of the quota over what is left of
+			// the window.
+			state.next = now.Add(state.reset.Sub(now) / time.Duration(remaining+1))
+		}
+
+		l.mu.Lock()
+		l.hosts[host] = state
+		l.mu.Unlock()
+		return
+	}
+}
+
+// delay returns how long a request to host should wait.
+func (l *rateLimiter) delay(host string) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state, ok := l.hosts[host]
+	if !ok {
+		return 0
+	}
+	now := l.now()
+	if now.After(state.reset) {
+		delete(l.hosts, host)
+		return 0
+	}
+	if state.next.After(now) {
+		return state.next.Sub(now)
+	}
+	return 0
+}
+
+func (l *rateLimiter) wait(ctx context.Context, host string) error {
+	d := l.delay(host)
+	if d <= 0 {
+		return nil
+	}
+	if l.maxWait > 0 && d > l.maxWait {
+		return fmt.Errorf("rate limit of %s exhausted: the quota resets in %s, longer than rate_limit_max_wait_ms (%s)", host, d.Round(time.Second), l.maxWait)
+	}
+	log.Printf("[WARN] http-full rate limit of %s nearly exhausted, waiting %s", host, d.Round(time.Millisecond))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// wrap returns a RoundTripper pacing the requests of rt. A nil limiter
+// returns rt unchanged.
+func (l *rateLimiter) wrap(rt http.RoundTripper) http.RoundTripper {
+	if l == nil {
+		return rt
+	}
+	return &rateLimitTransport{limiter: l, next: rt}
+}
+
+type rateLimitTransport struct {
+	limiter *rateLimiter
+	next    http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if err := t.limiter.wait(req.Context(), host); err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err == nil {
+		t.limiter.observe(host, resp.Header)
+	}
+	return resp, err
+}
+
+// throttle caps the rate of all requests sent through a provider instance,
+// whatever their host, so that large for_each fan-outs stay below the
+// limits of an API instead of tripping them. A nil *throttle never waits.
+type throttle struct {
+	limiter *rate.Limiter
+}
+
+func newThrottle(requestsPerSecond float64, burst int) *throttle {
+	if burst < 1 {
+		burst = 1
+	}
+	return &throttle{limiter: rate.NewLimiter(rate.Limit(requestsPerSecond), burst)}
+}
+
+// wrap returns a RoundTripper throttling the requests of rt. A nil throttle
+// returns rt unchanged.
+func (t *throttle) wrap(rt http.RoundTripper) http.RoundTripper {
+	if t == nil {
+		return rt
+	}
+	return &throttleTransport{throttle: t, next: rt}
+}
+
+type throttleTransport struct {
+	throttle *throttle
+	next     http.RoundTripper
+}
+
+func (t *throttleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.throttle.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
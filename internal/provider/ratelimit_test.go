@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := newRateLimiter(0)
+	limiter.now = func() time.Time { return now }
+
+	header := func(pairs ...string) http.Header {
+		h := http.Header{}
+		for i := 0; i < len(pairs); i += 2 {
+			h.Set(pairs[i], pairs[i+1])
+		}
+		return h
+	}
+
+	limiter.observe("api.github.com", header(
+		"X-RateLimit-Limit", "5000", "X-RateLimit-Remaining", "4000", "X-RateLimit-Reset", "1700000600"))
+	if d := limiter.delay("api.github.com"); d != 0 {
+		t.Errorf("plenty of quota left: delay %s; want none", d)
+	}
+
+	limiter.observe("api.github.com", header(
+		"X-RateLimit-Limit", "5000", "X-RateLimit-Remaining", "99", "X-RateLimit-Reset", "1700000600"))
+	if d := limiter.delay("api.github.com"); d != 6*time.Second {
+		t.Errorf("low quota: delay %s; want the window spread over the remaining requests (6s)", d)
+	}
+
+	limiter.observe("example.com", header("RateLimit-Remaining", "0", "RateLimit-Reset", "30"))
+	if d := limiter.delay("example.com"); d != 30*time.Second {
+		t.Errorf("exhausted quota: delay %s; want 30s until reset", d)
+	}
+
+	now = now.Add(time.Hour)
+	if d := limiter.delay("example.com"); d != 0 {
+		t.Errorf("after reset: delay %s; want none", d)
+	}
+
+	var nilLimiter *rateLimiter
+	if d := nilLimiter.delay("example.com"); d != 0 {
+		t.Errorf("nil limiter: delay %s; want none", d)
+	}
+
+	limiter.maxWait = 10 * time.Second
+	limiter.observe("example.com", header("RateLimit-Remaining", "0", "RateLimit-Reset", "30"))
+	if err := limiter.wait(context.Background(), "example.com"); err == nil || !strings.Contains(err.Error(), "rate_limit_max_wait_ms") {
+		t.Errorf("wait past rate_limit_max_wait_ms: got %v; want an error", err)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newThrottle(50, 2).wrap(http.DefaultTransport)}
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		resp.Body.Close()
+	}
+	// A burst of 2, then 3 more at 50 per second.
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("5 requests took %s; want at least 60ms at 50 per second with a burst of 2", elapsed)
+	}
+}
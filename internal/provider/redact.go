@@ -0,0 +1,159 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const redacted = "REDACTED"
+
+// sensitiveHeaders are always redacted from captured traffic.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// isSensitiveName reports whether a header or query parameter is likely to
+// carry a credential: the well-known authentication headers and any whose
+// name mentions a token, secret, key, password or signature.
+func isSensitiveName(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	if sensitiveHeaders[name] {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, word := range []string{"token", "secret", "key", "password", "signature", "session"} {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactHeaders returns a copy of h with the values of sensitive headers
+// replaced.
+func redactHeaders(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for name, values := range h {
+		if isSensitiveName(name) {
+			out[name] = []string{redacted}
+			continue
+		}
+		out[name] = append([]string(nil), values...)
+	}
+	return out
+}
+
+// redactURL returns u with the values of sensitive query parameters and any
+// password replaced.
+func redactURL(u *url.URL) string {
+	out := *u
+	if _, ok := out.User.Password(); ok {
+		out.User = url.UserPassword(out.User.Username(), redacted)
+	}
+	query := out.Query()
+	changed := false
+	for name := range query {
+		if isSensitiveName(name) {
+			query[name] = []string{redacted}
+			changed = true
+		}
+	}
+	if changed {
+		out.RawQuery = query.Encode()
+	}
+	return out.String()
+}
+
+// redactBody returns body with the values of sensitive fields replaced when
+// it is a JSON document or a form, and unchanged otherwise.
+func redactBody(contentType string, body []byte) []byte {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return body
+		}
+		changed := false
+		for name := range form {
+			if isSensitiveName(name) {
+				form[name] = []string{redacted}
+				changed = true
+			}
+		}
+		if !changed {
+			return body
+		}
+		return []byte(form.Encode())
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return body
+		}
+		if !redactJSON(doc) {
+			return body
+		}
+		out, err := json.Marshal(doc)
+		if err != nil {
+			return body
+		}
+		return out
+	}
+	return body
+}
+
+// redactJSON replaces, in place, the values of the sensitive fields of the
+// objects in doc and reports whether it changed anything.
+func redactJSON(doc interface{}) bool {
+	changed := false
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for name, value := range v {
+			if isSensitiveName(name) {
+				v[name] = redacted
+				changed = true
+				continue
+			}
+			changed = redactJSON(value) || changed
+		}
+	case []interface{}:
+		for _, value := range v {
+			changed = redactJSON(value) || changed
+		}
+	}
+	return changed
+}
+
+// recordingPolicy tells the transports that record traffic, the HAR
+// recorder and debug logging, how much of a response body they may keep.
+type recordingPolicy struct {
+	// omitBody is set for sensitive_response, whose body is never recorded.
+	omitBody bool
+	// limit is the number of body bytes recorded at most.
+	limit int64
+}
+
+type recordingPolicyKey struct{}
+
+// withRecordingPolicy returns a context whose requests are recorded as p
+// says.
+func withRecordingPolicy(ctx context.Context, p recordingPolicy) context.Context {
+	return context.WithValue(ctx, recordingPolicyKey{}, p)
+}
+
+// recordingPolicyFrom returns the policy of ctx, by default recording
+// bodies up to the default max_response_body_size.
+func recordingPolicyFrom(ctx context.Context) recordingPolicy {
+	p, _ := ctx.Value(recordingPolicyKey{}).(recordingPolicy)
+	if p.limit <= 0 {
+		p.limit = defaultMaxResponseBodySize
+	}
+	return p
+}
@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+)
+
+func validateRegexMap(val interface{}, key string) (warns []string, errs []error) {
+	for name, pattern := range val.(map[string]interface{}) {
+		if _, err := regexp.Compile(pattern.(string)); err != nil {
+			errs = append(errs, fmt.Errorf("%s.%s is not a valid regular expression: %s", key, name, err))
+		}
+	}
+	return
+}
+
+// regexExtract matches the regex_extract patterns against body and adds
+// the first capture group of each, or the whole match for patterns without
+// groups, to outputs.
+func regexExtract(body []byte, patterns map[string]interface{}, outputs map[string]string) error {
+	for name, pattern := range patterns {
+		if _, ok := outputs[name]; ok {
+			return fmt.Errorf("%s is also set by json_extract", name)
+		}
+		re, err := regexp.Compile(pattern.(string))
+		if err != nil {
+			return err
+		}
+		match := re.FindSubmatch(body)
+		if match == nil {
+			return fmt.Errorf("%s: %q matched nothing", name, pattern)
+		}
+		if len(match) > 1 {
+			outputs[name] = string(match[1])
+		} else {
+			outputs[name] = string(match[0])
+		}
+	}
+	return nil
+}
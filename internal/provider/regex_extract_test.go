@@ -0,0 +1,34 @@
+package provider
+
+import (
+	"testing"
+)
+
+func TestRegexExtract(t *testing.T) {
+	body := []byte(`<html><head><meta name="csrf-token" content="a1b2c3"></head><body>Version: 2.14.1</body></html>`)
+	outputs := map[string]string{}
+	err := regexExtract(body, map[string]interface{}{
+		"token":   `name="csrf-token" content="([^"]+)"`,
+		"version": `Version: (\d+)\.\d+\.\d+`,
+		"release": `\d+\.\d+\.\d+`,
+	}, outputs)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	want := map[string]string{"token": "a1b2c3", "version": "2", "release": "2.14.1"}
+	for k, v := range want {
+		if outputs[k] != v {
+			t.Errorf("%s: got %q; want %q", k, outputs[k], v)
+		}
+	}
+
+	if err := regexExtract(body, map[string]interface{}{"build": `Build: (\d+)`}, map[string]string{}); err == nil {
+		t.Errorf("expected an error for a pattern matching nothing")
+	}
+	if err := regexExtract(body, map[string]interface{}{"token": `csrf`}, map[string]string{"token": "x"}); err == nil {
+		t.Errorf("expected an error for a name also set by json_extract")
+	}
+	if _, errs := validateRegexMap(map[string]interface{}{"bad": `(`}, "regex_extract"); len(errs) == 0 {
+		t.Errorf("expected a validation error for an invalid pattern")
+	}
+}
@@ -0,0 +1,369 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resourceCollection manages the set of objects exposed by a collection
+// endpoint that only offers a bulk REST API, such as DNS records or firewall
+// rules. Objects are matched by key_field; remote objects whose key isn't
+// managed are left alone.
+func resourceCollection() *schema.Resource {
+	return &schema.Resource{
+		Description: "Manages a set of objects behind a REST collection endpoint.",
+
+		CreateContext: resourceCollectionCreate,
+		ReadContext:   resourceCollectionRead,
+		UpdateContext: resourceCollectionUpdate,
+		DeleteContext: resourceCollectionDelete,
+
+		Schema: map[string]*schema.Schema{
+			"url": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+
+			"key_field": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+
+			"items_field": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"item_url": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"request_headers": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"ca": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"max_response_body_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      defaultMaxResponseBodySize,
+				ValidateFunc: validateNonNegative,
+			},
+
+			"retry_non_idempotent": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"items": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:             schema.TypeString,
+					DiffSuppressFunc: jsonDriftSuppressFunc("strict_drift"),
+				},
+			},
+
+			"strict_drift": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+		},
+	}
+}
+
+// collectionClient sends the requests of a single collection operation.
+type collectionClient struct {
+	client   *http.Client
+	config   *providerConfig
+	headers  map[string]interface{}
+	url      string
+	itemURL  string
+	limit    int64
+	retryAll bool
+}
+
+func newCollectionClient(ctx context.Context, d *schema.ResourceData, meta interface{}) (*collectionClient, diag.Diagnostics) {
+	config := configFromMeta(meta)
+	tlsConfig, diags := newTLSConfig(ctx, d, config)
+	if diags.HasError() {
+		return nil, diags
+	}
+	tr, err := config.baseTransport(tlsConfig)
+	if err != nil {
+		return nil, append(diags, diag.Errorf("Error configuring proxy: %s", err)...)
+	}
+	return &collectionClient{
+		client: &http.Client{
+			Transport: config.wrapTransport(&decompressTransport{next: tr, limit: defaultMaxDecompressedBytes}),
+			Timeout:   config.requestTimeout,
+		},
+		config:   config,
+		headers:  d.Get("request_headers").(map[string]interface{}),
+		url:      d.Get("url").(string),
+		itemURL:  d.Get("item_url").(string),
+		limit:    int64(d.Get("max_response_body_size").(int)),
+		retryAll: d.Get("retry_non_idempotent").(bool),
+	}, diags
+}
+
+// urlFor returns the URL of the object with the given key: item_url with
+// "{key}" substituted, or the collection URL followed by the key. The key is
+// escaped as a single path segment.
+func (c *collectionClient) urlFor(key string) string {
+	key = url.PathEscape(key)
+	if c.itemURL != "" {
+		return strings.Replace(c.itemURL, "{key}", key, -1)
+	}
+	return strings.TrimSuffix(c.url, "/") + "/" + key
+}
+
+// do sends a request with an optional JSON body and returns the response
+// body, failing on any non-2xx status.
+func (c *collectionClient) do(ctx context.Context, method, url string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value.(string))
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := doWithRetry(ctx, c.client, req, retryPolicyFor(method, c.config.retryPolicy, c.retryAll), c.config.retryBudget)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := readBody(resp, c.limit)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s %s: HTTP request error. Response code: %d", method, url, resp.StatusCode)
+	}
+	return respBody, nil
+}
+
+// list returns the remote objects indexed by key.
+func (c *collectionClient) list(ctx context.Context, keyField, itemsField string) (map[string]interface{}, error) {
+	body, err := c.do(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("decoding collection: %s", err)
+	}
+	if itemsField != "" {
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("collection response is not an object with a %q field", itemsField)
+		}
+		doc = obj[itemsField]
+	}
+	items, ok := doc.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("collection response is not a list")
+	}
+
+	remote := make(map[string]interface{}, len(items))
+	for _, item := range items {
+		key, err := collectionKey(item, keyField)
+		if err != nil {
+			continue
+		}
+		remote[key] = item
+	}
+	return remote, nil
+}
+
+// collectionKey returns the value of keyField in item as a string.
+func collectionKey(item interface{}, keyField string) (string, error) {
+	obj, ok := item.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("item is not an object")
+	}
+	v, ok := obj[keyField]
+	if !ok || v == nil {
+		return "", fmt.Errorf("item has no %q field", keyField)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	out, err := json.Marshal(v)
+	return string(out), err
+}
+
+// collectionItems decodes the items attribute into objects indexed by key,
+// also returning the keys in their configured order.
+func collectionItems(v interface{}, keyField string) (map[string]interface{}, []string, error) {
+	items := map[string]interface{}{}
+	var keys []string
+	for i, raw := range v.([]interface{}) {
+		var item interface{}
+		if err := json.Unmarshal([]byte(raw.(string)), &item); err != nil {
+			return nil, nil, fmt.Errorf("items[%d] is not valid JSON: %s", i, err)
+		}
+		key, err := collectionKey(item, keyField)
+		if err != nil {
+			return nil, nil, fmt.Errorf("items[%d]: %s", i, err)
+		}
+		if _, ok := items[key]; ok {
+			return nil, nil, fmt.Errorf("items[%d]: duplicate key %q", i, key)
+		}
+		items[key] = item
+		keys = append(keys, key)
+	}
+	return items, keys, nil
+}
+
+func resourceCollectionCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	c, diags := newCollectionClient(ctx, d, meta)
+	if diags.HasError() {
+		return diags
+	}
+	items, keys, err := collectionItems(d.Get("items"), d.Get("key_field").(string))
+	if err != nil {
+		return diag.Errorf("Error reading items: %s", err)
+	}
+
+	d.SetId(c.url)
+	for _, key := range keys {
+		body, _ := json.Marshal(items[key])
+		if _, err := c.do(ctx, http.MethodPost, c.url, body); err != nil {
+			return append(diags, diag.Errorf("Error creating item %q: %s", key, err)...)
+		}
+	}
+
+	return append(diags, resourceCollectionRead(ctx, d, meta)...)
+}
+
+func resourceCollectionRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	c, diags := newCollectionClient(ctx, d, meta)
+	if diags.HasError() {
+		return diags
+	}
+	keyField := d.Get("key_field").(string)
+
+	remote, err := c.list(ctx, keyField, d.Get("items_field").(string))
+	if err != nil {
+		return append(diags, diag.Errorf("Error listing collection: %s", err)...)
+	}
+	_, keys, err := collectionItems(d.Get("items"), keyField)
+	if err != nil {
+		return append(diags, diag.Errorf("Error reading items: %s", err)...)
+	}
+
+	// Only the managed objects are kept in state, in their configured order;
+	// those deleted remotely drop out and are recreated on the next apply.
+	var state []interface{}
+	for _, key := range keys {
+		item, ok := remote[key]
+		if !ok {
+			continue
+		}
+		out, err := json.Marshal(item)
+		if err != nil {
+			return append(diags, diag.Errorf("Error encoding item %q: %s", key, err)...)
+		}
+		state = append(state, string(out))
+	}
+	if err := d.Set("items", state); err != nil {
+		return append(diags, diag.Errorf("Error setting items: %s", err)...)
+	}
+
+	return diags
+}
+
+func resourceCollectionUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	c, diags := newCollectionClient(ctx, d, meta)
+	if diags.HasError() {
+		return diags
+	}
+	keyField := d.Get("key_field").(string)
+
+	o, n := d.GetChange("items")
+	current, _, err := collectionItems(o, keyField)
+	if err != nil {
+		return append(diags, diag.Errorf("Error reading items: %s", err)...)
+	}
+	desired, keys, err := collectionItems(n, keyField)
+	if err != nil {
+		return append(diags, diag.Errorf("Error reading items: %s", err)...)
+	}
+	strict := d.Get("strict_drift").(bool)
+
+	for key := range current {
+		if _, ok := desired[key]; !ok {
+			if _, err := c.do(ctx, http.MethodDelete, c.urlFor(key), nil); err != nil {
+				return append(diags, diag.Errorf("Error deleting item %q: %s", key, err)...)
+			}
+		}
+	}
+	for _, key := range keys {
+		body, _ := json.Marshal(desired[key])
+		remote, ok := current[key]
+		switch {
+		case !ok:
+			_, err = c.do(ctx, http.MethodPost, c.url, body)
+		case strict && !jsonEqual(desired[key], remote), !strict && !jsonSubset(desired[key], remote):
+			_, err = c.do(ctx, http.MethodPatch, c.urlFor(key), body)
+		}
+		if err != nil {
+			return append(diags, diag.Errorf("Error updating item %q: %s", key, err)...)
+		}
+	}
+
+	return append(diags, resourceCollectionRead(ctx, d, meta)...)
+}
+
+func resourceCollectionDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	c, diags := newCollectionClient(ctx, d, meta)
+	if diags.HasError() {
+		return diags
+	}
+	current, _, err := collectionItems(d.Get("items"), d.Get("key_field").(string))
+	if err != nil {
+		return append(diags, diag.Errorf("Error reading items: %s", err)...)
+	}
+
+	for key := range current {
+		if _, err := c.do(ctx, http.MethodDelete, c.urlFor(key), nil); err != nil {
+			return append(diags, diag.Errorf("Error deleting item %q: %s", key, err)...)
+		}
+	}
+
+	d.SetId("")
+	return diags
+}
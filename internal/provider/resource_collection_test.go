@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// testCollection is an in-memory REST collection that, like most APIs, adds
+// a server-side field to the objects it stores.
+type testCollection struct {
+	mu      sync.Mutex
+	records map[string]map[string]interface{}
+	calls   []string
+}
+
+func setUpMockCollectionServer() (*testCollection, *httptest.Server) {
+	c := &testCollection{records: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		key := strings.TrimPrefix(r.URL.Path, "/records/")
+		if r.Method != http.MethodGet {
+			c.calls = append(c.calls, r.Method+" "+r.URL.Path)
+		}
+		switch r.Method {
+		case http.MethodGet:
+			list := []interface{}{}
+			for _, rec := range c.records {
+				list = append(list, rec)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"records": list})
+		case http.MethodPost:
+			var rec map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&rec)
+			rec["ttl"] = 300.0
+			c.records[rec["name"].(string)] = rec
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodPatch:
+			var rec map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&rec)
+			for k, v := range rec {
+				c.records[key][k] = v
+			}
+		case http.MethodDelete:
+			delete(c.records, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	return c, server
+}
+
+func TestResource_collection(t *testing.T) {
+	collection, server := setUpMockCollectionServer()
+	defer server.Close()
+
+	expectCalls := func(want string) resource.TestCheckFunc {
+		return func(*terraform.State) error {
+			collection.mu.Lock()
+			defer collection.mu.Unlock()
+			sort.Strings(collection.calls)
+			got := strings.Join(collection.calls, ", ")
+			collection.calls = nil
+			if got != want {
+				return fmt.Errorf("sent %q; want %q", got, want)
+			}
+			return nil
+		}
+	}
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders,
+		CheckDestroy: expectCalls("DELETE /records/a, DELETE /records/c"),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testResourceConfig_collection, server.URL, `{ name = "a", value = "1" }, { name = "b", value = "2" }`),
+				Check:  expectCalls("POST /records, POST /records"),
+			},
+			// The ttl added by the server is not drift.
+			{
+				Config: fmt.Sprintf(testResourceConfig_collection, server.URL, `{ name = "a", value = "1" }, { name = "b", value = "2" }`),
+				Check:  expectCalls(""),
+			},
+			{
+				Config: fmt.Sprintf(testResourceConfig_collection, server.URL, `{ name = "a", value = "10" }, { name = "c", value = "3" }`),
+				Check:  expectCalls("DELETE /records/b, PATCH /records/a, POST /records"),
+			},
+		},
+	})
+}
+
+const testResourceConfig_collection = `
+resource "http_collection" "records" {
+  url         = "%s/records"
+  key_field   = "name"
+  items_field = "records"
+  items       = [for item in [%s] : jsonencode(item)]
+}
+`
+
+func TestCollectionClient_urlFor(t *testing.T) {
+	cases := []struct {
+		url     string
+		itemURL string
+		key     string
+		want    string
+	}{
+		{"https://api.example.com/records/", "", "www", "https://api.example.com/records/www"},
+		{"https://api.example.com/records", "", "a/b", "https://api.example.com/records/a%2Fb"},
+		{"https://api.example.com/records", "", "what?#now", "https://api.example.com/records/what%3F%23now"},
+		{"https://api.example.com/records", "https://api.example.com/records/{key}?full=true", "my record", "https://api.example.com/records/my%20record?full=true"},
+		{"https://api.example.com/records", "https://api.example.com/records/{key}", "10.0.0.0/8", "https://api.example.com/records/10.0.0.0%2F8"},
+	}
+	for _, tc := range cases {
+		c := &collectionClient{url: tc.url, itemURL: tc.itemURL}
+		if got := c.urlFor(tc.key); got != tc.want {
+			t.Errorf("%s: urlFor(%q) = %q; want %q", tc.url, tc.key, got, tc.want)
+		}
+	}
+}
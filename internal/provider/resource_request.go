@@ -0,0 +1,308 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// requestMethods are the methods accepted for the requests of http_request.
+var requestMethods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// resourceRequest manages a single remote API object through configurable
+// requests: one creating it, one updating it and one destroying it, with the
+// object read back with GET to detect drift. It replaces null_resource and
+// local-exec curl for generic REST objects such as dashboards.
+func resourceRequest() *schema.Resource {
+	return &schema.Resource{
+		Description: "Manages a remote API object with HTTP requests.",
+
+		CreateContext: resourceRequestCreate,
+		ReadContext:   resourceRequestRead,
+		UpdateContext: resourceRequestUpdate,
+		DeleteContext: resourceRequestDelete,
+
+		Schema: map[string]*schema.Schema{
+			"url": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+
+			"create_method": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      http.MethodPost,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice(requestMethods, false),
+			},
+
+			"body": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DiffSuppressFunc: jsonDriftSuppressFunc("strict_drift"),
+			},
+
+			"object_url": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"id_field": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"update_method": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      http.MethodPatch,
+				ValidateFunc: validation.StringInSlice(requestMethods, false),
+			},
+
+			"update_body": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"destroy_method": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      http.MethodDelete,
+				ValidateFunc: validation.StringInSlice(requestMethods, false),
+			},
+
+			"destroy_body": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"request_headers": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"ca": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"max_response_body_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      defaultMaxResponseBodySize,
+				ValidateFunc: validateNonNegative,
+			},
+
+			"retry_non_idempotent": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"strict_drift": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"object_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"response_body": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+// requestClient sends the requests of a single http_request operation.
+type requestClient struct {
+	client   *http.Client
+	config   *providerConfig
+	headers  map[string]interface{}
+	limit    int64
+	retryAll bool
+}
+
+func newRequestClient(ctx context.Context, d *schema.ResourceData, meta interface{}) (*requestClient, diag.Diagnostics) {
+	config := configFromMeta(meta)
+	// The schema only has ca: the other TLS settings come from the
+	// provider's tls block.
+	tlsConfig, diags := newTLSConfig(ctx, d, config)
+	if diags.HasError() {
+		return nil, diags
+	}
+	tr, err := config.baseTransport(tlsConfig)
+	if err != nil {
+		return nil, append(diags, diag.Errorf("Error configuring proxy: %s", err)...)
+	}
+	return &requestClient{
+		client: &http.Client{
+			Transport: config.wrapTransport(&decompressTransport{next: tr, limit: defaultMaxDecompressedBytes}),
+			Timeout:   config.requestTimeout,
+		},
+		config:   config,
+		headers:  d.Get("request_headers").(map[string]interface{}),
+		limit:    int64(d.Get("max_response_body_size").(int)),
+		retryAll: d.Get("retry_non_idempotent").(bool),
+	}, diags
+}
+
+// do sends a request with an optional body and returns the response status
+// and body. Unlike collectionClient.do it leaves the status to the caller,
+// which may accept a 404.
+func (c *requestClient) do(ctx context.Context, method, url, body string) (int, []byte, error) {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value.(string))
+	}
+	if body != "" && req.Header.Get("Content-Type") == "" && json.Valid([]byte(body)) {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := doWithRetry(ctx, c.client, req, retryPolicyFor(method, c.config.retryPolicy, c.retryAll), c.config.retryBudget)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := readBody(resp, c.limit)
+	return resp.StatusCode, respBody, err
+}
+
+func requestSucceeded(method, url string, status int) error {
+	if status < 200 || status > 299 {
+		return fmt.Errorf("%s %s: HTTP request error. Response code: %d", method, url, status)
+	}
+	return nil
+}
+
+func resourceRequestCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	c, diags := newRequestClient(ctx, d, meta)
+	if diags.HasError() {
+		return diags
+	}
+	method, url := d.Get("create_method").(string), d.Get("url").(string)
+
+	status, body, err := c.do(ctx, method, url, d.Get("body").(string))
+	if err == nil {
+		err = requestSucceeded(method, url, status)
+	}
+	if err != nil {
+		return append(diags, diag.Errorf("Error creating object: %s", err)...)
+	}
+
+	var objectID string
+	if idField := d.Get("id_field").(string); idField != "" {
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return append(diags, diag.Errorf("Error decoding create response: %s", err)...)
+		}
+		if objectID, err = collectionKey(doc, idField); err != nil {
+			return append(diags, diag.Errorf("Error reading object ID from create response: %s", err)...)
+		}
+	}
+
+	objectURL := url
+	if v := d.Get("object_url").(string); v != "" {
+		objectURL = strings.Replace(v, "{id}", objectID, -1)
+	}
+	d.SetId(objectURL)
+	d.Set("object_id", objectID)
+
+	return append(diags, resourceRequestRead(ctx, d, meta)...)
+}
+
+func resourceRequestRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	c, diags := newRequestClient(ctx, d, meta)
+	if diags.HasError() {
+		return diags
+	}
+
+	status, body, err := c.do(ctx, http.MethodGet, d.Id(), "")
+	if err != nil {
+		return append(diags, diag.Errorf("Error reading object: %s", err)...)
+	}
+	if status == http.StatusNotFound || status == http.StatusGone {
+		d.SetId("")
+		return diags
+	}
+	if err := requestSucceeded(http.MethodGet, d.Id(), status); err != nil {
+		return append(diags, diag.Errorf("Error reading object: %s", err)...)
+	}
+
+	// The remote object is kept in body so that changes made outside of
+	// Terraform show up as drift; only JSON can be compared meaningfully.
+	if json.Valid(body) && json.Valid([]byte(d.Get("body").(string))) {
+		d.Set("body", string(body))
+	}
+	d.Set("response_body", string(body))
+	return diags
+}
+
+func resourceRequestUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	if !d.HasChanges("body", "update_body") {
+		return resourceRequestRead(ctx, d, meta)
+	}
+	c, diags := newRequestClient(ctx, d, meta)
+	if diags.HasError() {
+		return diags
+	}
+	method := d.Get("update_method").(string)
+
+	body := d.Get("update_body").(string)
+	if body == "" {
+		body = d.Get("body").(string)
+	}
+	status, _, err := c.do(ctx, method, d.Id(), body)
+	if err == nil {
+		err = requestSucceeded(method, d.Id(), status)
+	}
+	if err != nil {
+		return append(diags, diag.Errorf("Error updating object: %s", err)...)
+	}
+
+	return append(diags, resourceRequestRead(ctx, d, meta)...)
+}
+
+func resourceRequestDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	c, diags := newRequestClient(ctx, d, meta)
+	if diags.HasError() {
+		return diags
+	}
+	method := d.Get("destroy_method").(string)
+
+	status, _, err := c.do(ctx, method, d.Id(), d.Get("destroy_body").(string))
+	if err == nil && status != http.StatusNotFound && status != http.StatusGone {
+		err = requestSucceeded(method, d.Id(), status)
+	}
+	if err != nil {
+		return append(diags, diag.Errorf("Error destroying object: %s", err)...)
+	}
+
+	d.SetId("")
+	return diags
+}
@@ -0,0 +1,206 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestResource_request(t *testing.T) {
+	var mu sync.Mutex
+	var dashboard map[string]interface{}
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Method != http.MethodGet {
+			calls = append(calls, r.Method+" "+r.URL.Path+" "+string(body))
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/dashboards":
+			json.Unmarshal(body, &dashboard)
+			dashboard["uid"] = "abc"
+			json.NewEncoder(w).Encode(dashboard)
+		case dashboard == nil:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(dashboard)
+		case r.Method == http.MethodPut:
+			json.Unmarshal(body, &dashboard)
+			dashboard["uid"] = "abc"
+		case r.Method == http.MethodPost:
+			dashboard = nil
+		}
+	}))
+	defer server.Close()
+
+	sent := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		got := strings.Join(calls, ", ")
+		calls = nil
+		return got
+	}
+	expectSent := func(want string) resource.TestCheckFunc {
+		return func(*terraform.State) error {
+			if got := sent(); got != want {
+				return fmt.Errorf("sent %q; want %q", got, want)
+			}
+			return nil
+		}
+	}
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders,
+		CheckDestroy: expectSent(`POST /dashboards/abc {"archive": true}`),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testResourceConfig_request, server.URL, "api"),
+				Check: resource.ComposeTestCheckFunc(
+					expectSent(`POST /dashboards {"title":"api"}`),
+					resource.TestCheckResourceAttr("http_request.dashboard", "id", server.URL+"/dashboards/abc"),
+					resource.TestCheckResourceAttr("http_request.dashboard", "object_id", "abc"),
+				),
+			},
+			// The uid added by the server is not drift.
+			{
+				Config: fmt.Sprintf(testResourceConfig_request, server.URL, "api"),
+				Check:  expectSent(""),
+			},
+			{
+				Config: fmt.Sprintf(testResourceConfig_request, server.URL, "API"),
+				Check:  expectSent(`PUT /dashboards/abc {"title":"API"}`),
+			},
+		},
+	})
+}
+
+const testResourceConfig_request = `
+resource "http_request" "dashboard" {
+  url            = "%[1]s/dashboards"
+  object_url     = "%[1]s/dashboards/{id}"
+  id_field       = "uid"
+  body           = jsonencode({ title = %[2]q })
+  update_method  = "PUT"
+  destroy_method = "POST"
+  destroy_body   = jsonencode({ archive = true })
+}
+`
+
+func TestResource_requestDeletedRemotely(t *testing.T) {
+	var mu sync.Mutex
+	exists := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.Method == http.MethodPost:
+			exists = true
+			fmt.Fprint(w, `{"uid": "abc", "title": "api"}`)
+		case !exists:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodGet:
+			fmt.Fprint(w, `{"uid": "abc", "title": "api"}`)
+		case r.Method == http.MethodDelete:
+			exists = false
+		}
+	}))
+	defer server.Close()
+
+	config := fmt.Sprintf(`
+resource "http_request" "dashboard" {
+  url        = "%[1]s/dashboards"
+  object_url = "%[1]s/dashboards/{id}"
+  id_field   = "uid"
+  body       = jsonencode({ title = "api" })
+}
+`, server.URL)
+
+	// An object deleted remotely drops out of state and is planned again.
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+			},
+			{
+				PreConfig: func() {
+					mu.Lock()
+					exists = false
+					mu.Unlock()
+				},
+				Config:             config,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func TestRequestClient_do(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"uid": "abc", "title": "a dashboard with a long title"}`)
+	}))
+	defer server.Close()
+
+	config := &providerConfig{
+		tokens:      newTokenStore(nil),
+		retryPolicy: &retryPolicy{attempts: 3, minDelay: time.Millisecond, maxDelay: time.Millisecond},
+	}
+	cases := []struct {
+		name       string
+		method     string
+		attributes map[string]interface{}
+		wantStatus int
+		wantCalls  int
+		wantErr    bool
+	}{
+		{"POST not retried", http.MethodPost, nil, http.StatusServiceUnavailable, 1, false},
+		{"POST retried on request", http.MethodPost, map[string]interface{}{"retry_non_idempotent": true}, http.StatusOK, 2, false},
+		{"PUT retried", http.MethodPut, nil, http.StatusOK, 2, false},
+		{"body too large", http.MethodPut, map[string]interface{}{"max_response_body_size": 16}, 0, 2, true},
+	}
+
+	for _, tc := range cases {
+		calls = 0
+		attributes := map[string]interface{}{"url": server.URL}
+		for k, v := range tc.attributes {
+			attributes[k] = v
+		}
+		d := schema.TestResourceDataRaw(t, resourceRequest().Schema, attributes)
+		c, diags := newRequestClient(context.Background(), d, config)
+		if diags.HasError() {
+			t.Fatalf("%s: %v", tc.name, diags)
+		}
+		status, _, err := c.do(context.Background(), tc.method, server.URL, `{"title": "api"}`)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: err %v; want error %v", tc.name, err, tc.wantErr)
+		}
+		if !tc.wantErr && status != tc.wantStatus {
+			t.Errorf("%s: got %d; want %d", tc.name, status, tc.wantStatus)
+		}
+		if calls != tc.wantCalls {
+			t.Errorf("%s: sent %d requests; want %d", tc.name, calls, tc.wantCalls)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// retryPolicy describes how a single request is retried on transient
+// failures: by default connection errors, 429 and 5xx responses, or only
+// the status codes in onStatus and errors containing one of onErrors when
+// those are set.
+type retryPolicy struct {
+	attempts int
+	minDelay time.Duration
+	maxDelay time.Duration
+	onStatus []int
+	onErrors []string
+}
+
+// retrySchema is the retry block shared by the provider and data source.
+func retrySchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		MaxItems: 1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"attempts": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      3,
+					ValidateFunc: validation.IntAtLeast(1),
+				},
+				"min_delay_ms": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      500,
+					ValidateFunc: validateNonNegative,
+				},
+				"max_delay_ms": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      10000,
+					ValidateFunc: validateNonNegative,
+				},
+				"retry_on_status": {
+					Type:     schema.TypeList,
+					Optional: true,
+					Elem: &schema.Schema{
+						Type:         schema.TypeInt,
+						ValidateFunc: validation.IntBetween(100, 599),
+					},
+				},
+				"retry_on_errors": {
+					Type:     schema.TypeList,
+					Optional: true,
+					Elem: &schema.Schema{
+						Type: schema.TypeString,
+					},
+				},
+			},
+		},
+	}
+}
+
+// expandRetryPolicy reads a retry block as stored in the schema.
+func expandRetryPolicy(v interface{}) *retryPolicy {
+	l, ok := v.([]interface{})
+	if !ok || len(l) == 0 || l[0] == nil {
+		return nil
+	}
+	m := l[0].(map[string]interface{})
+	policy := &retryPolicy{
+		attempts: m["attempts"].(int),
+		minDelay: time.Duration(m["min_delay_ms"].(int)) * time.Millisecond,
+		maxDelay: time.Duration(m["max_delay_ms"].(int)) * time.Millisecond,
+	}
+	for _, code := range m["retry_on_status"].([]interface{}) {
+		policy.onStatus = append(policy.onStatus, code.(int))
+	}
+	for _, msg := range m["retry_on_errors"].([]interface{}) {
+		policy.onErrors = append(policy.onErrors, msg.(string))
+	}
+	return policy
+}
+
+// backoff returns the delay before the given retry (starting at 1): the
+// minimum delay doubled for every previous retry, capped at the maximum,
+// with the upper half randomised so that concurrent reads don't retry in
+// lockstep.
+func (p *retryPolicy) backoff(retry int) time.Duration {
+	delay := p.minDelay
+	for i := 1; i < retry && (p.maxDelay <= 0 || delay < p.maxDelay); i++ {
+		delay *= 2
+	}
+	if p.maxDelay > 0 && delay > p.maxDelay {
+		delay = p.maxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// retryAttemptKey is the context key under which doWithRetry stores the
+// number of the retry a request is, so transports can tell retries apart.
+type retryAttemptKey struct{}
+
+func isRetry(req *http.Request) bool {
+	n, _ := req.Context().Value(retryAttemptKey{}).(int)
+	return n > 0
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// retryable reports whether the outcome of an attempt is worth retrying
+// under the policy.
+func (p *retryPolicy) retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		if len(p.onErrors) == 0 {
+			return true
+		}
+		msg := strings.ToLower(err.Error())
+		for _, s := range p.onErrors {
+			if strings.Contains(msg, strings.ToLower(s)) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(p.onStatus) == 0 {
+		return retryableStatus(resp.StatusCode)
+	}
+	for _, code := range p.onStatus {
+		if code == resp.StatusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfter returns the delay a 429 or 503 response asks for in its
+// Retry-After header, either in seconds or as an HTTP date.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+	return parseRetryAfter(resp.Header.Get("Retry-After"), now)
+}
+
+// parseRetryAfter parses a Retry-After header value.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if at.Before(now) {
+			return 0, true
+		}
+		return at.Sub(now), true
+	}
+	return 0, false
+}
+
+// retryPolicyFor returns policy for the requests of the managed resources
+// with an idempotent method, and for the others only when retryAll is set: a
+// POST whose response was lost may have created the object already.
+func retryPolicyFor(method string, policy *retryPolicy, retryAll bool) *retryPolicy {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return policy
+	}
+	if retryAll {
+		return policy
+	}
+	return nil
+}
+
+// doWithRetry sends req, retrying transient failures according to policy
+// for as long as the provider-wide budget allows. A Retry-After header
+// replaces the backoff delay, still capped by the policy's maximum. A nil
+// policy sends the request once.
+func doWithRetry(ctx context.Context, client *http.Client, req *http.Request, policy *retryPolicy, budget *retryBudget) (*http.Response, error) {
+	for retry := 1; ; retry++ {
+		resp, err := client.Do(req)
+		if policy == nil || retry >= policy.attempts || !policy.retryable(resp, err) {
+			return resp, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			return resp, err
+		}
+
+		delay := policy.backoff(retry)
+		if after, ok := retryAfter(resp, time.Now()); ok {
+			delay = after
+			if policy.maxDelay > 0 && delay > policy.maxDelay {
+				delay = policy.maxDelay
+			}
+		}
+		if !budget.take(delay) {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+
+		req = req.Clone(context.WithValue(ctx, retryAttemptKey{}, retry))
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// retryBudget caps the retries spent by all requests sent through a single
+// provider instance. Without it a widely failing dependency multiplies plan
+// time by the per-request retry policy times the number of data sources.
+//
+// A zero limit means that dimension of the budget is unbounded. A nil
+// *retryBudget allows every retry.
+type retryBudget struct {
+	mu sync.Mutex
+
+	maxRetries int
+	maxDelay   time.Duration
+
+	retries int
+	delay   time.Duration
+}
+
+func newRetryBudget(maxRetries int, maxDelay time.Duration) *retryBudget {
+	return &retryBudget{
+		maxRetries: maxRetries,
+		maxDelay:   maxDelay,
+	}
+}
+
+// take reserves a single retry that is going to wait for delay before being
+// sent. It reports false, reserving nothing, once the budget is exhausted.
+func (b *retryBudget) take(delay time.Duration) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.maxRetries > 0 && b.retries+1 > b.maxRetries {
+		return false
+	}
+	if b.maxDelay > 0 && b.delay+delay > b.maxDelay {
+		return false
+	}
+
+	b.retries++
+	b.delay += delay
+	return true
+}
+
+func validateNonNegative(val interface{}, key string) (warns []string, errs []error) {
+	if v, ok := val.(int); ok {
+		if v < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got: %d", key, v))
+		}
+	} else {
+		errs = append(errs, fmt.Errorf("error parsing %s", key))
+	}
+	return
+}
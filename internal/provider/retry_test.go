@@ -0,0 +1,185 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryBudget_maxRetries(t *testing.T) {
+	budget := newRetryBudget(2, 0)
+
+	for i := 0; i < 2; i++ {
+		if !budget.take(time.Second) {
+			t.Fatalf("retry %d was refused; want it allowed", i+1)
+		}
+	}
+	if budget.take(time.Second) {
+		t.Fatalf("retry 3 was allowed; want the budget to be exhausted")
+	}
+}
+
+func TestRetryBudget_maxDelay(t *testing.T) {
+	budget := newRetryBudget(0, 3*time.Second)
+
+	if !budget.take(2 * time.Second) {
+		t.Fatalf("first retry was refused; want it allowed")
+	}
+	if budget.take(2 * time.Second) {
+		t.Fatalf("second retry was allowed; want it refused for exceeding max delay")
+	}
+	if !budget.take(time.Second) {
+		t.Fatalf("third retry was refused; want it allowed within the remaining delay")
+	}
+}
+
+func TestRetryBudget_nil(t *testing.T) {
+	var budget *retryBudget
+
+	if !budget.take(time.Hour) {
+		t.Fatalf("nil budget refused a retry; want unlimited")
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	policy := &retryPolicy{attempts: 5, minDelay: 100 * time.Millisecond, maxDelay: 300 * time.Millisecond}
+
+	for retry, max := range map[int]time.Duration{1: 100, 2: 200, 3: 300, 4: 300} {
+		max *= time.Millisecond
+		if d := policy.backoff(retry); d < max/2 || d > max {
+			t.Errorf("retry %d: backoff %s; want between %s and %s", retry, d, max/2, max)
+		}
+	}
+}
+
+func TestDoWithRetry(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != "payload" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}))
+	defer server.Close()
+
+	send := func(policy *retryPolicy, budget *retryBudget) int {
+		calls = 0
+		req, _ := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader([]byte("payload")))
+		resp, err := doWithRetry(context.Background(), server.Client(), req, policy, budget)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	policy := &retryPolicy{attempts: 3, minDelay: time.Millisecond, maxDelay: time.Millisecond}
+	if code := send(policy, nil); code != http.StatusOK || calls != 3 {
+		t.Fatalf("got %d after %d calls; want 200 after 3", code, calls)
+	}
+	if code := send(policy, newRetryBudget(1, 0)); code != http.StatusServiceUnavailable || calls != 2 {
+		t.Fatalf("got %d after %d calls; want 503 after 2 once the budget is spent", code, calls)
+	}
+	if code := send(nil, nil); code != http.StatusServiceUnavailable || calls != 1 {
+		t.Fatalf("got %d after %d calls; want 503 after 1 without a policy", code, calls)
+	}
+
+	policy = &retryPolicy{attempts: 3, minDelay: time.Millisecond, maxDelay: time.Millisecond, onStatus: []int{http.StatusTooManyRequests}}
+	if code := send(policy, nil); code != http.StatusServiceUnavailable || calls != 1 {
+		t.Fatalf("got %d after %d calls; want 503 after 1 when only 429 is retried", code, calls)
+	}
+}
+
+func TestRetryPolicy_retryable(t *testing.T) {
+	refused := errors.New("dial tcp 127.0.0.1:1: connect: connection refused")
+	cases := []struct {
+		name   string
+		policy retryPolicy
+		resp   *http.Response
+		err    error
+		want   bool
+	}{
+		{"default 503", retryPolicy{}, &http.Response{StatusCode: 503}, nil, true},
+		{"default 404", retryPolicy{}, &http.Response{StatusCode: 404}, nil, false},
+		{"default error", retryPolicy{}, nil, refused, true},
+		{"listed status", retryPolicy{onStatus: []int{409}}, &http.Response{StatusCode: 409}, nil, true},
+		{"unlisted status", retryPolicy{onStatus: []int{409}}, &http.Response{StatusCode: 503}, nil, false},
+		{"listed error", retryPolicy{onErrors: []string{"Connection Refused"}}, nil, refused, true},
+		{"unlisted error", retryPolicy{onErrors: []string{"timeout"}}, nil, refused, false},
+		{"errors only", retryPolicy{onErrors: []string{"timeout"}}, &http.Response{StatusCode: 503}, nil, true},
+	}
+
+	for _, tc := range cases {
+		if got := tc.policy.retryable(tc.resp, tc.err); got != tc.want {
+			t.Errorf("%s: got %v; want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		status int
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"seconds", http.StatusTooManyRequests, "30", 30 * time.Second, true},
+		{"date", http.StatusServiceUnavailable, "Fri, 01 Mar 2024 12:02:00 GMT", 2 * time.Minute, true},
+		{"past date", http.StatusServiceUnavailable, "Fri, 01 Mar 2024 11:00:00 GMT", 0, true},
+		{"other status", http.StatusBadGateway, "30", 0, false},
+		{"missing", http.StatusTooManyRequests, "", 0, false},
+		{"invalid", http.StatusTooManyRequests, "soon", 0, false},
+	}
+
+	for _, tc := range cases {
+		resp := &http.Response{StatusCode: tc.status, Header: http.Header{}}
+		if tc.header != "" {
+			resp.Header.Set("Retry-After", tc.header)
+		}
+		got, ok := retryAfter(resp, now)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%s: got %s, %v; want %s, %v", tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestDoWithRetry_retryAfter(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	// The one second asked for is capped by max_delay_ms.
+	policy := &retryPolicy{attempts: 2, minDelay: time.Hour, maxDelay: 20 * time.Millisecond}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	start := time.Now()
+	resp, err := doWithRetry(context.Background(), server.Client(), req, policy, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls != 2 {
+		t.Fatalf("got %d after %d calls; want 200 after 2", resp.StatusCode, calls)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Fatalf("retried after %s; want the capped Retry-After delay", elapsed)
+	}
+}
@@ -0,0 +1,240 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// dataSourceSequence sends an ordered list of requests sharing a cookie jar,
+// for appliances that require a login request and its session cookie or
+// token before the actual API call. Values extracted from a step can be
+// used in the URL, headers and body of the following ones as
+// "{<step>.<name>}".
+func dataSourceSequence() *schema.Resource {
+	return &schema.Resource{
+		Description: "Sends an ordered sequence of HTTP requests sharing cookies.",
+
+		ReadContext: dataSourceSequenceRead,
+
+		Schema: map[string]*schema.Schema{
+			"step": {
+				Type:     schema.TypeList,
+				Required: true,
+				MinItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"url": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"method": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      http.MethodGet,
+							ValidateFunc: validateVerb,
+						},
+						"request_headers": {
+							Type:     schema.TypeMap,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"request_body": {
+							Type:      schema.TypeString,
+							Optional:  true,
+							Sensitive: true,
+						},
+						"json_extract": {
+							Type:     schema.TypeMap,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"regex_extract": {
+							Type:         schema.TypeMap,
+							Optional:     true,
+							ValidateFunc: validateRegexMap,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"header_extract": {
+							Type:     schema.TypeMap,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+
+			"request_headers": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"max_response_body_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      defaultMaxResponseBodySize,
+				ValidateFunc: validateNonNegative,
+			},
+
+			"ca": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"body": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"status_code": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+
+			"outputs": {
+				Type:      schema.TypeMap,
+				Computed:  true,
+				Sensitive: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+func dataSourceSequenceRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := configFromMeta(meta)
+	tlsConfig, diags := newTLSConfig(ctx, d, config)
+	if diags.HasError() {
+		return diags
+	}
+	tr, err := config.baseTransport(tlsConfig)
+	if err != nil {
+		return append(diags, diag.Errorf("Error configuring proxy: %s", err)...)
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return append(diags, diag.Errorf("Error creating cookie jar: %s", err)...)
+	}
+	client := &http.Client{
+		Transport: config.wrapTransport(&decompressTransport{next: tr, limit: defaultMaxDecompressedBytes}),
+		Timeout:   config.requestTimeout,
+		Jar:       jar,
+	}
+
+	headers := d.Get("request_headers").(map[string]interface{})
+	limit := int64(d.Get("max_response_body_size").(int))
+	ctx = withRecordingPolicy(ctx, recordingPolicy{limit: limit})
+	outputs := map[string]string{}
+	seen := map[string]bool{}
+	id := sha256.New()
+	var status int
+	var body []byte
+	for _, v := range d.Get("step").([]interface{}) {
+		step := v.(map[string]interface{})
+		name := step["name"].(string)
+		if seen[name] {
+			return append(diags, diag.Errorf("Error in step: duplicate name %q", name)...)
+		}
+		seen[name] = true
+
+		var header http.Header
+		status, header, body, err = sequenceDo(ctx, client, config, headers, step, outputs, limit)
+		if err != nil {
+			return append(diags, diag.Errorf("Error making request %q: %s", name, err)...)
+		}
+		if status < 200 || status > 299 {
+			return append(diags, diag.Errorf("Error making request %q: HTTP request error. Response code: %d", name, status)...)
+		}
+		fmt.Fprintf(id, "%s %s %s\n", name, step["method"], step["url"])
+
+		extracted := map[string]string{}
+		if v := step["json_extract"].(map[string]interface{}); len(v) > 0 {
+			if extracted, err = jsonExtract(body, v); err != nil {
+				return append(diags, diag.Errorf("Error extracting JSON values from %q: %s", name, err)...)
+			}
+		}
+		if err := regexExtract(body, step["regex_extract"].(map[string]interface{}), extracted); err != nil {
+			return append(diags, diag.Errorf("Error extracting values from %q: %s", name, err)...)
+		}
+		for output, headerName := range step["header_extract"].(map[string]interface{}) {
+			if _, ok := extracted[output]; ok {
+				return append(diags, diag.Errorf("Error extracting headers from %q: %s is also set by json_extract or regex_extract", name, output)...)
+			}
+			value := header.Get(headerName.(string))
+			if value == "" {
+				return append(diags, diag.Errorf("Error extracting headers from %q: %s: no %s header", name, output, headerName)...)
+			}
+			extracted[output] = value
+		}
+		for output, value := range extracted {
+			outputs[name+"."+output] = value
+		}
+	}
+
+	d.Set("body", string(body))
+	d.Set("status_code", status)
+	d.Set("outputs", outputs)
+	d.SetId(hex.EncodeToString(id.Sum(nil)))
+	return diags
+}
+
+// sequenceDo sends the request of step, with the "{<step>.<name>}"
+// references to earlier outputs substituted in its URL, headers and body.
+func sequenceDo(ctx context.Context, client *http.Client, config *providerConfig, headers map[string]interface{}, step map[string]interface{}, outputs map[string]string, limit int64) (int, http.Header, []byte, error) {
+	pairs := make([]string, 0, 2*len(outputs))
+	for name, value := range outputs {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	var body io.Reader
+	if v := step["request_body"].(string); v != "" {
+		body = strings.NewReader(replacer.Replace(v))
+	}
+	url := config.resolveURL(replacer.Replace(step["url"].(string)))
+	req, err := http.NewRequestWithContext(ctx, step["method"].(string), url, body)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	for _, headers := range []map[string]interface{}{config.defaultHeaders, headers, step["request_headers"].(map[string]interface{})} {
+		for name, value := range headers {
+			req.Header.Set(name, replacer.Replace(value.(string)))
+		}
+	}
+
+	resp, err := doWithRetry(ctx, client, req, config.retryPolicy, config.retryBudget)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := readBody(resp, limit)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return resp.StatusCode, resp.Header, data, nil
+}
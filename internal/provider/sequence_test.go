@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceSequenceRead(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t"})
+			w.Header().Set("X-Csrf-Token", "csrf-1")
+			fmt.Fprint(w, `{"user": {"id": 42}}`)
+		case "/users/42":
+			cookie, err := r.Cookie("session")
+			if err != nil || cookie.Value != "s3cr3t" || r.Header.Get("X-Csrf-Token") != "csrf-1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"name": "admin"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	d := schema.TestResourceDataRaw(t, dataSourceSequence().Schema, map[string]interface{}{
+		"step": []interface{}{
+			map[string]interface{}{
+				"name":           "login",
+				"url":            server.URL + "/login",
+				"method":         "POST",
+				"json_extract":   map[string]interface{}{"user_id": "$.user.id"},
+				"header_extract": map[string]interface{}{"csrf": "X-CSRF-Token"},
+			},
+			map[string]interface{}{
+				"name":            "user",
+				"url":             server.URL + "/users/{login.user_id}",
+				"request_headers": map[string]interface{}{"X-CSRF-Token": "{login.csrf}"},
+			},
+		},
+	})
+	if diags := dataSourceSequenceRead(context.Background(), d, nil); diags.HasError() {
+		t.Fatalf("err: %v", diags)
+	}
+	if got := d.Get("body").(string); got != `{"name": "admin"}` {
+		t.Errorf("got body %q", got)
+	}
+	outputs := d.Get("outputs").(map[string]interface{})
+	if outputs["login.user_id"] != "42" || outputs["login.csrf"] != "csrf-1" {
+		t.Errorf("got outputs %v", outputs)
+	}
+
+	// A failing step stops the sequence.
+	d = schema.TestResourceDataRaw(t, dataSourceSequence().Schema, map[string]interface{}{
+		"step": []interface{}{
+			map[string]interface{}{"name": "user", "url": server.URL + "/users/42"},
+		},
+	})
+	if diags := dataSourceSequenceRead(context.Background(), d, nil); !diags.HasError() {
+		t.Errorf("a request without the session cookie did not fail")
+	}
+}
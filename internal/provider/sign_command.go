@@ -0,0 +1,65 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// signCommandRequest is the canonical request written to the standard input
+// of a sign_command.
+type signCommandRequest struct {
+	Method  string              `json:"method"`
+	URL     string              `json:"url"`
+	Headers map[string][]string `json:"headers"`
+	Body    string              `json:"body"`
+}
+
+// runSignCommand pipes req to an external program and merges the headers it
+// prints, as a JSON object of header names to values, into req. This lets
+// vendor-specific signature schemes be supported without a native
+// implementation.
+func runSignCommand(ctx context.Context, command []string, req *http.Request, body []byte) error {
+	if len(command) == 0 {
+		return fmt.Errorf("sign_command is empty")
+	}
+	input, err := json.Marshal(signCommandRequest{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: req.Header,
+		Body:    string(body),
+	})
+	if err != nil {
+		return err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running %s: %s: %s", command[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	var headers map[string]string
+	if err := json.Unmarshal(stdout.Bytes(), &headers); err != nil {
+		return fmt.Errorf("decoding output of %s: %s", command[0], err)
+	}
+	for name, value := range headers {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return fmt.Errorf("%s returned invalid header name %q", command[0], name)
+		}
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return fmt.Errorf("%s returned invalid value for header %q", command[0], name)
+		}
+		req.Header.Set(name, value)
+	}
+	return nil
+}
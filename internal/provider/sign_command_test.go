@@ -0,0 +1,26 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestRunSignCommand(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/a", bytes.NewReader([]byte("x")))
+	req.Header.Set("Content-Type", "text/plain")
+
+	// The script checks the canonical request and signs it with a constant.
+	script := `grep -q '"method":"POST".*"url":"https://example.com/a".*"body":"x"' && echo '{"X-Signature": "sig"}'`
+	if err := runSignCommand(context.Background(), []string{"sh", "-c", script}, req, []byte("x")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := req.Header.Get("X-Signature"); got != "sig" {
+		t.Fatalf("X-Signature is %q; want sig", got)
+	}
+
+	if err := runSignCommand(context.Background(), []string{"sh", "-c", "echo nope >&2; exit 1"}, req, nil); err == nil {
+		t.Fatalf("expected an error from a failing command")
+	}
+}
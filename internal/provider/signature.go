@@ -0,0 +1,302 @@
+package provider
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	signatureSchemeRFC9421 = "rfc9421"
+	signatureSchemeCavage  = "cavage"
+)
+
+// httpSigner signs requests following RFC 9421 (HTTP Message Signatures) or
+// the earlier draft-cavage-http-signatures scheme still used by OCI and
+// Mastodon-compatible services.
+type httpSigner struct {
+	scheme     string
+	keyID      string
+	algorithm  string
+	label      string
+	components []string
+	authHeader bool
+	key        interface{}
+	now        func() time.Time
+}
+
+func newHTTPSigner(config map[string]interface{}) (*httpSigner, error) {
+	s := &httpSigner{
+		scheme:     config["scheme"].(string),
+		keyID:      config["key_id"].(string),
+		algorithm:  config["algorithm"].(string),
+		label:      config["label"].(string),
+		authHeader: config["use_authorization_header"].(bool),
+		now:        time.Now,
+	}
+	for _, c := range config["components"].([]interface{}) {
+		s.components = append(s.components, strings.ToLower(c.(string)))
+	}
+
+	key, err := parseSigningKey(s.algorithm, []byte(config["key"].(string)))
+	if err != nil {
+		return nil, err
+	}
+	s.key = key
+
+	return s, nil
+}
+
+func parseSigningKey(algorithm string, data []byte) (interface{}, error) {
+	if strings.HasPrefix(algorithm, "hmac-") {
+		return data, nil
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported signing key type %q", block.Type)
+}
+
+// sign adds the signature headers to req. Covered headers that are commonly
+// derived (Date, Digest, Content-Digest, Content-Length) are added when
+// missing so that they can be signed.
+func (s *httpSigner) sign(req *http.Request, body []byte) error {
+	components := s.components
+	if len(components) == 0 {
+		components = s.defaultComponents(body)
+	}
+
+	now := s.now()
+	for _, c := range components {
+		switch c {
+		case "date":
+			if req.Header.Get("Date") == "" {
+				req.Header.Set("Date", now.UTC().Format(http.TimeFormat))
+			}
+		case digestDigest, digestContentDigest:
+			if err := setBodyDigests(req, body, []string{c}, "sha-256"); err != nil {
+				return err
+			}
+		case "content-length":
+			if req.Header.Get("Content-Length") == "" {
+				req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+			}
+		}
+	}
+
+	if s.scheme == signatureSchemeCavage {
+		return s.signCavage(req, components)
+	}
+	return s.signRFC9421(req, components, now)
+}
+
+func (s *httpSigner) defaultComponents(body []byte) []string {
+	if s.scheme == signatureSchemeCavage {
+		if body != nil {
+			return []string{"(request-target)", "host", "date", "digest"}
+		}
+		return []string{"(request-target)", "host", "date"}
+	}
+	if body != nil {
+		return []string{"@method", "@target-uri", "content-digest"}
+	}
+	return []string{"@method", "@target-uri"}
+}
+
+func (s *httpSigner) signRFC9421(req *http.Request, components []string, now time.Time) error {
+	quoted := make([]string, len(components))
+	for i, c := range components {
+		quoted[i] = strconv.Quote(c)
+	}
+	params := fmt.Sprintf("(%s);created=%d;keyid=%s;alg=%s",
+		strings.Join(quoted, " "), now.Unix(), strconv.Quote(s.keyID), strconv.Quote(s.algorithm))
+
+	var base strings.Builder
+	for _, c := range components {
+		value, err := rfc9421ComponentValue(req, c)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&base, "%q: %s\n", c, value)
+	}
+	fmt.Fprintf(&base, "%q: %s", "@signature-params", params)
+
+	sig, err := s.signBytes([]byte(base.String()), true)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Signature-Input", s.label+"="+params)
+	req.Header.Set("Signature", s.label+"=:"+base64.StdEncoding.EncodeToString(sig)+":")
+	return nil
+}
+
+func rfc9421ComponentValue(req *http.Request, component string) (string, error) {
+	switch component {
+	case "@method":
+		return req.Method, nil
+	case "@target-uri":
+		return req.URL.String(), nil
+	case "@authority":
+		return strings.ToLower(requestHost(req)), nil
+	case "@scheme":
+		return strings.ToLower(req.URL.Scheme), nil
+	case "@request-target":
+		return req.URL.RequestURI(), nil
+	case "@path":
+		return req.URL.EscapedPath(), nil
+	case "@query":
+		return "?" + req.URL.RawQuery, nil
+	}
+	if strings.HasPrefix(component, "@") {
+		return "", fmt.Errorf("unsupported signature component %q", component)
+	}
+	return signedHeaderValue(req, component)
+}
+
+func (s *httpSigner) signCavage(req *http.Request, components []string) error {
+	lines := make([]string, len(components))
+	for i, c := range components {
+		var value string
+		switch c {
+		case "(request-target)":
+			value = strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+		default:
+			v, err := signedHeaderValue(req, c)
+			if err != nil {
+				return err
+			}
+			value = v
+		}
+		lines[i] = c + ": " + value
+	}
+
+	sig, err := s.signBytes([]byte(strings.Join(lines, "\n")), false)
+	if err != nil {
+		return err
+	}
+
+	value := fmt.Sprintf(`keyId=%q,algorithm=%q,headers=%q,signature=%q`,
+		s.keyID, s.algorithm, strings.Join(components, " "), base64.StdEncoding.EncodeToString(sig))
+	if s.authHeader {
+		req.Header.Set("Authorization", `Signature version="1",`+value)
+	} else {
+		req.Header.Set("Signature", value)
+	}
+	return nil
+}
+
+func signedHeaderValue(req *http.Request, name string) (string, error) {
+	if name == "host" {
+		return requestHost(req), nil
+	}
+	values, ok := req.Header[http.CanonicalHeaderKey(name)]
+	if !ok {
+		return "", fmt.Errorf("signature component %q is not present in the request", name)
+	}
+	trimmed := make([]string, len(values))
+	for i, v := range values {
+		trimmed[i] = strings.TrimSpace(v)
+	}
+	return strings.Join(trimmed, ", "), nil
+}
+
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+// signBytes produces the raw signature over data. RFC 9421 encodes ECDSA
+// signatures as the fixed size concatenation of r and s while the cavage
+// draft uses the ASN.1 DER encoding.
+func (s *httpSigner) signBytes(data []byte, rawECDSA bool) ([]byte, error) {
+	switch s.algorithm {
+	case "hmac-sha256":
+		mac := hmac.New(sha256.New, s.key.([]byte))
+		mac.Write(data)
+		return mac.Sum(nil), nil
+	case "rsa-pss-sha512":
+		key, ok := s.key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("algorithm %s requires an RSA key", s.algorithm)
+		}
+		digest := sha512.Sum512(data)
+		return rsa.SignPSS(rand.Reader, key, crypto.SHA512, digest[:], &rsa.PSSOptions{SaltLength: 64})
+	case "rsa-v1_5-sha256", "rsa-sha256":
+		key, ok := s.key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("algorithm %s requires an RSA key", s.algorithm)
+		}
+		digest := sha256.Sum256(data)
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case "ecdsa-p256-sha256", "ecdsa-sha256":
+		digest := sha256.Sum256(data)
+		return signECDSA(s.key, digest[:], 32, rawECDSA)
+	case "ecdsa-p384-sha384":
+		digest := sha512.Sum384(data)
+		return signECDSA(s.key, digest[:], 48, rawECDSA)
+	case "ed25519":
+		key, ok := s.key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("algorithm %s requires an Ed25519 key", s.algorithm)
+		}
+		return ed25519.Sign(key, data), nil
+	}
+	return nil, fmt.Errorf("unsupported signature algorithm %q", s.algorithm)
+}
+
+func signECDSA(key interface{}, hash []byte, size int, raw bool) ([]byte, error) {
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("ECDSA algorithms require an EC key")
+	}
+
+	if !raw {
+		return ecdsa.SignASN1(rand.Reader, ecKey, hash)
+	}
+
+	r, sVal, err := ecdsa.Sign(rand.Reader, ecKey, hash)
+	if err != nil {
+		return nil, err
+	}
+	sig := make([]byte, 2*size)
+	r.FillBytes(sig[:size])
+	sVal.FillBytes(sig[size:])
+	return sig, nil
+}
+
+func validateSignatureAlgorithm(val interface{}, key string) (warns []string, errs []error) {
+	switch val.(string) {
+	case "rsa-pss-sha512", "rsa-v1_5-sha256", "rsa-sha256", "ecdsa-p256-sha256",
+		"ecdsa-sha256", "ecdsa-p384-sha384", "ed25519", "hmac-sha256":
+	default:
+		errs = append(errs, fmt.Errorf("%s is not a supported signature algorithm, got: %s", key, val))
+	}
+	return
+}
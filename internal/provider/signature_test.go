@@ -0,0 +1,111 @@
+package provider
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSigner(t *testing.T, scheme, algorithm string, key interface{}) *httpSigner {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	signer, err := newHTTPSigner(map[string]interface{}{
+		"scheme":                   scheme,
+		"key_id":                   "test-key",
+		"algorithm":                algorithm,
+		"label":                    "sig1",
+		"use_authorization_header": false,
+		"components":               []interface{}{},
+		"key":                      string(pemKey),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	signer.now = func() time.Time { return time.Unix(1618884473, 0) }
+	return signer
+}
+
+func TestHTTPSigner_rfc9421(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	signer := testSigner(t, signatureSchemeRFC9421, "rsa-pss-sha512", key)
+
+	body := []byte(`{"hello": "world"}`)
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/foo?param=Value", nil)
+	if err := signer.sign(req, body); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	wantInput := `sig1=("@method" "@target-uri" "content-digest");created=1618884473;keyid="test-key";alg="rsa-pss-sha512"`
+	if got := req.Header.Get("Signature-Input"); got != wantInput {
+		t.Fatalf("Signature-Input is %s; want %s", got, wantInput)
+	}
+	if got := req.Header.Get("Content-Digest"); got != "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:" {
+		t.Fatalf("Content-Digest is %s", got)
+	}
+
+	base := strings.Join([]string{
+		`"@method": POST`,
+		`"@target-uri": https://example.com/foo?param=Value`,
+		`"content-digest": sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:`,
+		`"@signature-params": ("@method" "@target-uri" "content-digest");created=1618884473;keyid="test-key";alg="rsa-pss-sha512"`,
+	}, "\n")
+
+	sig := strings.TrimSuffix(strings.TrimPrefix(req.Header.Get("Signature"), "sig1=:"), ":")
+	raw, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	digest := sha512.Sum512([]byte(base))
+	if err := rsa.VerifyPSS(&key.PublicKey, crypto.SHA512, digest[:], raw, &rsa.PSSOptions{SaltLength: 64}); err != nil {
+		t.Fatalf("signature does not verify: %s", err)
+	}
+}
+
+func TestHTTPSigner_cavage(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	signer := testSigner(t, signatureSchemeCavage, "ed25519", key)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/inbox?page=2", nil)
+	if err := signer.sign(req, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	date := req.Header.Get("Date")
+	if date != "Tue, 20 Apr 2021 02:07:53 GMT" {
+		t.Fatalf("Date is %s", date)
+	}
+
+	header := req.Header.Get("Signature")
+	if !strings.HasPrefix(header, `keyId="test-key",algorithm="ed25519",headers="(request-target) host date",signature="`) {
+		t.Fatalf("unexpected Signature header %s", header)
+	}
+
+	sig := strings.TrimSuffix(header[strings.Index(header, `signature="`)+len(`signature="`):], `"`)
+	raw, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	signingString := "(request-target): get /inbox?page=2\nhost: example.com\ndate: " + date
+	if !ed25519.Verify(pub, []byte(signingString), raw) {
+		t.Fatalf("signature does not verify")
+	}
+}
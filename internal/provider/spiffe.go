@@ -0,0 +1,226 @@
+package provider
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The SPIFFE Workload API is a small gRPC service exposed by the SPIRE agent
+// (or any compatible implementation) on a local socket. Only FetchX509SVID is
+// needed here, so the messages are decoded by hand rather than pulling in the
+// generated client.
+const spiffeFetchX509SVIDMethod = "/SpiffeWorkloadAPI/FetchX509SVID"
+
+// x509SVID is a workload X.509 identity as returned by the Workload API.
+type x509SVID struct {
+	spiffeID     string
+	certificates []*x509.Certificate
+	privateKey   crypto.Signer
+	bundle       []*x509.Certificate
+}
+
+// fetchX509SVID returns the X.509 SVID whose SPIFFE ID is spiffeID, or the
+// default (first) SVID when spiffeID is empty. Fetching on every read means
+// rotated certificates are always picked up.
+func fetchX509SVID(ctx context.Context, socketPath, spiffeID string) (*x509SVID, error) {
+	socketPath = strings.TrimPrefix(socketPath, "unix://")
+
+	conn, err := grpc.DialContext(ctx, "passthrough:///spiffe-workload-api",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to workload API at %s: %s", socketPath, err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(ctx, "workload.spiffe.io", "true"))
+	defer cancel()
+
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, spiffeFetchX509SVIDMethod, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		return nil, fmt.Errorf("fetching X.509 SVID: %s", err)
+	}
+	if err := stream.SendMsg(&[]byte{}); err != nil {
+		return nil, fmt.Errorf("fetching X.509 SVID: %s", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, fmt.Errorf("fetching X.509 SVID: %s", err)
+	}
+
+	var resp []byte
+	if err := stream.RecvMsg(&resp); err != nil {
+		return nil, fmt.Errorf("fetching X.509 SVID: %s", err)
+	}
+
+	svids, err := parseX509SVIDResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(svids) == 0 {
+		return nil, fmt.Errorf("workload API returned no X.509 SVIDs")
+	}
+	if spiffeID == "" {
+		return svids[0], nil
+	}
+	for _, svid := range svids {
+		if svid.spiffeID == spiffeID {
+			return svid, nil
+		}
+	}
+	return nil, fmt.Errorf("workload API returned no X.509 SVID for %s", spiffeID)
+}
+
+// parseX509SVIDResponse decodes an X509SVIDResponse message:
+//
+//	message X509SVIDResponse { repeated X509SVID svids = 1; ... }
+//	message X509SVID {
+//	  string spiffe_id = 1; bytes x509_svid = 2; bytes x509_svid_key = 3; bytes bundle = 4;
+//	}
+func parseX509SVIDResponse(b []byte) ([]*x509SVID, error) {
+	var svids []*x509SVID
+	err := walkProtoBytes(b, func(num protowire.Number, v []byte) error {
+		if num != 1 {
+			return nil
+		}
+		svid := &x509SVID{}
+		err := walkProtoBytes(v, func(num protowire.Number, v []byte) error {
+			var err error
+			switch num {
+			case 1:
+				svid.spiffeID = string(v)
+			case 2:
+				svid.certificates, err = x509.ParseCertificates(v)
+			case 3:
+				var key interface{}
+				if key, err = x509.ParsePKCS8PrivateKey(v); err == nil {
+					var ok bool
+					if svid.privateKey, ok = key.(crypto.Signer); !ok {
+						err = fmt.Errorf("unsupported SVID key type %T", key)
+					}
+				}
+			case 4:
+				svid.bundle, err = x509.ParseCertificates(v)
+			}
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("decoding X.509 SVID: %s", err)
+		}
+		if len(svid.certificates) == 0 || svid.privateKey == nil {
+			return fmt.Errorf("decoding X.509 SVID: missing certificate or key")
+		}
+		svids = append(svids, svid)
+		return nil
+	})
+	return svids, err
+}
+
+// walkProtoBytes calls fn for every length-delimited field in b, skipping
+// fields of any other wire type.
+func walkProtoBytes(b []byte, fn func(protowire.Number, []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := fn(num, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applySPIFFE presents svid as the client certificate. When peerID is set it
+// returns a verifier requiring the server to present an SVID for exactly
+// that SPIFFE ID chained to the trust bundle; otherwise the bundle is only
+// used as the root CA pool.
+func applySPIFFE(tlsConfig *tls.Config, svid *x509SVID, peerID string) chainVerifier {
+	chain := make([][]byte, len(svid.certificates))
+	for i, c := range svid.certificates {
+		chain[i] = c.Raw
+	}
+	tlsConfig.Certificates = []tls.Certificate{{
+		Certificate: chain,
+		PrivateKey:  svid.privateKey,
+		Leaf:        svid.certificates[0],
+	}}
+
+	roots := x509.NewCertPool()
+	for _, c := range svid.bundle {
+		roots.AddCert(c)
+	}
+
+	if peerID == "" {
+		if tlsConfig.RootCAs == nil {
+			tlsConfig.RootCAs = roots
+		}
+		return nil
+	}
+
+	// SVIDs carry no DNS names, so hostname verification is replaced by
+	// checking the SPIFFE ID in the URI SAN.
+	return func(certs []*x509.Certificate) ([][]*x509.Certificate, error) {
+		intermediates := x509.NewCertPool()
+		for _, c := range certs[1:] {
+			intermediates.AddCert(c)
+		}
+		chains, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("verifying server SVID: %s", err)
+		}
+		for _, uri := range certs[0].URIs {
+			if uri.String() == peerID {
+				return chains, nil
+			}
+		}
+		return nil, fmt.Errorf("server SVID does not match the expected SPIFFE ID %s", peerID)
+	}
+}
+
+// rawCodec passes pre-encoded protobuf messages straight through gRPC.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	return *(v.(*[]byte)), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*(v.(*[]byte)) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func testSPIFFECertificate(t *testing.T, id string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{Organization: []string{"SPIFFE"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	} else {
+		u, _ := url.Parse(id)
+		template.URIs = []*url.URL{u}
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return cert, key
+}
+
+// testServerCodec adapts rawCodec to the older codec interface servers take.
+type testServerCodec struct {
+	rawCodec
+}
+
+func (testServerCodec) String() string {
+	return "proto"
+}
+
+func TestFetchX509SVID(t *testing.T) {
+	ca, caKey := testSPIFFECertificate(t, "", nil, nil)
+	leaf, leafKey := testSPIFFECertificate(t, "spiffe://example.org/client", ca, caKey)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(leafKey)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var svid []byte
+	svid = protowire.AppendTag(svid, 1, protowire.BytesType)
+	svid = protowire.AppendString(svid, "spiffe://example.org/client")
+	svid = protowire.AppendTag(svid, 2, protowire.BytesType)
+	svid = protowire.AppendBytes(svid, leaf.Raw)
+	svid = protowire.AppendTag(svid, 3, protowire.BytesType)
+	svid = protowire.AppendBytes(svid, keyDER)
+	svid = protowire.AppendTag(svid, 4, protowire.BytesType)
+	svid = protowire.AppendBytes(svid, ca.Raw)
+	var resp []byte
+	resp = protowire.AppendTag(resp, 1, protowire.BytesType)
+	resp = protowire.AppendBytes(resp, svid)
+
+	dir, err := ioutil.TempDir("", "spiffe")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "agent.sock")
+
+	lis, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	server := grpc.NewServer(grpc.CustomCodec(testServerCodec{}), grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		if len(md.Get("workload.spiffe.io")) == 0 {
+			t.Errorf("request is missing the workload.spiffe.io header")
+		}
+		var req []byte
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		return stream.SendMsg(&resp)
+	}))
+	go server.Serve(lis)
+	defer server.Stop()
+
+	got, err := fetchX509SVID(context.Background(), "unix://"+socketPath, "")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got.spiffeID != "spiffe://example.org/client" {
+		t.Fatalf("SPIFFE ID is %s", got.spiffeID)
+	}
+
+	if _, err := fetchX509SVID(context.Background(), socketPath, "spiffe://example.org/other"); err == nil {
+		t.Fatalf("expected an error for an unknown SPIFFE ID")
+	}
+
+	tlsConfig := &tls.Config{}
+	verify := applySPIFFE(tlsConfig, got, "spiffe://example.org/server")
+	server1, _ := testSPIFFECertificate(t, "spiffe://example.org/server", ca, caKey)
+	if chains, err := verify([]*x509.Certificate{server1}); err != nil || len(chains) == 0 {
+		t.Fatalf("expected server SVID to verify: %v", err)
+	}
+	if _, err := verify([]*x509.Certificate{leaf}); err == nil {
+		t.Fatalf("expected an SVID with the wrong SPIFFE ID to be rejected")
+	}
+}
@@ -0,0 +1,702 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/youmark/pkcs8"
+	"golang.org/x/crypto/pkcs12"
+)
+
+// tlsVersions maps the tls_min_version and tls_max_version values to
+// crypto/tls versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsVersionNames = []string{"1.0", "1.1", "1.2", "1.3"}
+
+// tlsCurves maps the tls_curve_preferences values to crypto/tls curves.
+var tlsCurves = map[string]tls.CurveID{
+	"X25519": tls.X25519,
+	"P256":   tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P521":   tls.CurveP521,
+}
+
+var tlsCurveNames = []string{"X25519", "P256", "P384", "P521"}
+
+// tlsCipherSuiteNames lists the names accepted in tls_cipher_suites: every
+// suite crypto/tls implements, including the insecure ones some legacy
+// devices still require.
+func tlsCipherSuiteNames() []string {
+	var names []string
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		names = append(names, suite.Name)
+	}
+	return names
+}
+
+// expandTLSSettings converts cipher suite and curve names, which are
+// validated by the schema, to their crypto/tls identifiers.
+func expandTLSSettings(suites, curves []interface{}) ([]uint16, []tls.CurveID) {
+	ids := map[string]uint16{}
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		ids[suite.Name] = suite.ID
+	}
+	var suiteIDs []uint16
+	for _, name := range suites {
+		suiteIDs = append(suiteIDs, ids[name.(string)])
+	}
+	var curveIDs []tls.CurveID
+	for _, name := range curves {
+		curveIDs = append(curveIDs, tlsCurves[name.(string)])
+	}
+	return suiteIDs, curveIDs
+}
+
+// providerTLS is the provider's tls block, the defaults of every request.
+type providerTLS struct {
+	ca                       string
+	clientCrt                string
+	clientKey                string
+	includeSystemCA          bool
+	insecure                 bool
+	skipHostnameVerification bool
+	minVersion               string
+	maxVersion               string
+	cipherSuites             []uint16
+	curvePreferences         []tls.CurveID
+}
+
+// tlsSchema is the provider's tls block.
+func tlsSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		MaxItems: 1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"ca": {
+					Type:          schema.TypeString,
+					Optional:      true,
+					ConflictsWith: []string{"tls.0.ca_file"},
+				},
+				"ca_file": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+				"include_system_ca": {
+					Type:     schema.TypeBool,
+					Optional: true,
+				},
+				"client_crt": {
+					Type:          schema.TypeString,
+					Optional:      true,
+					ConflictsWith: []string{"tls.0.client_crt_file"},
+				},
+				"client_crt_file": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+				"client_key": {
+					Type:          schema.TypeString,
+					Optional:      true,
+					Sensitive:     true,
+					ConflictsWith: []string{"tls.0.client_key_file"},
+				},
+				"client_key_file": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+				"client_key_password": {
+					Type:      schema.TypeString,
+					Optional:  true,
+					Sensitive: true,
+				},
+				"client_pkcs12": {
+					Type:          schema.TypeString,
+					Optional:      true,
+					Sensitive:     true,
+					ConflictsWith: []string{"tls.0.client_pkcs12_file", "tls.0.client_crt", "tls.0.client_crt_file", "tls.0.client_key", "tls.0.client_key_file"},
+				},
+				"client_pkcs12_file": {
+					Type:          schema.TypeString,
+					Optional:      true,
+					ConflictsWith: []string{"tls.0.client_crt", "tls.0.client_crt_file", "tls.0.client_key", "tls.0.client_key_file"},
+				},
+				"pkcs12_password": {
+					Type:      schema.TypeString,
+					Optional:  true,
+					Sensitive: true,
+				},
+				"insecure": {
+					Type:          schema.TypeBool,
+					Optional:      true,
+					ConflictsWith: []string{"tls.0.ca", "tls.0.ca_file", "tls.0.skip_hostname_verification"},
+				},
+				"skip_hostname_verification": {
+					Type:     schema.TypeBool,
+					Optional: true,
+				},
+				"min_version": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					ValidateFunc: validation.StringInSlice(tlsVersionNames, false),
+				},
+				"max_version": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					ValidateFunc: validation.StringInSlice(tlsVersionNames, false),
+				},
+				"cipher_suites":     tlsCipherSuitesSchema(),
+				"curve_preferences": tlsCurvePreferencesSchema(),
+			},
+		},
+	}
+}
+
+// tlsCipherSuitesSchema lists cipher suites by their IANA name, e.g.
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256.
+func tlsCipherSuitesSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Elem: &schema.Schema{
+			Type:         schema.TypeString,
+			ValidateFunc: validation.StringInSlice(tlsCipherSuiteNames(), false),
+		},
+	}
+}
+
+func tlsCurvePreferencesSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Elem: &schema.Schema{
+			Type:         schema.TypeString,
+			ValidateFunc: validation.StringInSlice(tlsCurveNames, false),
+		},
+	}
+}
+
+// expandProviderTLS reads the provider's tls block, loading the files it
+// names.
+func expandProviderTLS(v interface{}) (*providerTLS, error) {
+	l, ok := v.([]interface{})
+	if !ok || len(l) == 0 || l[0] == nil {
+		return nil, nil
+	}
+	m := l[0].(map[string]interface{})
+	get := func(key string) (interface{}, bool) {
+		s, _ := m[key].(string)
+		return s, s != ""
+	}
+	config := &providerTLS{
+		includeSystemCA:          m["include_system_ca"].(bool),
+		insecure:                 m["insecure"].(bool),
+		skipHostnameVerification: m["skip_hostname_verification"].(bool),
+		minVersion:               m["min_version"].(string),
+		maxVersion:               m["max_version"].(string),
+	}
+	config.cipherSuites, config.curvePreferences = expandTLSSettings(m["cipher_suites"].([]interface{}), m["curve_preferences"].([]interface{}))
+	var err error
+	if config.ca, _, err = pemSetting(get, "ca"); err != nil {
+		return nil, err
+	}
+	if config.clientCrt, _, err = pemSetting(get, "client_crt"); err != nil {
+		return nil, err
+	}
+	if config.clientKey, _, err = pemSetting(get, "client_key"); err != nil {
+		return nil, err
+	}
+	if password, ok := get("client_key_password"); ok && config.clientKey != "" {
+		if config.clientKey, err = decryptPrivateKey(config.clientKey, password.(string)); err != nil {
+			return nil, err
+		}
+	}
+	if (config.clientCrt == "") != (config.clientKey == "") {
+		return nil, fmt.Errorf("both a client certificate and its key must be specified")
+	}
+	if config.clientCrt == "" {
+		if config.clientCrt, config.clientKey, _, err = pkcs12Setting(get); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// decryptPrivateKey decrypts a passphrase-protected PEM private key, either
+// PKCS#8 ("ENCRYPTED PRIVATE KEY", as written by openssl pkcs8 -topk8) or
+// legacy OpenSSL encryption (a Proc-Type: 4,ENCRYPTED header). Keys that are
+// not encrypted are returned as they are.
+func decryptPrivateKey(keyPEM, password string) (string, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return keyPEM, nil
+	}
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		key, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(password))
+		if err != nil {
+			return "", err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return "", err
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+	}
+	if x509.IsEncryptedPEMBlock(block) {
+		der, err := x509.DecryptPEMBlock(block, []byte(password))
+		if err != nil {
+			return "", err
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der})), nil
+	}
+	return keyPEM, nil
+}
+
+// pkcs12Setting returns the client certificate chain and key held by the
+// PKCS#12 bundle given by get, base64 encoded in client_pkcs12 or read from
+// the file named by client_pkcs12_file, and whether either is set.
+func pkcs12Setting(get func(string) (interface{}, bool)) (string, string, bool, error) {
+	var data []byte
+	if v, ok := get("client_pkcs12"); ok {
+		b, err := base64.StdEncoding.DecodeString(v.(string))
+		if err != nil {
+			return "", "", false, fmt.Errorf("client_pkcs12 is not valid base64: %s", err)
+		}
+		data = b
+	} else if v, ok := get("client_pkcs12_file"); ok {
+		b, err := ioutil.ReadFile(expandHome(v.(string)))
+		if err != nil {
+			return "", "", false, err
+		}
+		data = b
+	} else {
+		return "", "", false, nil
+	}
+	v, _ := get("pkcs12_password")
+	password, _ := v.(string)
+	crt, key, err := pkcs12KeyPair(data, password)
+	if err != nil {
+		return "", "", false, err
+	}
+	return crt, key, true, nil
+}
+
+// pkcs12KeyPair converts a PKCS#12 bundle to a PEM certificate chain and
+// private key for tls.X509KeyPair, putting the certificate matching the key
+// first. Only the legacy encryption schemes (3DES and RC2 with SHA-1)
+// are supported, not the AES ones newer tools default to.
+func pkcs12KeyPair(data []byte, password string) (string, string, error) {
+	blocks, err := pkcs12.ToPEM(data, password)
+	if err != nil {
+		return "", "", fmt.Errorf("decoding PKCS#12 bundle: %s", err)
+	}
+	var certs [][]byte
+	var key []byte
+	for _, b := range blocks {
+		if b.Type == "CERTIFICATE" {
+			certs = append(certs, pem.EncodeToMemory(&pem.Block{Type: b.Type, Bytes: b.Bytes}))
+		} else {
+			key = pem.EncodeToMemory(&pem.Block{Type: b.Type, Bytes: b.Bytes})
+		}
+	}
+	if key == nil || len(certs) == 0 {
+		return "", "", fmt.Errorf("PKCS#12 bundle must hold a certificate and its private key")
+	}
+	for i := range certs {
+		chain := append([][]byte{certs[i]}, certs[:i]...)
+		chain = append(chain, certs[i+1:]...)
+		crt := bytes.Join(chain, nil)
+		if _, err := tls.X509KeyPair(crt, key); err == nil {
+			return string(crt), string(key), nil
+		}
+	}
+	return "", "", fmt.Errorf("no certificate in the PKCS#12 bundle matches its private key")
+}
+
+// pemSetting returns the PEM value of attr as given by get, inline or read
+// from the file named by attr_file, and whether either is set.
+func pemSetting(get func(string) (interface{}, bool), attr string) (string, bool, error) {
+	if v, ok := get(attr); ok {
+		return v.(string), true, nil
+	}
+	if v, ok := get(attr + "_file"); ok {
+		b, err := ioutil.ReadFile(expandHome(v.(string)))
+		if err != nil {
+			return "", false, err
+		}
+		return string(b), true, nil
+	}
+	return "", false, nil
+}
+
+// newTLSConfig builds the client TLS configuration described by d. Settings
+// d leaves out are inherited from the provider's tls block.
+func newTLSConfig(ctx context.Context, d *schema.ResourceData, config *providerConfig) (*tls.Config, diag.Diagnostics) {
+	tlsConfig := &tls.Config{}
+	defaults := config.tls
+	if defaults == nil {
+		defaults = &providerTLS{}
+	}
+
+	castr, hasCA, err := pemSetting(d.GetOk, "ca")
+	if err != nil {
+		return nil, diag.Errorf("Error loading ca: %s", err)
+	}
+	if !hasCA {
+		castr = defaults.ca
+	}
+	if castr != "" {
+		caCertPool := x509.NewCertPool()
+		if v, ok := d.GetOk("include_system_ca"); (ok && v.(bool)) || defaults.includeSystemCA {
+			systemPool, err := x509.SystemCertPool()
+			if err != nil {
+				return nil, diag.Errorf("Error loading the system trust store: %s", err)
+			}
+			caCertPool = systemPool
+		}
+		caCertPool.AppendCertsFromPEM([]byte(castr))
+		tlsConfig.RootCAs = caCertPool
+	}
+
+	client_crt, hasCrt, err := pemSetting(d.GetOk, "client_crt")
+	if err != nil {
+		return nil, diag.Errorf("Error loading client_crt: %s", err)
+	}
+	client_key, hasKey, err := pemSetting(d.GetOk, "client_key")
+	if err != nil {
+		return nil, diag.Errorf("Error loading client_key: %s", err)
+	}
+	if password, ok := d.GetOk("client_key_password"); ok && hasKey {
+		if client_key, err = decryptPrivateKey(client_key, password.(string)); err != nil {
+			return nil, diag.Errorf("Error decrypting client_key: %s", err)
+		}
+	}
+	pkcs11Config, usePKCS11 := d.GetOk("pkcs11")
+	if hasCrt != hasKey && !usePKCS11 {
+		return nil, diag.Errorf("Both client_crt and client_key must be specified")
+	}
+	if usePKCS11 {
+		cert, err := pkcs11Certificate(expandPKCS11(pkcs11Config), client_crt)
+		if err != nil {
+			return nil, diag.Errorf("Error loading PKCS#11 client key: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		client_crt = ""
+	} else if !hasCrt {
+		crt, key, hasPKCS12, err := pkcs12Setting(d.GetOk)
+		if err != nil {
+			return nil, diag.Errorf("Error loading client_pkcs12: %s", err)
+		}
+		client_crt, client_key = crt, key
+		if !hasPKCS12 {
+			client_crt, client_key = defaults.clientCrt, defaults.clientKey
+		}
+	}
+	if client_crt != "" {
+		clientCerts, err := tls.X509KeyPair(
+			[]byte(client_crt),
+			[]byte(client_key),
+		)
+		if err != nil {
+			return nil, diag.Errorf("Error loading client certificates: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCerts}
+	}
+
+	minVersion, maxVersion := defaults.minVersion, defaults.maxVersion
+	if v, ok := d.GetOk("tls_min_version"); ok {
+		minVersion = v.(string)
+	}
+	if v, ok := d.GetOk("tls_max_version"); ok {
+		maxVersion = v.(string)
+	}
+	tlsConfig.MinVersion, tlsConfig.MaxVersion = tlsVersions[minVersion], tlsVersions[maxVersion]
+	if tlsConfig.MinVersion != 0 && tlsConfig.MaxVersion != 0 && tlsConfig.MinVersion > tlsConfig.MaxVersion {
+		return nil, diag.Errorf("TLS minimum version %s is above the maximum version %s", minVersion, maxVersion)
+	}
+
+	tlsConfig.CipherSuites, tlsConfig.CurvePreferences = defaults.cipherSuites, defaults.curvePreferences
+	if v, ok := d.GetOk("tls_cipher_suites"); ok {
+		tlsConfig.CipherSuites, _ = expandTLSSettings(v.([]interface{}), nil)
+	}
+	if v, ok := d.GetOk("tls_curve_preferences"); ok {
+		_, tlsConfig.CurvePreferences = expandTLSSettings(nil, v.([]interface{}))
+	}
+
+	if pairs, ok := d.GetOk("client_certificates"); ok {
+		var candidates []tls.Certificate
+		for i, p := range pairs.([]interface{}) {
+			pair := p.(map[string]interface{})
+			cert, err := tls.X509KeyPair([]byte(pair["client_crt"].(string)), []byte(pair["client_key"].(string)))
+			if err != nil {
+				return nil, diag.Errorf("Error loading client certificate %d: %s", i, err)
+			}
+			candidates = append(candidates, cert)
+		}
+		tlsConfig.GetClientCertificate = selectClientCertificate(candidates)
+	}
+
+	var peerID string
+	if v, ok := d.GetOk("spiffe_peer_id"); ok {
+		peerID = v.(string)
+	}
+	if peerID != "" && defaults.skipHostnameVerification {
+		return nil, diag.Errorf("spiffe_peer_id cannot be used with the provider's tls.skip_hostname_verification")
+	}
+	v, ok := d.GetOk("insecure")
+	insecure := (ok && v.(bool)) || (defaults.insecure && !hasCA)
+
+	var verify chainVerifier
+	if socketPath, ok := d.GetOk("spiffe_socket_path"); ok {
+		svid, err := fetchX509SVID(ctx, socketPath.(string), d.Get("spiffe_id").(string))
+		if err != nil {
+			return nil, diag.Errorf("Error loading SPIFFE identity: %s", err)
+		}
+		verify = applySPIFFE(tlsConfig, svid, peerID)
+	}
+
+	// A data source's own ca asks for verification against it, whatever
+	// the provider's default. insecure skips verification altogether.
+	if v, ok := d.GetOk("skip_hostname_verification"); ((ok && v.(bool)) || defaults.skipHostnameVerification) && !insecure {
+		verify = verifyChainOnly(tlsConfig.RootCAs, verify)
+	}
+
+	if v, ok := d.GetOk("tls_server_name"); ok {
+		tlsConfig.ServerName = v.(string)
+	}
+
+	if v, ok := d.GetOk("pinned_spki_sha256"); ok {
+		pinSPKI(tlsConfig, v.([]interface{}))
+	}
+	if v, ok := d.GetOk("ocsp"); ok && v.(string) != "off" {
+		client, err := ocspClient(d, config)
+		if err != nil {
+			return nil, diag.Errorf("Error configuring proxy: %s", err)
+		}
+		checkOCSP(tlsConfig, v.(string), client)
+	}
+	if verify != nil {
+		verifyChainsWith(tlsConfig, verify)
+	}
+
+	var diags diag.Diagnostics
+	if insecure {
+		tlsConfig.InsecureSkipVerify = true
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "TLS certificate verification is disabled",
+			Detail:   "The server certificate is not verified, so the connection is open to interception. Prefer setting ca where possible.",
+		})
+	}
+
+	return tlsConfig, diags
+}
+
+// validateSPKIPin checks that a pin is a base64 SHA-256 digest, the format
+// of HPKP and of openssl's
+// "openssl x509 -pubkey -noout | openssl pkey -pubin -outform der |
+// openssl dgst -sha256 -binary | base64".
+func validateSPKIPin(val interface{}, key string) (warns []string, errs []error) {
+	b, err := base64.StdEncoding.DecodeString(val.(string))
+	if err != nil || len(b) != sha256.Size {
+		errs = append(errs, fmt.Errorf("%s must be a base64 encoded SHA-256 digest, got %q", key, val))
+	}
+	return
+}
+
+// pinSPKI rejects connections unless the SHA-256 digest of the public key of
+// the server certificate, or of a CA certificate in a verified chain, is
+// one of pins. The check runs after, and in addition to, the usual
+// verification; with insecure it pins the server's own key only.
+func pinSPKI(tlsConfig *tls.Config, pins []interface{}) {
+	pinned := map[string]bool{}
+	for _, pin := range pins {
+		pinned[pin.(string)] = true
+	}
+	addConnectionCheck(tlsConfig, func(cs tls.ConnectionState) error {
+		candidates := []*x509.Certificate{cs.PeerCertificates[0]}
+		for _, chain := range cs.VerifiedChains {
+			candidates = append(candidates, chain...)
+		}
+		for _, cert := range candidates {
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			if pinned[base64.StdEncoding.EncodeToString(sum[:])] {
+				return nil
+			}
+		}
+		return fmt.Errorf("server public key matches none of pinned_spki_sha256")
+	})
+}
+
+// addConnectionCheck runs check on every handshake once the certificates
+// are verified, after the checks added before it.
+func addConnectionCheck(tlsConfig *tls.Config, check func(tls.ConnectionState) error) {
+	previous := tlsConfig.VerifyConnection
+	tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return fmt.Errorf("server presented no certificate")
+		}
+		if previous != nil {
+			if err := previous(cs); err != nil {
+				return err
+			}
+		}
+		return check(cs)
+	}
+}
+
+// selectClientCertificate returns a GetClientCertificate hook that presents
+// the first candidate issued by one of the CAs the server asks for, so that a
+// runner holding certificates from several PKIs can talk to each of them.
+// When no candidate matches the first one is sent, as crypto/tls would do.
+func selectClientCertificate(candidates []tls.Certificate) func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return func(cri *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		for i := range candidates {
+			if err := cri.SupportsCertificate(&candidates[i]); err == nil {
+				return &candidates[i], nil
+			}
+		}
+		if len(candidates) == 0 {
+			return &tls.Certificate{}, nil
+		}
+		return &candidates[0], nil
+	}
+}
+
+// chainVerifier verifies the certificates presented by a server, leaf
+// first, and returns the chains it verified them through.
+type chainVerifier func(certs []*x509.Certificate) ([][]*x509.Certificate, error)
+
+// verifyChainsWith replaces the verification of crypto/tls with verify. The
+// chains it returns are handed to the checks added with addConnectionCheck
+// as VerifiedChains, as crypto/tls would, so that SPKI pinning and the OCSP
+// issuer lookup keep working; it must be called after those checks are
+// added.
+func verifyChainsWith(tlsConfig *tls.Config, verify chainVerifier) {
+	tlsConfig.InsecureSkipVerify = true
+	checks := tlsConfig.VerifyConnection
+	tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return fmt.Errorf("server presented no certificate")
+		}
+		chains, err := verify(cs.PeerCertificates)
+		if err != nil {
+			return err
+		}
+		cs.VerifiedChains = chains
+		if checks != nil {
+			return checks(cs)
+		}
+		return nil
+	}
+}
+
+// verifyChainOnly keeps full certificate chain verification against roots
+// (the system roots when nil) but skips matching the certificate against
+// the hostname, for endpoints reached by IP whose certificates are otherwise
+// valid and trusted. A verifier set before it, such as the SPIFFE ID check,
+// still runs once the chain is verified.
+func verifyChainOnly(roots *x509.CertPool, previous chainVerifier) chainVerifier {
+	return func(certs []*x509.Certificate) ([][]*x509.Certificate, error) {
+		intermediates := x509.NewCertPool()
+		for _, c := range certs[1:] {
+			intermediates.AddCert(c)
+		}
+		chains, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if previous != nil {
+			if _, err := previous(certs); err != nil {
+				return nil, err
+			}
+		}
+		return chains, nil
+	}
+}
+
+// flattenCertificates describes the certificate chain presented by the
+// server, leaf first, as the server_certificates attribute. spki_sha256 is
+// in the format of pinned_spki_sha256. Plain HTTP responses have none.
+func flattenCertificates(state *tls.ConnectionState) []interface{} {
+	if state == nil {
+		return nil
+	}
+	var certs []interface{}
+	for _, cert := range state.PeerCertificates {
+		var sans []interface{}
+		for _, name := range cert.DNSNames {
+			sans = append(sans, name)
+		}
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		for _, email := range cert.EmailAddresses {
+			sans = append(sans, email)
+		}
+		for _, uri := range cert.URIs {
+			sans = append(sans, uri.String())
+		}
+		fingerprint := sha256.Sum256(cert.Raw)
+		spki := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		certs = append(certs, map[string]interface{}{
+			"subject":            cert.Subject.String(),
+			"issuer":             cert.Issuer.String(),
+			"serial_number":      cert.SerialNumber.String(),
+			"sans":               sans,
+			"not_before":         cert.NotBefore.UTC().Format(time.RFC3339),
+			"not_after":          cert.NotAfter.UTC().Format(time.RFC3339),
+			"is_ca":              cert.IsCA,
+			"sha256_fingerprint": hex.EncodeToString(fingerprint[:]),
+			"spki_sha256":        base64.StdEncoding.EncodeToString(spki[:]),
+			"pem":                string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+		})
+	}
+	return certs
+}
+
+// negotiatedProtocol returns the protocol resp was received with, by its
+// ALPN name ("http/1.1", "h2") or "h2c" for HTTP/2 over cleartext.
+func negotiatedProtocol(resp *http.Response) string {
+	switch {
+	case resp.ProtoMajor == 2 && resp.TLS == nil:
+		return "h2c"
+	case resp.ProtoMajor == 2:
+		return "h2"
+	default:
+		return fmt.Sprintf("http/%d.%d", resp.ProtoMajor, resp.ProtoMinor)
+	}
+}
+
+// tlsVersionName returns the tls_min_version style name of a TLS version.
+func tlsVersionName(version uint16) string {
+	for name, v := range tlsVersions {
+		if v == version {
+			return name
+		}
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
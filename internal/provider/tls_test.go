@@ -0,0 +1,662 @@
+package provider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/youmark/pkcs8"
+)
+
+// testClientCertificate issues a client certificate named cn from a new CA
+// of the same name and returns both.
+func testClientCertificate(t *testing.T, cn string) (tls.Certificate, *x509.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn + " CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, ca
+}
+
+func TestVerifyChainOnly(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// The test certificate is issued for 127.0.0.1 and example.com only.
+	u := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	get := func(tlsConfig *tls.Config) error {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		resp, err := client.Get(u)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	if err := get(&tls.Config{RootCAs: roots}); err == nil {
+		t.Fatalf("expected a hostname mismatch without skip_hostname_verification")
+	}
+
+	tlsConfig := &tls.Config{}
+	verifyChainsWith(tlsConfig, verifyChainOnly(roots, nil))
+	if err := get(tlsConfig); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	tlsConfig = &tls.Config{}
+	verifyChainsWith(tlsConfig, verifyChainOnly(x509.NewCertPool(), nil))
+	if err := get(tlsConfig); err == nil {
+		t.Fatalf("expected an untrusted chain to be rejected")
+	}
+
+	called := false
+	tlsConfig = &tls.Config{}
+	verifyChainsWith(tlsConfig, verifyChainOnly(roots, func([]*x509.Certificate) ([][]*x509.Certificate, error) {
+		called = true
+		return nil, fmt.Errorf("rejected by the earlier verifier")
+	}))
+	if err := get(tlsConfig); err == nil || !called {
+		t.Fatalf("earlier verifier not run: %v", err)
+	}
+
+	// The verified chains reach the later checks.
+	var chains [][]*x509.Certificate
+	tlsConfig = &tls.Config{}
+	addConnectionCheck(tlsConfig, func(cs tls.ConnectionState) error {
+		chains = cs.VerifiedChains
+		return nil
+	})
+	verifyChainsWith(tlsConfig, verifyChainOnly(roots, nil))
+	if err := get(tlsConfig); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(chains) == 0 {
+		t.Fatalf("connection checks got no verified chains")
+	}
+}
+
+func TestSelectClientCertificate(t *testing.T) {
+	first, _ := testClientCertificate(t, "first")
+	second, secondCA := testClientCertificate(t, "second")
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(secondCA)
+
+	var presented string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented = r.TLS.PeerCertificates[0].Subject.CommonName
+	}))
+	server.TLS = &tls.Config{ClientCAs: clientCAs, ClientAuth: tls.RequireAndVerifyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:              roots,
+		GetClientCertificate: selectClientCertificate([]tls.Certificate{first, second}),
+	}}}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if presented != "second" {
+		t.Fatalf("server got certificate %q; want second", presented)
+	}
+}
+
+func TestNewTLSConfig_insecure(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	d := schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{"insecure": true})
+	tlsConfig, diags := newTLSConfig(context.Background(), d, &providerConfig{})
+	if len(diags) != 1 || diags[0].Severity != diag.Warning {
+		t.Fatalf("got diagnostics %v; want a single warning", diags)
+	}
+
+	resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("self-signed certificate rejected: %s", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewTLSConfig_providerDefaults(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	serverCA := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	_, ca := testClientCertificate(t, "other")
+	otherCA := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}))
+
+	get := func(config *providerConfig, raw map[string]interface{}) (diag.Diagnostics, error) {
+		d := schema.TestResourceDataRaw(t, dataSource().Schema, raw)
+		tlsConfig, diags := newTLSConfig(context.Background(), d, config)
+		if diags.HasError() {
+			t.Fatalf("err: %v", diags)
+		}
+		resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}).Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return diags, err
+	}
+
+	config := &providerConfig{tls: &providerTLS{ca: serverCA}}
+	if _, err := get(config, map[string]interface{}{}); err != nil {
+		t.Fatalf("provider ca not inherited: %s", err)
+	}
+	if _, err := get(config, map[string]interface{}{"ca": otherCA}); err == nil {
+		t.Fatalf("data source ca does not override the provider's")
+	}
+
+	config = &providerConfig{tls: &providerTLS{insecure: true}}
+	if diags, err := get(config, map[string]interface{}{}); err != nil || len(diags) != 1 {
+		t.Fatalf("provider insecure not inherited: %v, %v", err, diags)
+	}
+	if _, err := get(config, map[string]interface{}{"ca": otherCA}); err == nil {
+		t.Fatalf("provider insecure applied although the data source sets a ca")
+	}
+
+	config = &providerConfig{tls: &providerTLS{skipHostnameVerification: true}}
+	if _, err := get(config, map[string]interface{}{"insecure": true}); err != nil {
+		t.Fatalf("provider skip_hostname_verification verified the chain despite insecure: %s", err)
+	}
+	d := schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{"spiffe_peer_id": "spiffe://example.org/server"})
+	if _, diags := newTLSConfig(context.Background(), d, config); !diags.HasError() {
+		t.Fatalf("expected spiffe_peer_id to be rejected with provider skip_hostname_verification")
+	}
+}
+
+func TestNewTLSConfig_files(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{"ca_file": caFile})
+	tlsConfig, diags := newTLSConfig(context.Background(), d, &providerConfig{})
+	if diags.HasError() {
+		t.Fatalf("err: %v", diags)
+	}
+	resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("ca_file not trusted: %s", err)
+	}
+	resp.Body.Close()
+
+	d = schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{"ca_file": filepath.Join(dir, "missing.pem")})
+	if _, diags := newTLSConfig(context.Background(), d, &providerConfig{}); !diags.HasError() {
+		t.Fatalf("expected an error for a missing ca_file")
+	}
+
+	defaults, err := expandProviderTLS([]interface{}{map[string]interface{}{
+		"ca": "", "ca_file": caFile, "include_system_ca": false, "client_crt": "", "client_crt_file": "", "client_key": "", "client_key_file": "",
+		"insecure": false, "skip_hostname_verification": false, "min_version": "", "max_version": "",
+		"cipher_suites": []interface{}{}, "curve_preferences": []interface{}{},
+	}})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if defaults.ca != string(ca) {
+		t.Fatalf("provider ca_file not loaded")
+	}
+}
+
+func TestNewTLSConfig_includeSystemCA(t *testing.T) {
+	_, ca := testClientCertificate(t, "private")
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}))
+
+	want, err := x509.SystemCertPool()
+	if err != nil {
+		t.Skipf("no system trust store: %s", err)
+	}
+	want.AddCert(ca)
+	only := x509.NewCertPool()
+	only.AddCert(ca)
+
+	d := schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{"ca": caPEM, "include_system_ca": true})
+	tlsConfig, diags := newTLSConfig(context.Background(), d, &providerConfig{})
+	if diags.HasError() {
+		t.Fatalf("err: %v", diags)
+	}
+	if !tlsConfig.RootCAs.Equal(want) {
+		t.Errorf("ca was not added to the system trust store")
+	}
+
+	d = schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{"ca": caPEM})
+	tlsConfig, _ = newTLSConfig(context.Background(), d, &providerConfig{})
+	if !tlsConfig.RootCAs.Equal(only) {
+		t.Errorf("ca does not replace the system trust store by default")
+	}
+}
+
+func TestNewTLSConfig_versions(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(config *providerConfig, raw map[string]interface{}) error {
+		d := schema.TestResourceDataRaw(t, dataSource().Schema, raw)
+		tlsConfig, diags := newTLSConfig(context.Background(), d, config)
+		if diags.HasError() {
+			t.Fatalf("err: %v", diags)
+		}
+		tlsConfig.InsecureSkipVerify = true
+		resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}).Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	if err := get(&providerConfig{}, map[string]interface{}{"tls_max_version": "1.2"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := get(&providerConfig{}, map[string]interface{}{"tls_min_version": "1.3"}); err == nil {
+		t.Fatalf("TLS 1.2 server accepted with tls_min_version 1.3")
+	}
+	config := &providerConfig{tls: &providerTLS{minVersion: "1.3"}}
+	if err := get(config, map[string]interface{}{}); err == nil {
+		t.Fatalf("provider min_version not inherited")
+	}
+	if err := get(config, map[string]interface{}{"tls_min_version": "1.2"}); err != nil {
+		t.Fatalf("tls_min_version does not override the provider's: %s", err)
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{"tls_min_version": "1.3", "tls_max_version": "1.2"})
+	if _, diags := newTLSConfig(context.Background(), d, &providerConfig{}); !diags.HasError() {
+		t.Fatalf("expected an error for a minimum above the maximum")
+	}
+}
+
+func TestNewTLSConfig_cipherSuites(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(raw map[string]interface{}) error {
+		d := schema.TestResourceDataRaw(t, dataSource().Schema, raw)
+		tlsConfig, diags := newTLSConfig(context.Background(), d, &providerConfig{})
+		if diags.HasError() {
+			t.Fatalf("err: %v", diags)
+		}
+		tlsConfig.InsecureSkipVerify = true
+		resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}).Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	if err := get(map[string]interface{}{
+		"tls_cipher_suites":     []interface{}{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+		"tls_curve_preferences": []interface{}{"P384"},
+	}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := get(map[string]interface{}{"tls_cipher_suites": []interface{}{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}}); err == nil {
+		t.Fatalf("handshake succeeded without a common cipher suite")
+	}
+}
+
+// testPKCS12 is a bundle for CN=pkcs12-client with the password "secret",
+// made with openssl pkcs12 -export -certpbe PBE-SHA1-3DES -keypbe
+// PBE-SHA1-3DES -macalg sha1.
+const testPKCS12 = `
+MIIDigIBAzCCA1AGCSqGSIb3DQEHAaCCA0EEggM9MIIDOTCCAi8GCSqGSIb3DQEHBqCCAiAwggIc
+AgEAMIICFQYJKoZIhvcNAQcBMBwGCiqGSIb3DQEMAQMwDgQIkA0alhgyMgICAggAgIIB6DtKrW+q
+s19ANI2hBL01eDy1XNcC5K7qZ77EVom/bx/w2hShGVWnBJcWYFfSydgtqi/0Nh4cz29pS3HayjM6
+LMIut8qz7WqCbwQmsu3KSTVR9eqRO7DNEyf6+OP2yOiozlaKAvCeP2IS6XREHRE4njOz6S5K9cPS
+APq/MO5jshewZvyEVwTtzngBHP2Av2HEBoTy1FbN6CsAPWOzeLzVPyL/ITc9JQ7+/XbQ+SqufGcx
+LftPHdCVV9aWckyoU/ozBvEOEVbSXUvNCDzV9VbSdlv5r+hNWodU+InsWrguihJag4ertMPJBjDd
+keFVSDgt7i2U3crTcUVBavGdiNMzwajSKnlakVRTsWQX+qiBL9eSyEr9LoETordIV5+CL7/Voec4
+GGnaM7kwKLYIFLM3Fo3t2qvqAxZb8xAm2FPjSCaJst0jNzTNYCQxh/I0XOcwOEco1h9YHoGKkCi0
+oE5NT6my4MsBdgXVDbD28z6tJ8Jb0EWA0cAyENF4IxRTqGjZRVw+05tg6Qc4c3FxwvXB2Aw4JaAg
+cycswt6q1vaWVnLRuCnjabqXBLv3ymCM2r0mkEgMhzXFAgju0Tx+fmS1TqEKImWHXTT8xToyoNiQ
+0Ctsj+/uK8mqZGY24VSP0nOLdX3l802QIn2JMIIBAgYJKoZIhvcNAQcBoIH0BIHxMIHuMIHrBgsq
+hkiG9w0BDAoBAqCBtDCBsTAcBgoqhkiG9w0BDAEDMA4ECBmxnmCKbGjRAgIIAASBkCr8PN2RvBn/
+5+xk9R9QviSrZYLglUCunHDEYlr3F8SSD22ha+Uh6fcwScAIJJroZTQEGb3LXUJXnhRQDzsvbq+C
+QTTX3H0VS/eXoJsT0xNaJGo0W0zDnuP0UFkmv/t8gjVeU5ISLck8if+c8GzJ3+2425YAI9EF1txb
+ZR1hclurwSNBwUz0uk8e2gYZb+I2njElMCMGCSqGSIb3DQEJFTEWBBTdjReKYeEgCeCHODgXvHXL
+dJC8MTAxMCEwCQYFKw4DAhoFAAQU/rXxR7ptuYZJcesC+w/0e6VVoVEECOoeDcHYNYrMAgIIAA==
+`
+
+func TestNewTLSConfig_pkcs12(t *testing.T) {
+	bundle := strings.Replace(strings.TrimSpace(testPKCS12), "\n", "", -1)
+
+	d := schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{"client_pkcs12": bundle, "pkcs12_password": "secret"})
+	tlsConfig, diags := newTLSConfig(context.Background(), d, &providerConfig{})
+	if diags.HasError() {
+		t.Fatalf("err: %v", diags)
+	}
+	if len(tlsConfig.Certificates) != 1 {
+		t.Fatalf("got %d client certificates; want 1", len(tlsConfig.Certificates))
+	}
+	leaf, err := x509.ParseCertificate(tlsConfig.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if leaf.Subject.CommonName != "pkcs12-client" {
+		t.Errorf("got client certificate %q; want pkcs12-client", leaf.Subject.CommonName)
+	}
+
+	d = schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{"client_pkcs12": bundle, "pkcs12_password": "wrong"})
+	if _, diags := newTLSConfig(context.Background(), d, &providerConfig{}); !diags.HasError() {
+		t.Errorf("expected an error for a wrong pkcs12_password")
+	}
+}
+
+func TestDecryptPrivateKey(t *testing.T) {
+	cert, _ := testClientCertificate(t, "encrypted")
+	der, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	plain := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	encrypted, err := pkcs8.MarshalPrivateKey(cert.PrivateKey, []byte("secret"), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	legacy, err := x509.EncryptPEMBlock(rand.Reader, "PRIVATE KEY", der, []byte("secret"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		name     string
+		key      string
+		password string
+		err      bool
+	}{
+		{"pkcs8", string(pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encrypted})), "secret", false},
+		{"legacy", string(pem.EncodeToMemory(legacy)), "secret", false},
+		{"not encrypted", plain, "secret", false},
+		{"pkcs8 wrong password", string(pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encrypted})), "wrong", true},
+		{"legacy wrong password", string(pem.EncodeToMemory(legacy)), "wrong", true},
+	}
+	for _, tc := range cases {
+		got, err := decryptPrivateKey(tc.key, tc.password)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: err: %s", tc.name, err)
+			continue
+		}
+		crt := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+		if _, err := tls.X509KeyPair(crt, []byte(got)); err != nil {
+			t.Errorf("%s: decrypted key unusable: %s", tc.name, err)
+		}
+	}
+}
+
+func TestNewTLSConfig_pinnedSPKI(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	ca := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	sum := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	pin := base64.StdEncoding.EncodeToString(sum[:])
+	other := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	cases := []struct {
+		name   string
+		config map[string]interface{}
+		err    bool
+	}{
+		{"pinned", map[string]interface{}{"ca": ca, "pinned_spki_sha256": []interface{}{other, pin}}, false},
+		{"not pinned", map[string]interface{}{"ca": ca, "pinned_spki_sha256": []interface{}{other}}, true},
+		{"insecure pinned", map[string]interface{}{"insecure": true, "pinned_spki_sha256": []interface{}{pin}}, false},
+		{"insecure not pinned", map[string]interface{}{"insecure": true, "pinned_spki_sha256": []interface{}{other}}, true},
+	}
+	for _, tc := range cases {
+		d := schema.TestResourceDataRaw(t, dataSource().Schema, tc.config)
+		tlsConfig, diags := newTLSConfig(context.Background(), d, &providerConfig{})
+		if diags.HasError() {
+			t.Fatalf("%s: err: %v", tc.name, diags)
+		}
+		resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}).Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if (err != nil) != tc.err {
+			t.Errorf("%s: got error %v; want error %t", tc.name, err, tc.err)
+		}
+	}
+
+	if _, errs := validateSPKIPin("c2hvcnQ=", "pinned_spki_sha256"); len(errs) == 0 {
+		t.Errorf("a pin that is not a SHA-256 digest was accepted")
+	}
+}
+
+func TestNewTLSConfig_pinnedCAWithoutHostname(t *testing.T) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Pinned CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	// The server certificate names another host, so only the chain is
+	// verified.
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "server"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"server.internal"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	server.StartTLS()
+	defer server.Close()
+
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}))
+	sum := sha256.Sum256(ca.RawSubjectPublicKeyInfo)
+	pin := base64.StdEncoding.EncodeToString(sum[:])
+	other := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	cases := []struct {
+		name string
+		pins []interface{}
+		err  bool
+	}{
+		{"CA pinned", []interface{}{pin}, false},
+		{"not pinned", []interface{}{other}, true},
+	}
+	for _, tc := range cases {
+		d := schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{
+			"ca":                         caPEM,
+			"skip_hostname_verification": true,
+			"pinned_spki_sha256":         tc.pins,
+		})
+		tlsConfig, diags := newTLSConfig(context.Background(), d, &providerConfig{})
+		if diags.HasError() {
+			t.Fatalf("%s: err: %v", tc.name, diags)
+		}
+		tr := &http.Transport{TLSClientConfig: tlsConfig}
+		resp, err := (&http.Client{Transport: tr}).Get(server.URL)
+		tr.CloseIdleConnections()
+		if err == nil {
+			resp.Body.Close()
+		}
+		if (err != nil) != tc.err {
+			t.Errorf("%s: got error %v; want error %t", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestFlattenCertificates(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+
+	certs := flattenCertificates(resp.TLS)
+	if len(certs) != 1 {
+		t.Fatalf("got %d certificates; want 1", len(certs))
+	}
+	cert := certs[0].(map[string]interface{})
+	sum := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	if got, want := cert["spki_sha256"], base64.StdEncoding.EncodeToString(sum[:]); got != want {
+		t.Errorf("got spki_sha256 %q; want %q", got, want)
+	}
+	if got := cert["sans"].([]interface{}); len(got) == 0 || got[0] != "example.com" {
+		t.Errorf("got sans %v; want the server's names", got)
+	}
+	if got := cert["not_after"]; got != server.Certificate().NotAfter.UTC().Format(time.RFC3339) {
+		t.Errorf("got not_after %q", got)
+	}
+	d := schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{})
+	if err := d.Set("server_certificates", certs); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if certs := flattenCertificates(nil); certs != nil {
+		t.Errorf("got %v for a plain HTTP response; want none", certs)
+	}
+}
+
+func TestNewTLSConfig_serverName(t *testing.T) {
+	var sni string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		sni = hello.ServerName
+		return nil, nil
+	}}
+	server.StartTLS()
+	defer server.Close()
+	ca := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	// The test certificate is valid for example.com, which the server name
+	// is verified against.
+	d := schema.TestResourceDataRaw(t, dataSource().Schema, map[string]interface{}{"ca": ca, "tls_server_name": "example.com"})
+	tlsConfig, diags := newTLSConfig(context.Background(), d, &providerConfig{})
+	if diags.HasError() {
+		t.Fatalf("err: %v", diags)
+	}
+	resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if sni != "example.com" {
+		t.Errorf("got SNI %q; want example.com", sni)
+	}
+}
+
+func TestNegotiatedProtocol(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if got := negotiatedProtocol(resp); got != "h2" {
+		t.Errorf("got %q; want h2", got)
+	}
+	if got := tlsVersionName(resp.TLS.Version); got != "1.3" {
+		t.Errorf("got TLS version %q; want 1.3", got)
+	}
+
+	plain := &http.Response{ProtoMajor: 1, ProtoMinor: 1}
+	if got := negotiatedProtocol(plain); got != "http/1.1" {
+		t.Errorf("got %q; want http/1.1", got)
+	}
+	if got := negotiatedProtocol(&http.Response{ProtoMajor: 2}); got != "h2c" {
+		t.Errorf("got %q; want h2c", got)
+	}
+}
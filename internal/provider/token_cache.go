@@ -0,0 +1,197 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cachedToken is an access token persisted between runs.
+type cachedToken struct {
+	Token  string    `json:"token"`
+	Expiry time.Time `json:"expiry"`
+}
+
+// tokenFileCache persists tokens in a file encrypted with AES-256-GCM so that
+// short-lived CI jobs can reuse a token across plans without it ever sitting
+// on disk in plaintext.
+type tokenFileCache struct {
+	mu   sync.Mutex
+	path string
+	aead cipher.AEAD
+}
+
+// newTokenFileCache returns a cache stored at path whose encryption key is
+// derived from the value of the keyEnv environment variable or, failing that,
+// the output of keyCommand (for example a KMS decrypt invocation).
+func newTokenFileCache(path, keyEnv string, keyCommand []string) (*tokenFileCache, error) {
+	var secret []byte
+	if keyEnv != "" {
+		secret = []byte(os.Getenv(keyEnv))
+	}
+	if len(secret) == 0 && len(keyCommand) > 0 {
+		out, err := exec.Command(keyCommand[0], keyCommand[1:]...).Output()
+		if err != nil {
+			return nil, fmt.Errorf("running token cache key command: %s", err)
+		}
+		secret = bytes.TrimSpace(out)
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("no token cache key available")
+	}
+
+	key := sha256.Sum256(secret)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenFileCache{path: expandHome(path), aead: aead}, nil
+}
+
+// lookup returns the token stored under name if it is still valid for at
+// least the given margin. A nil cache, a missing file or one that cannot be
+// decrypted is treated as empty.
+func (c *tokenFileCache) lookup(name string, margin time.Duration) (cachedToken, bool) {
+	if c == nil {
+		return cachedToken{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tokens, err := c.load()
+	if err != nil {
+		return cachedToken{}, false
+	}
+	t, ok := tokens[name]
+	if !ok || time.Now().Add(margin).After(t.Expiry) {
+		return cachedToken{}, false
+	}
+	return t, true
+}
+
+// put stores token under name. Tokens without an expiry are not stored, as
+// nothing would ever tell a later run that they are no longer valid.
+func (c *tokenFileCache) put(name, token string, expiry time.Time) error {
+	if c == nil || expiry.IsZero() {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tokens, err := c.load()
+	if err != nil {
+		tokens = map[string]cachedToken{}
+	}
+	now := time.Now()
+	for k, t := range tokens {
+		if now.After(t.Expiry) {
+			delete(tokens, k)
+		}
+	}
+	tokens[name] = cachedToken{Token: token, Expiry: expiry}
+	return c.save(tokens)
+}
+
+// remove drops every entry holding token.
+func (c *tokenFileCache) remove(token string) error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tokens, err := c.load()
+	if err != nil {
+		return nil
+	}
+	changed := false
+	for k, t := range tokens {
+		if t.Token == token {
+			delete(tokens, k)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return c.save(tokens)
+}
+
+// save encrypts tokens and atomically replaces the cache file with them.
+func (c *tokenFileCache) save(tokens map[string]cachedToken) error {
+	plaintext, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	sealed := c.aead.Seal(nonce, nonce, plaintext, nil)
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), ".token-cache")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(sealed); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+func (c *tokenFileCache) load() (map[string]cachedToken, error) {
+	sealed, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, fmt.Errorf("token cache is truncated")
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return nil, err
+	}
+	tokens := map[string]cachedToken{}
+	if err := json.Unmarshal(plaintext, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenFileCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token-cache")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tokens")
+
+	os.Setenv("TEST_TOKEN_CACHE_KEY", "s3cr3t")
+	defer os.Unsetenv("TEST_TOKEN_CACHE_KEY")
+
+	cache, err := newTokenFileCache(path, "TEST_TOKEN_CACHE_KEY", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := cache.put("api", "token-value", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := cache.put("stale", "old", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if bytes.Contains(raw, []byte("token-value")) {
+		t.Fatalf("token cache stores the token in plaintext")
+	}
+
+	// A second instance, as in a later run, reads the same tokens.
+	cache, err = newTokenFileCache(path, "", []string{"echo", "s3cr3t"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if token, ok := cache.lookup("api", time.Minute); !ok || token.Token != "token-value" {
+		t.Fatalf("got %q, %v; want token-value", token.Token, ok)
+	}
+	if _, ok := cache.lookup("stale", 5*time.Minute); ok {
+		t.Fatalf("token expiring within the margin was returned")
+	}
+
+	wrongKey, err := newTokenFileCache(path, "", []string{"echo", "other"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, ok := wrongKey.lookup("api", 0); ok {
+		t.Fatalf("token was decrypted with the wrong key")
+	}
+}
@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenExpiryMargin is how long before its expiry a token is refreshed.
+const tokenExpiryMargin = time.Minute
+
+// unknownExpiryLifetime is how long a token issued without an expiry is
+// reused before it is fetched again. Such tokens are never persisted.
+const unknownExpiryLifetime = 15 * time.Minute
+
+// tokenFetchFunc obtains a new token and its expiry (zero if unknown).
+type tokenFetchFunc func(ctx context.Context) (string, time.Time, error)
+
+// tokenStore shares the tokens of an auth profile between all the reads of a
+// provider instance. When the token expires mid-run while many data sources
+// read in parallel, exactly one of them refreshes it and the others wait for
+// and reuse the result, so the identity provider doesn't see a burst of
+// logins. Tokens are also persisted in the optional encrypted file cache.
+type tokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]cachedToken
+	locks  map[string]*sync.Mutex
+	file   *tokenFileCache
+}
+
+func newTokenStore(file *tokenFileCache) *tokenStore {
+	return &tokenStore{
+		tokens: map[string]cachedToken{},
+		locks:  map[string]*sync.Mutex{},
+		file:   file,
+	}
+}
+
+// token returns the token stored under key, calling fetch to obtain a new
+// one when there is none or it is about to expire.
+func (s *tokenStore) token(ctx context.Context, key string, fetch tokenFetchFunc) (string, error) {
+	if token, ok := s.cached(key); ok {
+		return token, nil
+	}
+
+	// Serialise refreshes of the same key; waiters find the token the
+	// first caller stored once they get the lock.
+	s.mu.Lock()
+	lock, ok := s.locks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.locks[key] = lock
+	}
+	s.mu.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if token, ok := s.cached(key); ok {
+		return token, nil
+	}
+	if t, ok := s.file.lookup(key, tokenExpiryMargin); ok {
+		s.store(key, t)
+		return t.Token, nil
+	}
+
+	token, expiry, err := fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	if expiry.IsZero() {
+		s.store(key, cachedToken{Token: token, Expiry: time.Now().Add(unknownExpiryLifetime)})
+		return token, nil
+	}
+	s.store(key, cachedToken{Token: token, Expiry: expiry})
+	// A cache that can't be written only costs a login on the next run.
+	s.file.put(key, token, expiry)
+	return token, nil
+}
+
+// reject drops token, which a server refused, from the store and the file
+// cache so that the next read of its key fetches a new one.
+func (s *tokenStore) reject(token string) {
+	s.mu.Lock()
+	for key, t := range s.tokens {
+		if t.Token == token {
+			delete(s.tokens, key)
+		}
+	}
+	s.mu.Unlock()
+	s.file.remove(token)
+}
+
+func (s *tokenStore) cached(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[key]
+	if !ok || time.Now().Add(tokenExpiryMargin).After(t.Expiry) {
+		return "", false
+	}
+	return t.Token, true
+}
+
+func (s *tokenStore) store(key string, t cachedToken) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[key] = t
+}
@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenStore_singleRefresh(t *testing.T) {
+	store := newTokenStore(nil)
+
+	var fetches int32
+	fetch := func(ctx context.Context) (string, time.Time, error) {
+		n := atomic.AddInt32(&fetches, 1)
+		time.Sleep(20 * time.Millisecond)
+		return fmt.Sprintf("token-%d", n), time.Now().Add(time.Hour), nil
+	}
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 20)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			token, err := store.token(context.Background(), "profile", fetch)
+			if err != nil {
+				t.Errorf("err: %s", err)
+			}
+			tokens[i] = token
+		}(i)
+	}
+	wg.Wait()
+
+	if fetches != 1 {
+		t.Fatalf("%d concurrent reads refreshed the token %d times; want once", len(tokens), fetches)
+	}
+	for _, token := range tokens {
+		if token != "token-1" {
+			t.Fatalf("got %q; want every reader to share token-1", token)
+		}
+	}
+
+	store.reject("token-1")
+	if token, _ := store.token(context.Background(), "profile", fetch); token != "token-2" {
+		t.Fatalf("got %q after rejection; want a refreshed token-2", token)
+	}
+}
+
+func TestTokenStore_expiry(t *testing.T) {
+	store := newTokenStore(nil)
+
+	var fetches int
+	fetch := func(ctx context.Context) (string, time.Time, error) {
+		fetches++
+		return "t", time.Now().Add(30 * time.Second), nil
+	}
+	store.token(context.Background(), "profile", fetch)
+	store.token(context.Background(), "profile", fetch)
+	if fetches != 2 {
+		t.Fatalf("fetched %d times; want a token within the expiry margin to be refreshed", fetches)
+	}
+}
+
+func TestTokenStore_unknownExpiry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token-store")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("TEST_TOKEN_STORE_KEY", "s3cr3t")
+	defer os.Unsetenv("TEST_TOKEN_STORE_KEY")
+	file, err := newTokenFileCache(filepath.Join(dir, "tokens"), "TEST_TOKEN_STORE_KEY", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	store := newTokenStore(file)
+
+	fetch := func(ctx context.Context) (string, time.Time, error) {
+		return "opaque", time.Time{}, nil
+	}
+	if token, err := store.token(context.Background(), "profile", fetch); err != nil || token != "opaque" {
+		t.Fatalf("got %q, %v; want opaque", token, err)
+	}
+	if _, ok := file.lookup("profile", 0); ok {
+		t.Errorf("token without an expiry was persisted")
+	}
+	if expiry := store.tokens["profile"].Expiry; expiry.IsZero() || expiry.After(time.Now().Add(unknownExpiryLifetime)) {
+		t.Errorf("token without an expiry kept until %s; want at most %s", expiry, unknownExpiryLifetime)
+	}
+}
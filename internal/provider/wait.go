@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// waitFor sends req every interval until it gets a response with an
+// expected status code or timeout elapses, so a data source can wait for a
+// service created earlier in the same apply to become ready. Once the time
+// is up the last response is returned for the caller to report, or an
+// error when the last attempt did not get one.
+func waitFor(ctx context.Context, client *http.Client, req *http.Request, policy *retryPolicy, budget *retryBudget, expected []interface{}, timeout, interval time.Duration) (*http.Response, error) {
+	deadline := time.Now().Add(timeout)
+	for attempt := 1; ; attempt++ {
+		resp, err := doWithRetry(ctx, client, req, policy, budget)
+		if err == nil && statusExpected(resp.StatusCode, expected) {
+			return resp, nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			if err != nil {
+				return nil, fmt.Errorf("not ready after %s (%d attempts): %s", timeout, attempt, err)
+			}
+			return resp, nil
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		req = req.Clone(ctx)
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
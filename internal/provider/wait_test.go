@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitFor(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make([]byte, 5)
+		r.Body.Read(body)
+		if string(body) != "probe" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	newProbe := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("probe"))
+		return req
+	}
+
+	resp, err := waitFor(context.Background(), server.Client(), newProbe(), nil, nil, nil, time.Second, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || requests != 3 {
+		t.Fatalf("got %d after %d requests; want 200 after 3", resp.StatusCode, requests)
+	}
+
+	// Once the time is up the last response is returned.
+	atomic.StoreInt32(&requests, -100)
+	resp, err = waitFor(context.Background(), server.Client(), newProbe(), nil, nil, nil, 50*time.Millisecond, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got %d; want the last 503", resp.StatusCode)
+	}
+
+	// Connection errors are retried too, and reported when time is up.
+	server.Close()
+	if _, err := waitFor(context.Background(), http.DefaultClient, newProbe(), nil, nil, nil, 30*time.Millisecond, 10*time.Millisecond); err == nil || !strings.Contains(err.Error(), "not ready after") {
+		t.Fatalf("got %v; want a timeout error", err)
+	}
+}